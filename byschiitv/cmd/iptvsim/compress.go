@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipMinSize is the smallest response worth compressing: below it the
+// gzip header and the CPU time cost more than the bytes saved.
+const gzipMinSize = 1024
+
+var gzipWriters = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return w
+}}
+
+// bufferedWriter holds the response body so gzipResponses can decide on
+// compression once it knows the size.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+// gzipResponses compresses JSON and text responses of at least gzipMinSize
+// for clients that accept gzip. The uplink is shared with the stream, and
+// playlist, EPG and history payloads compress well.
+func gzipResponses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Next()
+			return
+		}
+		w := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		h := w.Header()
+		h.Add("Vary", "Accept-Encoding")
+		body := w.buf.Bytes()
+		ctype := h.Get("Content-Type")
+		compressible := strings.HasPrefix(ctype, "application/json") || strings.HasPrefix(ctype, "text/")
+		if len(body) < gzipMinSize || !compressible || h.Get("Content-Encoding") != "" || w.Status() == http.StatusNotModified {
+			w.ResponseWriter.Write(body)
+			return
+		}
+
+		var out bytes.Buffer
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(&out)
+		gz.Write(body)
+		gz.Close()
+		gzipWriters.Put(gz)
+		h.Set("Content-Encoding", "gzip")
+		h.Set("Content-Length", strconv.Itoa(out.Len()))
+		w.ResponseWriter.Write(out.Bytes())
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/http"
+
+	"byschiitv/pkg/player"
+	"github.com/gin-gonic/gin"
+)
+
+// rejectWhileDraining answers 503 to requests adding items during a drain.
+func rejectWhileDraining(srv *player.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if srv.IsDraining() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "draining, not accepting items"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,794 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"byschiitv/pkg/ffmpegopts"
+	"byschiitv/pkg/player"
+	"byschiitv/pkg/playlist"
+)
+
+func main() {
+	// use gin in release mode by default for cleaner logging
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(gzipResponses())
+
+	rtmpURL := os.Getenv("RTMP_URL")
+	if rtmpURL == "" {
+		rtmpURL = "rtmp://iptvsim-nginx:1935/live/stream"
+	}
+	log.Printf("Using RTMP URL: %s", rtmpURL)
+
+	if v := os.Getenv("RTMP_FAILOVER_AFTER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			player.FailoverAfter = n
+		} else {
+			log.Printf("ignoring invalid RTMP_FAILOVER_AFTER=%q", v)
+		}
+	}
+	if v := os.Getenv("RTMP_FAILBACK_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			player.FailbackInterval = time.Duration(secs) * time.Second
+		} else {
+			log.Printf("ignoring invalid RTMP_FAILBACK_SECONDS=%q", v)
+		}
+	}
+
+	if server := os.Getenv("NTP_SERVER"); server != "" {
+		player.NTPServer = server
+	}
+	if v := os.Getenv("CLOCK_MAX_OFFSET_SECONDS"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+			player.ClockMaxOffset = time.Duration(secs * float64(time.Second))
+		} else {
+			log.Printf("ignoring invalid CLOCK_MAX_OFFSET_SECONDS=%q", v)
+		}
+	}
+
+	if v := os.Getenv("THERMAL_HOT_CELSIUS"); v != "" {
+		if c, err := strconv.ParseFloat(v, 64); err == nil && c > 0 {
+			player.ThermalHot = c
+		} else {
+			log.Printf("ignoring invalid THERMAL_HOT_CELSIUS=%q", v)
+		}
+	}
+	if v := os.Getenv("THERMAL_COOL_CELSIUS"); v != "" {
+		if c, err := strconv.ParseFloat(v, 64); err == nil && c > 0 && c < player.ThermalHot {
+			player.ThermalCool = c
+		} else {
+			log.Printf("ignoring invalid THERMAL_COOL_CELSIUS=%q", v)
+		}
+	}
+
+	if v := os.Getenv("LATE_POLICY"); v != "" {
+		if playlist.ValidLatePolicy(v) {
+			playlist.DefaultLatePolicy = v
+		} else {
+			log.Printf("ignoring invalid LATE_POLICY=%q", v)
+		}
+	}
+
+	if lang, file := os.Getenv("LANGUAGE"), os.Getenv("STRINGS_FILE"); lang != "" || file != "" {
+		st, err := ffmpegopts.LoadScreenStrings(lang, file)
+		if err != nil {
+			log.Printf("on-screen strings: %v", err)
+		}
+		player.ScreenText = st
+	}
+
+	if file := os.Getenv("BRANDING_FILE"); file != "" {
+		if err := player.ChannelBranding.Load(file); err != nil {
+			log.Printf("branding: %v", err)
+		}
+	}
+
+	player.IcecastMetadataURL = os.Getenv("ICECAST_METADATA_URL")
+
+	player.MosaicSources = player.ParseDestinations(os.Getenv("MOSAIC_SOURCES"))
+	player.MosaicURL = os.Getenv("MOSAIC_URL")
+
+	if dir := os.Getenv("PROMO_DIR"); dir != "" {
+		player.PromoDir = dir
+	}
+
+	if dir := os.Getenv("YTDLP_CACHE_DIR"); dir != "" {
+		player.YtdlpCacheDir = dir
+	}
+
+	if v := os.Getenv("DISK_WARN_FREE_MB"); v != "" {
+		if mb, err := strconv.ParseInt(v, 10, 64); err == nil && mb >= 0 {
+			player.DiskWarnFree = mb << 20
+		} else {
+			log.Printf("ignoring invalid DISK_WARN_FREE_MB=%q", v)
+		}
+	}
+	if v := os.Getenv("DISK_MIN_FREE_MB"); v != "" {
+		if mb, err := strconv.ParseInt(v, 10, 64); err == nil && mb >= 0 {
+			player.DiskMinFree = mb << 20
+		} else {
+			log.Printf("ignoring invalid DISK_MIN_FREE_MB=%q", v)
+		}
+	}
+	player.DiskExtraPaths = player.ParseDestinations(os.Getenv("DISK_PATHS"))
+
+	if path := os.Getenv("SERIES_STATE_FILE"); path != "" {
+		player.SeriesStateFile = path
+	}
+
+	if path, ok := os.LookupEnv("QUEUE_STATE_FILE"); ok {
+		player.QueueStateFile = path
+	}
+
+	if v := os.Getenv("DEAD_LETTER_AFTER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			player.DeadLetterAfter = n
+		} else {
+			log.Printf("ignoring invalid DEAD_LETTER_AFTER=%q", v)
+		}
+	}
+
+	if path, ok := os.LookupEnv("DURATION_CACHE_FILE"); ok {
+		player.DurationCacheFile = path
+	}
+
+	if dir, ok := os.LookupEnv("AS_RUN_DIR"); ok {
+		player.AsRunDir = dir
+	}
+	if v := os.Getenv("AS_RUN_KEEP_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			player.AsRunKeepDays = n
+		} else {
+			log.Printf("ignoring invalid AS_RUN_KEEP_DAYS=%q", v)
+		}
+	}
+
+	if dir := os.Getenv("MEDIA_DIR"); dir != "" {
+		player.MediaDir = dir
+	}
+
+	if v := os.Getenv("SCAN_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			player.ScanWorkers = n
+		} else {
+			log.Printf("ignoring invalid SCAN_WORKERS=%q", v)
+		}
+	}
+
+	if v := os.Getenv("REQUIRE_PLAYLIST_VERSION"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			requirePlaylistVersion = b
+		} else {
+			log.Printf("ignoring invalid REQUIRE_PLAYLIST_VERSION=%q", v)
+		}
+	}
+
+	if v := os.Getenv("PROBE_TIMEOUT_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			player.ProbeTimeout = time.Duration(secs) * time.Second
+		} else {
+			log.Printf("ignoring invalid PROBE_TIMEOUT_SECONDS=%q", v)
+		}
+	}
+
+	if v := os.Getenv("DEDUP_WINDOW_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			player.DedupWindow = time.Duration(secs) * time.Second
+		} else {
+			log.Printf("ignoring invalid DEDUP_WINDOW_SECONDS=%q", v)
+		}
+	}
+
+	if v := os.Getenv("SLOW_ENCODE_SECONDS"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			player.SlowEncodeWindow = time.Duration(secs) * time.Second
+		} else {
+			log.Printf("ignoring invalid SLOW_ENCODE_SECONDS=%q", v)
+		}
+	}
+
+	cacheDone := make(chan struct{})
+	cacheSaved := player.PersistDurations(cacheDone)
+
+	srv := player.NewServer(rtmpURL)
+
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	srv.RunBackground(background)
+
+	// Enqueue: /enque/<string> (capture rest of path)
+	r.GET(`/enque/*item`, rejectWhileDraining(srv), checkVersion(srv), func(c *gin.Context) {
+		item := c.Param("item")
+		item = strings.TrimPrefix(item, "/")
+		if item == "" {
+			c.String(http.StatusBadRequest, "missing item to enqueue")
+			return
+		}
+		if playlist.IsRemoteURL(item) {
+			if err := player.CheckRemoteURL(c.Request.Context(), item); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		n := srv.Append(item)
+		c.JSON(http.StatusOK, gin.H{"enqueued": item, "length": n, "version": srv.Version()})
+	})
+
+	// Enqueue from a JSON body: {"item": <path or /load object>,
+	// "metadata": {...}, "id": "optional"}. A retried request with the same
+	// Idempotency-Key header is not enqueued twice.
+	r.POST("/enqueue", rejectWhileDraining(srv), checkVersion(srv), func(c *gin.Context) {
+		var req struct {
+			ID       string                 `json:"id"`
+			Item     interface{}            `json:"item"`
+			Metadata map[string]interface{} `json:"metadata"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := player.EnqueueItem(req.Item, req.Metadata)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.ID != "" {
+			item["id"] = req.ID
+		}
+		id, position, fresh, err := srv.Enqueue(c.GetHeader("Idempotency-Key"), item)
+		if errors.Is(err, player.ErrDuplicateID) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "id": req.ID})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "position": position, "enqueued": fresh, "length": srv.Length(), "version": srv.Version()})
+	})
+
+	// List
+	// List: ?type=video|idle|..., ?from_index=N, ?offset=N&limit=N
+	r.GET("/list", etagged(srv), func(c *gin.Context) {
+		var q player.ListQuery
+		q.Type = c.Query("type")
+		for name, dst := range map[string]*int{"from_index": &q.FromIndex, "offset": &q.Offset, "limit": &q.Limit} {
+			v := c.Query(name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a non-negative integer"})
+				return
+			}
+			*dst = n
+		}
+		c.JSON(http.StatusOK, srv.ListPage(c.Request.Context(), q))
+	})
+
+	// Several edits applied all or nothing, see player.BatchOp
+	r.POST("/playlist/batch", checkVersion(srv), func(c *gin.Context) {
+		var ops []player.BatchOp
+		if err := c.BindJSON(&ops); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		n, err := srv.Batch(c.Request.Context(), ops)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "applied", "steps": len(ops), "length": n, "version": srv.Version()})
+	})
+
+	// Where an enqueued item stands
+	r.GET("/item/:id", etagged(srv), func(c *gin.Context) {
+		st, ok := srv.Item(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no item with this id"})
+			return
+		}
+		c.JSON(http.StatusOK, st)
+	})
+
+	// Remove a queued item, or cancel it if it is airing
+	r.DELETE("/item/:id", checkVersion(srv), func(c *gin.Context) {
+		outcome, item := srv.RemoveItem(c.Param("id"))
+		if outcome == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no item with this id"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": outcome, "id": c.Param("id"), "item": item, "version": srv.Version()})
+	})
+
+	// The item the player starts next
+	r.GET("/peek", etagged(srv), func(c *gin.Context) {
+		st, err := srv.Peek()
+		if errors.Is(err, player.ErrNextUnknown) {
+			c.JSON(http.StatusConflict, gin.H{"status": "unknown", "error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"status": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, st)
+	})
+
+	// Start
+	r.GET("/start", func(c *gin.Context) {
+		ok := srv.StartPlayer()
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"status": "already running"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "started"})
+	})
+
+	// Stop
+	r.GET("/stop", func(c *gin.Context) {
+		ok := srv.StopPlayer()
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"status": "not running"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "stopping"})
+	})
+
+	// Pause: finish the current item, then hold until /resume
+	r.GET("/pause", func(c *gin.Context) {
+		srv.SetPaused(true)
+		c.JSON(http.StatusOK, gin.H{"status": "paused", "playing": srv.IsPlaying()})
+	})
+
+	r.GET("/resume", func(c *gin.Context) {
+		srv.SetPaused(false)
+		c.JSON(http.StatusOK, gin.H{"status": "resumed"})
+	})
+
+	// Long poll: ?since=<seq> waits up to ?timeout=<seconds> (default 25,
+	// at most 50, under the server write timeout) for the playlist or the
+	// player to change. seq comes from the previous answer, 0 at first.
+	r.GET("/wait", func(c *gin.Context) {
+		since, err := strconv.ParseUint(c.DefaultQuery("since", "0"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a sequence number"})
+			return
+		}
+		timeout := 25
+		if v := c.Query("timeout"); v != "" {
+			if timeout, err = strconv.Atoi(v); err != nil || timeout < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "timeout must be seconds"})
+				return
+			}
+		}
+		timeout = min(timeout, 50)
+		v := srv.WaitChange(c.Request.Context(), since, time.Duration(timeout)*time.Second)
+		c.JSON(http.StatusOK, gin.H{
+			"changed": v.Seq > since,
+			"seq":     v.Seq,
+			"version": v.Version,
+			"state":   v.State,
+			"index":   v.Index,
+			"playing": v.Playing,
+		})
+	})
+
+	// Player status, programmed time, the active RTMP destination and the
+	// clock check
+	r.GET("/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, srv.Status(c.Request.Context()))
+	})
+
+	// Deep health check for Docker HEALTHCHECK: 503 only when unhealthy
+	r.GET("/healthz", func(c *gin.Context) {
+		h := srv.Health(c.Request.Context())
+		code := http.StatusOK
+		if h.Status == player.HealthUnhealthy {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, h)
+	})
+
+	// What aired on a day (YYYY-MM-DD or today), as JSON or ?format=csv
+	r.GET("/history/asrun/:date", func(c *gin.Context) {
+		day, err := player.ParseAsRunDay(c.Param("date"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entries, err := player.ReadAsRun(day)
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "nothing aired on " + day})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if c.Query("format") == "csv" {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="asrun-`+day+`.csv"`)
+			if err := player.WriteAsRunCSV(c.Writer, entries); err != nil {
+				log.Printf("as-run %s: %v", day, err)
+			}
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="asrun-`+day+`.json"`)
+		c.JSON(http.StatusOK, entries)
+	})
+
+	// Planned start times of a day against what aired: drift, skipped and
+	// unplanned items. ?tolerance=<seconds> for counting as on time.
+	r.GET("/history/reconcile/:date", func(c *gin.Context) {
+		day, err := player.ParseAsRunDay(c.Param("date"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		tolerance := player.ReconcileTolerance
+		if v := c.Query("tolerance"); v != "" {
+			secs, err := strconv.ParseFloat(v, 64)
+			if err != nil || secs < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "tolerance must be a non-negative number of seconds"})
+				return
+			}
+			tolerance = time.Duration(secs * float64(time.Second))
+		}
+		rec, err := srv.Reconcile(day, tolerance)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, rec)
+	})
+
+	// Branding packages, and switching the one on air from the next item
+	r.GET("/branding", func(c *gin.Context) {
+		packages, active := player.ChannelBranding.List()
+		c.JSON(http.StatusOK, gin.H{"active": active, "packages": packages})
+	})
+	r.GET("/branding/:name", func(c *gin.Context) {
+		if err := player.ChannelBranding.Use(c.Param("name")); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "switched", "active": c.Param("name")})
+	})
+
+	// Multiviewer of every channel, see MOSAIC_SOURCES
+	r.GET("/mosaic", func(c *gin.Context) {
+		c.JSON(http.StatusOK, player.Mosaic.Status())
+	})
+
+	// Ad break cue-out/cue-in times of the recent and current items
+	r.GET("/cues", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"cues": srv.Cues()})
+	})
+
+	// Player state and its recent transitions
+	r.GET("/state", etagged(srv), func(c *gin.Context) {
+		state, events := srv.State()
+		c.JSON(http.StatusOK, gin.H{"state": state, "events": events})
+	})
+
+	// Drain: refuse new items, air what is left, then stop
+	r.GET("/drain", func(c *gin.Context) {
+		c.JSON(http.StatusOK, srv.Drain())
+	})
+
+	r.GET("/drain/:mode", func(c *gin.Context) {
+		switch c.Param("mode") {
+		case "on":
+			srv.SetDraining(true)
+		case "off":
+			srv.SetDraining(false)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be on or off"})
+			return
+		}
+		c.JSON(http.StatusOK, srv.Drain())
+	})
+
+	// Next: cancel current item only
+	r.GET("/next", func(c *gin.Context) {
+		cur, ok := srv.Current()
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"status": "not playing"})
+			return
+		}
+		ok = srv.Next()
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"status": "not playing"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "skipped", "item": cur})
+	})
+
+	// Load playlist from JSON
+	r.POST("/load", rejectWhileDraining(srv), checkVersion(srv), func(c *gin.Context) {
+		var items []map[string]interface{}
+		if err := c.BindJSON(&items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := srv.LoadPlaylist(items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "loaded", "count": len(items), "version": srv.Version()})
+	})
+
+	// Whole queue export and import, see persist.go
+	r.GET("/snapshot", etagged(srv), func(c *gin.Context) {
+		st, err := srv.Snapshot()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, st)
+	})
+
+	r.POST("/snapshot", rejectWhileDraining(srv), checkVersion(srv), func(c *gin.Context) {
+		var st player.QueueState
+		if err := c.BindJSON(&st); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		n := srv.Import(st)
+		c.JSON(http.StatusOK, gin.H{"status": "imported", "count": n, "skipped": len(st.Playlist) - n, "version": srv.Version()})
+	})
+
+	// Shuffle: pick the next item at random by weight instead of in order
+	r.GET("/shuffle/:mode", func(c *gin.Context) {
+		switch c.Param("mode") {
+		case "on":
+			srv.SetShuffle(true)
+		case "off":
+			srv.SetShuffle(false)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be on or off"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"shuffle": srv.IsShuffle()})
+	})
+
+	// What is on air, with its metadata
+	r.GET("/nowplaying", etagged(srv), func(c *gin.Context) {
+		c.JSON(http.StatusOK, srv.NowPlaying())
+	})
+
+	// Programme guide from the current item on
+	r.GET("/epg", etagged(srv), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"programmes": srv.EPG(c.Request.Context())})
+	})
+
+	// Render a "tonight on" promo of the upcoming programmes to a file in the
+	// background: ?from=HH:MM (today, default now), ?count=N, ?seconds=N, and
+	// ?enqueue=true to append it to the playlist. Poll GET /promo/<id>.
+	r.POST("/promo", checkVersion(srv), func(c *gin.Context) {
+		enqueue := c.Query("enqueue") == "true"
+		if enqueue && srv.IsDraining() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "draining, not accepting items"})
+			return
+		}
+		from := time.Now()
+		if v := c.Query("from"); v != "" {
+			t, err := time.ParseInLocation("15:04", v, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be HH:MM"})
+				return
+			}
+			from = time.Date(from.Year(), from.Month(), from.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+		}
+		count, seconds := player.MaxPromoEntries, player.DefaultPromoSeconds
+		for name, dst := range map[string]*int{"count": &count, "seconds": &seconds} {
+			v := c.Query(name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a positive integer"})
+				return
+			}
+			*dst = n
+		}
+		count = min(count, player.MaxPromoEntries)
+		job := srv.StartPromo(from, count, seconds, enqueue)
+		c.JSON(http.StatusAccepted, gin.H{"job": job, "status_url": "/promo/" + job.ID})
+	})
+
+	// A promo render started by POST /promo
+	r.GET("/promo/:id", func(c *gin.Context) {
+		job, ok := srv.PromoJob(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no promo render " + c.Param("id")})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	// Saved playlists, referenced by {"type": "playlist", "name": ...}
+	r.GET("/playlists", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"playlists": srv.SavedPlaylists()})
+	})
+
+	r.GET("/playlists/:name", func(c *gin.Context) {
+		playlist, ok := srv.SavedPlaylist(c.Param("name"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "playlist not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"name": c.Param("name"), "queue": playlist})
+	})
+
+	r.POST("/playlists/:name", func(c *gin.Context) {
+		var items []map[string]interface{}
+		if err := c.BindJSON(&items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		n, err := srv.SavePlaylist(c.Param("name"), items)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "saved", "name": c.Param("name"), "count": n})
+	})
+
+	r.DELETE("/playlists/:name", func(c *gin.Context) {
+		if !srv.DeletePlaylist(c.Param("name")) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "playlist not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "deleted", "name": c.Param("name")})
+	})
+
+	// Items taken out of the playlist after repeated failures
+	r.GET("/dead", etagged(srv), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"dead": srv.DeadItems()})
+	})
+
+	r.POST("/dead/:id/retry", checkVersion(srv), func(c *gin.Context) {
+		position, ok := srv.RetryDead(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no dead item with this id"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "requeued", "id": c.Param("id"), "position": position, "version": srv.Version()})
+	})
+
+	// Prometheus metrics
+	r.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4")
+		srv.WriteMetrics(c.Writer)
+	})
+
+	// Library scan: /rescan starts one, /scan reports progress
+	r.GET("/rescan", func(c *gin.Context) {
+		st, started := srv.Rescan()
+		c.JSON(http.StatusOK, gin.H{"started": started, "scan": st})
+	})
+
+	r.GET("/scan", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"scan": srv.ScanStatus()})
+	})
+
+	// Search the media library: ?q=title&algo=combined&limit=20
+	r.GET("/library/search", func(c *gin.Context) {
+		query := c.Query("q")
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing q"})
+			return
+		}
+		limit := player.DefaultSearchLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+				return
+			}
+			limit = n
+		}
+		files, err := player.SearchLibrary(player.MediaDir, query, c.Query("algo"), limit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"query": query, "files": files})
+	})
+
+	// Automatic quality downgrades
+	r.GET("/downgrades", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"downgrades": srv.QualityEvents()})
+	})
+
+	// Series episode positions
+	r.GET("/series", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"series": srv.SeriesPositions()})
+	})
+
+	// root
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "iptvsim server. endpoints: /enque/<string> /enqueue (POST) /next /list /item/<id> /peek /status /healthz /start /stop /pause /resume /state /wait /drain /drain/on|off /load (POST) /playlist/batch (POST) /snapshot /shuffle/on|off /nowplaying /epg /promo (POST) /promo/<id> /playlists /dead /branding /branding/<name> /mosaic /cues /history/asrun/<date> /history/reconcile/<date> /downgrades /series /metrics /rescan /scan /library/search")
+	})
+
+	addr := os.Getenv("LISTEN_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadHeaderTimeout: envSeconds("READ_HEADER_TIMEOUT_SECONDS", 10*time.Second),
+		ReadTimeout:       envSeconds("READ_TIMEOUT_SECONDS", 30*time.Second),
+		WriteTimeout:      envSeconds("WRITE_TIMEOUT_SECONDS", 60*time.Second),
+		IdleTimeout:       envSeconds("IDLE_TIMEOUT_SECONDS", 120*time.Second),
+	}
+
+	// List files in the media folder, then probe the library
+	entries, err := os.ReadDir(player.MediaDir)
+	if err != nil {
+		log.Printf("failed to read %s: %v", player.MediaDir, err)
+	} else {
+		for _, entry := range entries {
+			log.Printf("%s: %s (dir: %v)", player.MediaDir, entry.Name(), entry.IsDir())
+		}
+		srv.Rescan()
+	}
+	go srv.NotifyReady(background)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		log.Printf("gin server: starting on %s", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("gin server: ListenAndServe: %v", err)
+		}
+	}()
+
+	<-stop
+	log.Println("gin server: shutting down")
+	player.SdNotify("STOPPING=1")
+	srv.StopPlayer()
+	stopBackground()
+	close(cacheDone)
+	<-cacheSaved
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Fatalf("gin server: Shutdown: %v", err)
+	}
+	log.Println("gin server: exited")
+}
+
+// envSeconds reads a duration in seconds from the environment, def when
+// unset or invalid. 0 means no timeout.
+func envSeconds(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		log.Printf("ignoring invalid %s=%q", name, v)
+		return def
+	}
+	return time.Duration(secs) * time.Second
+}
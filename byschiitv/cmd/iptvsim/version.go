@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"byschiitv/pkg/player"
+	"github.com/gin-gonic/gin"
+)
+
+// requirePlaylistVersion makes If-Match mandatory on playlist edits, so two
+// editors can't overwrite each other's changes. REQUIRE_PLAYLIST_VERSION=false
+// in main turns it off for scripts that never read the version.
+var requirePlaylistVersion = true
+
+// parseVersion reads an If-Match value: a bare or quoted version number, or
+// an ETag as sent by the read endpoints, "v5-3", of which only the playlist
+// version counts.
+func parseVersion(header string) (uint64, bool) {
+	value := strings.Trim(strings.TrimPrefix(strings.TrimSpace(header), "W/"), `"`)
+	if tag, ok := strings.CutPrefix(value, "v"); ok {
+		value, _, ok = strings.Cut(tag, "-")
+		if !ok {
+			return 0, false
+		}
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	return v, err == nil
+}
+
+// checkVersion guards a playlist edit with optimistic concurrency: the
+// client sends the version it last read in If-Match, and gets 412 if the
+// playlist changed since. Edits are serialized, so the check and the edit
+// can't interleave with another client's.
+func checkVersion(srv *player.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		srv.LockEdits()
+		defer srv.UnlockEdits()
+		header := c.GetHeader("If-Match")
+		if header == "" {
+			if requirePlaylistVersion {
+				c.AbortWithStatusJSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match with the playlist version is required", "version": srv.Version()})
+				return
+			}
+			c.Next()
+			return
+		}
+		want, ok := parseVersion(header)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "If-Match must be a playlist version or ETag"})
+			return
+		}
+		if have := srv.Version(); have != want {
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "playlist changed since it was read", "version": have})
+			return
+		}
+		c.Next()
+	}
+}
+
+// etagged answers 304 when the client's If-None-Match is the current ETag,
+// so pollers skip re-downloading unchanged JSON.
+func etagged(srv *player.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag := srv.ETag()
+		if match := c.GetHeader("If-None-Match"); match != "" && (match == tag || match == "W/"+tag) {
+			c.Header("ETag", tag)
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+		c.Header("ETag", tag)
+		c.Next()
+	}
+}
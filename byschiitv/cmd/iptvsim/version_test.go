@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"byschiitv/pkg/player"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   uint64
+		ok     bool
+	}{
+		{"5", 5, true},
+		{`"5"`, 5, true},
+		{`"v5-3"`, 5, true},
+		{`W/"v5-3"`, 5, true},
+		{"v5-3", 5, true},
+		{`"v5"`, 0, false},
+		{"five", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseVersion(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseVersion(%q) = %d, %v, want %d, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := player.QueueStateFile
+	player.QueueStateFile = filepath.Join(t.TempDir(), "queue.json")
+	t.Cleanup(func() { player.QueueStateFile = saved })
+	s := player.NewServer("rtmp://nginx:1935/live/stream")
+	s.Append("/media/a.mp4")
+	r := gin.New()
+	r.POST("/edit", checkVersion(s), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int
+	}{
+		{"missing", "", http.StatusPreconditionRequired},
+		{"current version", "1", http.StatusOK},
+		{"current ETag", s.ETag(), http.StatusOK},
+		{"stale", "0", http.StatusPreconditionFailed},
+		{"invalid", "latest", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/edit", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ module byschiitv
 
 go 1.23.3
 
-require github.com/gin-gonic/gin v1.11.0
+require (
+	github.com/gin-gonic/gin v1.11.0
+	textmatch v0.0.0
+)
 
 require (
 	github.com/bytedance/sonic v1.14.0 // indirect
@@ -37,3 +40,6 @@ require (
 	golang.org/x/tools v0.34.0 // indirect
 	google.golang.org/protobuf v1.36.9 // indirect
 )
+
+// textmatch is the shared module at the repository root
+replace textmatch => ../textmatch
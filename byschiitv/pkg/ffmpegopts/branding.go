@@ -0,0 +1,104 @@
+package ffmpegopts
+
+import (
+	"fmt"
+	"strconv"
+
+	"byschiitv/pkg/filtergraph"
+)
+
+// Palette are the colors of generated video, in ffmpeg color syntax.
+type Palette struct {
+	Background string `json:"background,omitempty"`
+	// Primary is the intermission title
+	Primary string `json:"primary,omitempty"`
+	// Secondary is the "coming up next" line
+	Secondary string `json:"secondary,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Muted     string `json:"muted,omitempty"`
+	// Accent is the countdown
+	Accent string `json:"accent,omitempty"`
+}
+
+// LowerThird styles the scrolling banner over videos.
+type LowerThird struct {
+	FontSize  int    `json:"font_size,omitempty"`
+	FontColor string `json:"font_color,omitempty"`
+	// BoxColor draws a box behind the text, none if empty
+	BoxColor string `json:"box_color,omitempty"`
+}
+
+// Branding is a named package of channel look: the assets and colors used
+// by video overlays, the intermission card and text cards. Paths are image,
+// video and font files as seen by ffmpeg.
+type Branding struct {
+	Name string `json:"name"`
+	// Logo is an image bug overlaid on videos and the intermission
+	Logo string `json:"logo,omitempty"`
+	// LogoPosition is top-left, top-right (default), bottom-left or
+	// bottom-right
+	LogoPosition string     `json:"logo_position,omitempty"`
+	LogoWidth    int        `json:"logo_width,omitempty"`
+	LowerThird   LowerThird `json:"lower_third"`
+	// IntermissionBackground is an image behind the intermission card,
+	// instead of Palette.Background
+	IntermissionBackground string `json:"intermission_background,omitempty"`
+	// Ident is the clip aired by "ident" playlist items
+	Ident string `json:"ident,omitempty"`
+	// Font is the font file of every drawtext, ffmpeg's default if empty
+	Font    string  `json:"font,omitempty"`
+	Palette Palette `json:"palette"`
+}
+
+func (br Branding) Validate() error {
+	switch br.LogoPosition {
+	case "top-left", "top-right", "bottom-left", "bottom-right":
+	default:
+		return fmt.Errorf("unknown logo_position %q", br.LogoPosition)
+	}
+	if br.LogoWidth <= 0 {
+		return fmt.Errorf("logo_width must be positive")
+	}
+	return nil
+}
+
+// LogoInputArgs are the ffmpeg input options of the logo, none without one.
+func (br Branding) LogoInputArgs() []string {
+	if br.Logo == "" {
+		return nil
+	}
+	return []string{"-loop", "1", "-i", br.Logo}
+}
+
+// OverlayLogo labels video "v", putting the logo from input logoInput
+// over it when the package has one.
+func (br Branding) OverlayLogo(video filtergraph.FilterChain, logoInput int) filtergraph.FilterGraph {
+	if br.Logo == "" {
+		video.Outputs = []string{"v"}
+		return filtergraph.FilterGraph{video}
+	}
+	video.Outputs = []string{"base"}
+	const margin = "24"
+	x, y := margin, margin
+	switch br.LogoPosition {
+	case "top-right":
+		x = "W-w-" + margin
+	case "bottom-left":
+		y = "H-h-" + margin
+	case "bottom-right":
+		x, y = "W-w-"+margin, "H-h-"+margin
+	}
+	return filtergraph.FilterGraph{
+		video,
+		{
+			Inputs:  []string{strconv.Itoa(logoInput) + ":v"},
+			Filters: []filtergraph.Filter{filtergraph.Scale{Width: br.LogoWidth, Height: -1}},
+			Outputs: []string{"logo"},
+		},
+		{
+			Inputs:  []string{"base", "logo"},
+			Filters: []filtergraph.Filter{filtergraph.Overlay{X: x, Y: y, Shortest: true}},
+			Outputs: []string{"v"},
+		},
+	}
+}
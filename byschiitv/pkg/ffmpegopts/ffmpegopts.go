@@ -0,0 +1,580 @@
+// Package ffmpegopts builds the ffmpeg command lines for each kind of
+// element. Builders take the Branding to draw with as their first argument.
+package ffmpegopts
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"byschiitv/pkg/filtergraph"
+	"byschiitv/pkg/playlist"
+)
+
+type Q struct {
+	Width    int
+	Height   int
+	FPS      int
+	VBitrate string
+	ABitrate string
+}
+
+var Qualities169 = []Q{
+	// 0 Ultra (SW fallback for 1080p60)
+	{Width: 1920, Height: 1080, FPS: 60, VBitrate: "10000k", ABitrate: "128k"}, // ULTRA_1080p60 (SW libx264 recommended)
+
+	// 1 High (safe for Pi HW)
+	{Width: 1920, Height: 1080, FPS: 30, VBitrate: "8000k", ABitrate: "128k"}, // HIGH_1080p30 (HW h264_v4l2m2m)
+
+	// 2 Sports (fast motion with fewer pixels)
+	{Width: 1280, Height: 720, FPS: 60, VBitrate: "6000k", ABitrate: "128k"}, // SPORTS_720p60 (HW ok)
+
+	// 3 Standard HD
+	{Width: 1280, Height: 720, FPS: 30, VBitrate: "3500k", ABitrate: "128k"}, // STANDARD_720p30
+
+	// 4 Economy SD
+	{Width: 854, Height: 480, FPS: 30, VBitrate: "1200k", ABitrate: "96k"}, // ECONOMY_480p30
+
+	// 5 Mobile / low bandwidth
+	{Width: 640, Height: 360, FPS: 30, VBitrate: "700k", ABitrate: "64k"}, // MOBILE_360p30
+}
+
+var Qualities43 = []Q{
+	{Width: 960, Height: 720, FPS: 30, VBitrate: "2000k", ABitrate: "128k"}, // HD
+	{Width: 640, Height: 480, FPS: 23, VBitrate: "1000k", ABitrate: "96k"},  // SD
+	{Width: 480, Height: 360, FPS: 15, VBitrate: "600k", ABitrate: "64k"},   // LD
+}
+
+// FfmpegCommand builds an ffmpeg arg list streaming videoPath to RTMP, from
+// start to end (0 for the end of the file).
+// - Uses HW encoder (h264_v4l2m2m) for typical cases.
+// - Automatically switches to software (libx264) for 1080p60, which Pi HW can't do.
+// - Adds realtime-friendly flags: GOP≈2s, VBV, zerolatency, etc.
+// - Seeks to start when trimming, or restarting an item mid-way.
+// - Stops at end, an error if start is already past it.
+// - Reports progress on stdout (-progress pipe:1) so encode speed can be watched.
+func FfmpegCommand(brand Branding, videoPath string, rtmpURL string, ciccione bool, quality int, bannerText string, start, end time.Duration) ([]string, error) {
+	if end > 0 && start >= end {
+		return nil, fmt.Errorf("%s: start %s is past the end %s", videoPath, start, end)
+	}
+	q, quality := pickQuality(ciccione, quality)
+
+	// Build video filter chain
+	vFilter := filtergraph.Chain(
+		filtergraph.Scale{Width: q.Width, Height: q.Height},
+		filtergraph.FPS{Rate: q.FPS},
+		filtergraph.Format{PixFmt: "yuv420p"},
+	)
+	if bannerText != "" {
+		vFilter = vFilter.Then(getTextFilter(bannerText, brand))
+	}
+	vFilter.Inputs = []string{"0:v"}
+	graph := brand.OverlayLogo(vFilter, 1)
+	if err := graph.Validate(); err != nil {
+		return nil, fmt.Errorf("video filter: %w", err)
+	}
+
+	encoder, extra := videoEncoderArgs(q)
+
+	log.Printf("FFmpeg command for %s (encoder=%v, quality=%d, textBanner=%v)", videoPath, encoder, quality, bannerText != "")
+
+	// Assemble args
+	args := []string{"-re"}
+	if start > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", start.Seconds()))
+	}
+	args = append(args, RemoteInputArgs(videoPath)...)
+	args = append(args, "-i", videoPath)
+	args = append(args, brand.LogoInputArgs()...)
+	if end > 0 {
+		// input seeking resets timestamps, so -t is the length left
+		args = append(args, "-t", fmt.Sprintf("%.3f", (end-start).Seconds()))
+	}
+	args = append(args,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "0:a?",
+		"-pix_fmt", "yuv420p",
+		"-c:v", encoder,
+	)
+	args = append(args, extra...)
+	args = append(args,
+		"-b:v", q.VBitrate,
+		"-c:a", "aac",
+		"-b:a", q.ABitrate,
+		"-ar", "48000",
+		"-ac", "2",
+		"-progress", "pipe:1",
+		"-f", "flv",
+		rtmpURL,
+	)
+
+	return args, nil
+}
+
+// FfmpegLiveCommand relays a live source (RTMP/HLS/RTSP) into the channel,
+// re-encoded to the given preset. durationSeconds <= 0 relays until cancelled.
+// No -re here: a live source already arrives in realtime.
+func FfmpegLiveCommand(brand Branding, sourceURL string, rtmpURL string, ciccione bool, quality int, durationSeconds int) ([]string, error) {
+	q, quality := pickQuality(ciccione, quality)
+
+	vFilter := filtergraph.FilterChain{
+		Inputs: []string{"0:v"},
+		Filters: []filtergraph.Filter{
+			filtergraph.Scale{Width: q.Width, Height: q.Height},
+			filtergraph.FPS{Rate: q.FPS},
+			filtergraph.Format{PixFmt: "yuv420p"},
+		},
+	}
+	graph := brand.OverlayLogo(vFilter, 1)
+	if err := graph.Validate(); err != nil {
+		return nil, fmt.Errorf("live filter: %w", err)
+	}
+
+	encoder, extra := videoEncoderArgs(q)
+
+	log.Printf("FFmpeg live relay for %s (encoder=%v, quality=%d, duration=%ds)", sourceURL, encoder, quality, durationSeconds)
+
+	args := LiveInputArgs(sourceURL)
+	args = append(args, "-i", sourceURL)
+	args = append(args, brand.LogoInputArgs()...)
+	if durationSeconds > 0 {
+		args = append(args, "-t", strconv.Itoa(durationSeconds))
+	}
+	args = append(args,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "0:a?",
+		"-pix_fmt", "yuv420p",
+		"-c:v", encoder,
+	)
+	args = append(args, extra...)
+	args = append(args,
+		"-b:v", q.VBitrate,
+		"-c:a", "aac",
+		"-b:a", q.ABitrate,
+		"-ar", "48000",
+		"-ac", "2",
+		"-f", "flv",
+		rtmpURL,
+	)
+	return args, nil
+}
+
+// LiveInputArgs are the protocol specific input options for a live source.
+func LiveInputArgs(sourceURL string) []string {
+	lower := strings.ToLower(sourceURL)
+	switch {
+	case strings.HasPrefix(lower, "rtsp://"):
+		// UDP drops too much over wifi/vpn
+		return []string{"-rtsp_transport", "tcp", "-timeout", "15000000"}
+	case playlist.IsRemoteURL(sourceURL):
+		return RemoteInputArgs(sourceURL)
+	default:
+		return []string{"-rw_timeout", "15000000"}
+	}
+}
+
+// pickQuality clamps quality to the presets of the aspect ratio and returns
+// the preset with the index actually used.
+func pickQuality(ciccione bool, quality int) (Q, int) {
+	presets := Qualities169
+	if ciccione {
+		presets = Qualities43
+	}
+	if quality < 0 {
+		quality = 0
+	}
+	if quality >= len(presets) {
+		quality = len(presets) - 1
+	}
+	return presets[quality], quality
+}
+
+// videoEncoderArgs decides the encoder for a preset and its rate control flags.
+func videoEncoderArgs(q Q) (string, []string) {
+	usingRaspberryPi := true
+	want1080p60 := (q.Width >= 1920 && q.FPS > 30)
+
+	if want1080p60 || !usingRaspberryPi {
+		// Fall back to software for 1080p60
+		// Real-time, low-latency RTMP-friendly settings
+		level := "4.2" // for 1080p60
+		gop := q.FPS * 2
+		bufk := 2 * atoiK(q.VBitrate) // 2x VBV buffer
+		return "libx264", []string{
+			"-preset", "veryfast", // try "ultrafast" if CPU is tight
+			"-tune", "zerolatency",
+			"-profile:v", "high",
+			"-level:v", level,
+			"-g", strconv.Itoa(gop),
+			"-keyint_min", strconv.Itoa(gop),
+			"-sc_threshold", "0",
+			"-maxrate", q.VBitrate,
+			"-bufsize", fmt.Sprintf("%dk", bufk),
+			"-threads", "0",
+		}
+	}
+
+	// Use Pi HW encoder
+	// Keep a stable GOP; VBV helps RTMP stability on some setups
+	gop := q.FPS * 2
+	bufk := 2 * atoiK(q.VBitrate)
+	return "h264_v4l2m2m", []string{
+		"-g", strconv.Itoa(gop),
+		"-maxrate", q.VBitrate,
+		"-bufsize", fmt.Sprintf("%dk", bufk),
+	}
+}
+
+// LowestQuality returns the index of the cheapest preset for the aspect ratio.
+func LowestQuality(ciccione bool) int {
+	if ciccione {
+		return len(Qualities43) - 1
+	}
+	return len(Qualities169) - 1
+}
+
+// atoiK converts "8000k" -> 8000 (kbit). Returns 0 on error.
+func atoiK(s string) int {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "k")
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func getTextFilter(description string, brand Branding) filtergraph.DrawText {
+	interval := 25        // seconds for one full scroll cycle, from appearance to disappearance
+	duration := 10        // seconds the text is fully visible, from left edge to right edge
+	scrollDistance := 1.8 // how far to scroll (1.0 = full width, 2.0 = twice width, etc)
+
+	// padd up to 100 chars
+	strPadding := 150
+	if len(description) < strPadding {
+		description = description + strings.Repeat(" ", strPadding-len(description))
+	}
+
+	banner := filtergraph.DrawText{
+		Text:      description,
+		FontFile:  brand.Font,
+		FontSize:  brand.LowerThird.FontSize,
+		FontColor: brand.LowerThird.FontColor,
+		X:         fmt.Sprintf("w-(mod(t,%d)*w*%.1f/%d)", interval, scrollDistance, duration),
+		Y:         "h-50",
+		Enable:    fmt.Sprintf("lt(mod(t,%d),%d)", interval, duration),
+	}
+	if brand.LowerThird.BoxColor != "" {
+		banner.Box, banner.BoxColor, banner.BoxBorderW = true, brand.LowerThird.BoxColor, 6
+	}
+	return banner
+}
+
+func FfmpegIdleStreamCommand(brand Branding, text ScreenStrings, rtmpURL string, durationSeconds int, nextMovie string, description string, startTimeUnix int64) ([]string, error) {
+	currentTime := time.Now().Unix()
+	secondsUntilStart := startTimeUnix - currentTime
+	if startTimeUnix <= 0 || secondsUntilStart < 0 {
+		// unknown start: count down the break itself
+		secondsUntilStart = int64(durationSeconds)
+	}
+	palette := brand.Palette
+
+	// Intelligently handle long descriptions:
+	// - Short descriptions: show static centered text
+	// - Long descriptions: scroll horizontally (ticker style)
+	descFilter := filtergraph.DrawText{
+		Text:       description,
+		FontFile:   brand.Font,
+		FontSize:   22,
+		FontColor:  palette.Muted,
+		X:          "(w-text_w)/2", // short description - static centered display
+		Y:          "h/2+60",
+		Box:        true,
+		BoxColor:   "black@0.4",
+		BoxBorderW: 5,
+	}
+	if len(description) > 80 {
+		// Long description - scrolling ticker
+		// Scrolls right to left continuously
+		descFilter.X = "w-mod(t*80,w+tw)"
+	}
+
+	// Background: the package's image, or a solid color
+	duration := strconv.Itoa(durationSeconds)
+	var args []string
+	videoFilter := filtergraph.FilterChain{Inputs: []string{"0:v"}}
+	if brand.IntermissionBackground != "" {
+		args = []string{"-loop", "1", "-framerate", "15", "-t", duration, "-i", brand.IntermissionBackground}
+		videoFilter = videoFilter.Then(
+			filtergraph.Scale{Width: 1280, Height: 720, FitInside: true},
+			filtergraph.Pad{Width: 1280, Height: 720, Color: palette.Background},
+		)
+	} else {
+		background := filtergraph.Color{Width: 1280, Height: 720, Rate: 15, Color: palette.Background}
+		if err := background.Validate(); err != nil {
+			return nil, fmt.Errorf("idle background: %w", err)
+		}
+		args = []string{"-f", "lavfi", "-t", duration, "-i", background.String()}
+	}
+	videoFilter = videoFilter.Then(
+		filtergraph.Format{PixFmt: "yuv420p"},
+		// Top: Stream status with pulsing effect
+		filtergraph.DrawText{
+			Text:       text.Intermission,
+			FontFile:   brand.Font,
+			FontSize:   42,
+			FontColor:  palette.Primary,
+			X:          "(w-text_w)/2",
+			Y:          "80",
+			Box:        true,
+			BoxColor:   "black@0.6",
+			BoxBorderW: 10,
+			Alpha:      "0.85+0.15*sin(t)",
+		},
+	)
+	// Middle section: Next movie title, when something follows the break
+	if nextMovie != "" {
+		videoFilter = videoFilter.Then(
+			filtergraph.DrawText{
+				Text:      text.ComingUpNext,
+				FontFile:  brand.Font,
+				FontSize:  28,
+				FontColor: palette.Secondary,
+				X:         "(w-text_w)/2",
+				Y:         "h/2-120",
+			},
+			filtergraph.DrawText{
+				Text:       nextMovie,
+				FontFile:   brand.Font,
+				FontSize:   46,
+				FontColor:  palette.Text,
+				X:          "(w-text_w)/2",
+				Y:          "h/2-70",
+				Box:        true,
+				BoxColor:   "black@0.5",
+				BoxBorderW: 8,
+			},
+		)
+	}
+	// Description (smart display)
+	if description != "" {
+		videoFilter = videoFilter.Then(descFilter)
+	}
+	// Bottom: Countdown timer
+	videoFilter = videoFilter.Then(filtergraph.DrawText{
+		Text:       text.CountdownText(secondsUntilStart),
+		Expand:     true,
+		FontFile:   brand.Font,
+		FontSize:   36,
+		FontColor:  palette.Accent,
+		X:          "(w-text_w)/2",
+		Y:          "h-120",
+		Box:        true,
+		BoxColor:   "black@0.5",
+		BoxBorderW: 6,
+	})
+	graph := brand.OverlayLogo(videoFilter, 2)
+	if err := graph.Validate(); err != nil {
+		return nil, fmt.Errorf("idle filter: %w", err)
+	}
+
+	args = append(args,
+		"-f", "lavfi",
+		"-t", duration,
+		"-i", "anullsrc=channel_layout=stereo:sample_rate=44100",
+	)
+	args = append(args, brand.LogoInputArgs()...)
+	return append(args,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "1:a",
+		"-c:v", "h264_v4l2m2m",
+		"-b:v", "500k",
+		"-c:a", "aac",
+		"-b:a", "64k",
+		"-f", "flv",
+		rtmpURL,
+	), nil
+}
+
+// FfmpegTextCardCommand renders a full-screen title card: a solid background
+// with a big title and an optional subtitle, fading in over the first second.
+func FfmpegTextCardCommand(brand Branding, rtmpURL string, card playlist.TextCardElement) ([]string, error) {
+	background := card.BackgroundColor
+	if background == "" {
+		background = brand.Palette.Background
+	}
+	titleColor := card.TitleColor
+	if titleColor == "" {
+		titleColor = brand.Palette.Text
+	}
+	subtitleColor := card.SubtitleColor
+	if subtitleColor == "" {
+		subtitleColor = brand.Palette.Muted
+	}
+	duration := card.DurationSeconds
+	if duration <= 0 {
+		duration = playlist.DefaultTextCardSeconds
+	}
+
+	videoFilter := filtergraph.Chain(
+		filtergraph.Color{Width: 1280, Height: 720, Rate: 25, Color: background},
+		filtergraph.DrawText{
+			Text:      card.Title,
+			FontFile:  brand.Font,
+			FontSize:  64,
+			FontColor: titleColor,
+			X:         "(w-text_w)/2",
+			Y:         "(h-text_h)/2-40",
+			Alpha:     "min(1,t)",
+		},
+	)
+	if card.Subtitle != "" {
+		videoFilter = videoFilter.Then(filtergraph.DrawText{
+			Text:      card.Subtitle,
+			FontFile:  brand.Font,
+			FontSize:  32,
+			FontColor: subtitleColor,
+			X:         "(w-text_w)/2",
+			Y:         "(h-text_h)/2+50",
+			Alpha:     "min(1,t)",
+		})
+	}
+	if err := videoFilter.Validate(); err != nil {
+		return nil, fmt.Errorf("text card filter: %w", err)
+	}
+
+	return []string{
+		"-f", "lavfi",
+		"-t", strconv.Itoa(duration),
+		"-i", videoFilter.String(),
+		"-f", "lavfi",
+		"-t", strconv.Itoa(duration),
+		"-i", "anullsrc=channel_layout=stereo:sample_rate=44100",
+		"-c:v", "h264_v4l2m2m",
+		"-b:v", "500k",
+		"-c:a", "aac",
+		"-b:a", "64k",
+		"-f", "flv",
+		rtmpURL,
+	}, nil
+}
+
+// FfmpegAudioCommand airs an audio file with a generated video track: a
+// waveform, a spectrum, or a still artwork image letterboxed to 720p.
+func FfmpegAudioCommand(brand Branding, rtmpURL string, audio playlist.AudioElement) ([]string, error) {
+	const width, height, fps = 1280, 720, 25
+
+	args := []string{"-re"}
+	if audio.Offset > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", audio.Offset.Seconds()))
+	}
+	args = append(args, "-i", audio.Path)
+
+	var video filtergraph.FilterChain
+	switch {
+	case audio.Visualizer == "artwork" && audio.Artwork != "":
+		args = append(args, "-loop", "1", "-framerate", strconv.Itoa(fps), "-i", audio.Artwork)
+		video = filtergraph.FilterChain{
+			Inputs: []string{"1:v"},
+			Filters: []filtergraph.Filter{
+				filtergraph.Scale{Width: width, Height: height, FitInside: true},
+				filtergraph.Pad{Width: width, Height: height, Color: "black"},
+			},
+		}
+	case audio.Visualizer == "spectrum":
+		video = filtergraph.FilterChain{
+			Inputs: []string{"0:a"},
+			Filters: []filtergraph.Filter{
+				filtergraph.ShowSpectrum{Width: width, Height: height, Color: "intensity"},
+				filtergraph.FPS{Rate: fps},
+			},
+		}
+	default:
+		video = filtergraph.FilterChain{
+			Inputs:  []string{"0:a"},
+			Filters: []filtergraph.Filter{filtergraph.ShowWaves{Width: width, Height: height, Rate: fps, Mode: "cline"}},
+		}
+	}
+	video = video.Then(filtergraph.Format{PixFmt: "yuv420p"})
+	if audio.Title != "" {
+		video = video.Then(filtergraph.DrawText{
+			Text:       audio.Title,
+			FontFile:   brand.Font,
+			FontSize:   32,
+			FontColor:  "white",
+			X:          "(w-text_w)/2",
+			Y:          "h-80",
+			Box:        true,
+			BoxColor:   "black@0.5",
+			BoxBorderW: 8,
+		})
+	}
+	video.Outputs = []string{"v"}
+
+	graph := filtergraph.FilterGraph{video}
+	if err := graph.Validate(); err != nil {
+		return nil, fmt.Errorf("audio filter: %w", err)
+	}
+
+	return append(args,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "0:a",
+		"-shortest",
+		"-c:v", "h264_v4l2m2m",
+		"-b:v", "1000k",
+		"-g", strconv.Itoa(fps*2),
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "128k",
+		"-ar", "48000",
+		"-ac", "2",
+		"-f", "flv",
+		rtmpURL,
+	), nil
+}
+
+// FfmpegTestPatternCommand generates a test pattern with a sine tone, fully
+// deterministic so it doubles as a calibration and integration test source.
+func FfmpegTestPatternCommand(rtmpURL string, pattern playlist.TestPatternElement) ([]string, error) {
+	name := pattern.Pattern
+	if name == "" {
+		name = "smptebars"
+	}
+	tone := pattern.ToneHz
+	if tone <= 0 {
+		tone = 1000
+	}
+	duration := pattern.DurationSeconds
+	if duration <= 0 {
+		duration = playlist.DefaultTestPatternSeconds
+	}
+
+	videoFilter := filtergraph.Chain(filtergraph.TestSource{Pattern: name, Width: 1280, Height: 720, Rate: 25})
+	if err := videoFilter.Validate(); err != nil {
+		return nil, fmt.Errorf("test pattern filter: %w", err)
+	}
+
+	return []string{
+		"-f", "lavfi",
+		"-t", strconv.Itoa(duration),
+		"-i", videoFilter.String(),
+		"-f", "lavfi",
+		"-t", strconv.Itoa(duration),
+		"-i", fmt.Sprintf("sine=frequency=%d:sample_rate=48000", tone),
+		"-c:v", "h264_v4l2m2m",
+		"-b:v", "1000k",
+		"-g", "50",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "64k",
+		"-ac", "2",
+		"-f", "flv",
+		rtmpURL,
+	}, nil
+}
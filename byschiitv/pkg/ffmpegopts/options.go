@@ -0,0 +1,34 @@
+package ffmpegopts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// ForceKeyframes is the -force_key_frames value putting a keyframe at each
+// of points.
+func ForceKeyframes(points []time.Duration) string {
+	times := make([]string, len(points))
+	for i, p := range points {
+		times[i] = fmt.Sprintf("%.3f", p.Seconds())
+	}
+	return strings.Join(times, ",")
+}
+
+// RemoteInputArgs are input options placed before -i so that a network blip on
+// an http source reconnects instead of ending the item.
+func RemoteInputArgs(path string) []string {
+	if !playlist.IsRemoteURL(path) {
+		return nil
+	}
+	return []string{
+		"-reconnect", "1",
+		"-reconnect_streamed", "1",
+		"-reconnect_on_network_error", "1",
+		"-reconnect_delay_max", "10",
+		"-rw_timeout", "15000000", // microseconds
+	}
+}
@@ -0,0 +1,72 @@
+package ffmpegopts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"byschiitv/pkg/filtergraph"
+)
+
+// ScreenStrings are the texts burnt into generated video: the intermission
+// card and its countdown, and the promo card.
+type ScreenStrings struct {
+	Intermission string `json:"intermission"`
+	ComingUpNext string `json:"coming_up_next"`
+	// StartingIn is the countdown line; {seconds} is replaced by the
+	// running count
+	StartingIn string `json:"starting_in"`
+	TonightOn  string `json:"tonight_on"`
+}
+
+// BuiltinStrings are the languages selectable with LANGUAGE.
+var BuiltinStrings = map[string]ScreenStrings{
+	"en": {
+		Intermission: " [||] INTERMISSION",
+		ComingUpNext: "COMING UP NEXT",
+		StartingIn:   "Starting in: {seconds} seconds",
+		TonightOn:    "TONIGHT",
+	},
+	"it": {
+		Intermission: " [||] INTERVALLO",
+		ComingUpNext: "TRA POCO",
+		StartingIn:   "Si riprende tra: {seconds} secondi",
+		TonightOn:    "STASERA",
+	},
+}
+
+// LoadScreenStrings starts from the built-in lang and overrides it with the
+// keys present in file, if any. An unknown lang falls back to English, a
+// broken file to the built-in strings.
+func LoadScreenStrings(lang, file string) (ScreenStrings, error) {
+	st, ok := BuiltinStrings[lang]
+	if !ok {
+		st = BuiltinStrings["en"]
+		if lang != "" {
+			return st, fmt.Errorf("unknown language %q", lang)
+		}
+	}
+	if file == "" {
+		return st, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return st, err
+	}
+	translated := st
+	if err := json.Unmarshal(data, &translated); err != nil {
+		return st, fmt.Errorf("%s: %w", file, err)
+	}
+	if !strings.Contains(translated.StartingIn, "{seconds}") {
+		return st, fmt.Errorf("%s: starting_in must contain {seconds}", file)
+	}
+	return translated, nil
+}
+
+// CountdownText renders StartingIn for drawtext with expansion on: the
+// translated text is escaped, {seconds} becomes a count down from seconds.
+func (st ScreenStrings) CountdownText(seconds int64) string {
+	before, after, _ := strings.Cut(st.StartingIn, "{seconds}")
+	return filtergraph.EscapeDrawtext(before) + fmt.Sprintf("%%{eif:%d-t:d}", seconds) + filtergraph.EscapeDrawtext(after)
+}
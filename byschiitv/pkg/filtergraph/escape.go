@@ -0,0 +1,50 @@
+package filtergraph
+
+import "strings"
+
+// escapeFFmpegText turns arbitrary text into a drawtext text= value, ready to
+// be placed unquoted inside a -vf/-filter_complex string.
+//
+// The text goes through three parsers inside ffmpeg, innermost first:
+//  1. drawtext expansion: '\' and '%' are special ("%{...}" sequences)
+//  2. the filter option parser: values are single-quoted
+//  3. the filtergraph parser: \ ' [ ] , ; are backslash-escaped
+//
+// Line breaks and tabs are flattened to spaces since banners are single line.
+func escapeFFmpegText(text string) string {
+	return escapeFiltergraph(quoteFilterValue(EscapeDrawtext(text)))
+}
+
+// EscapeDrawtext escapes text for drawtext's own expansion, for texts
+// passed on with Expand set.
+func EscapeDrawtext(text string) string {
+	replacer := strings.NewReplacer(
+		"\\", "\\\\",
+		"%", "\\%",
+		"\r\n", " ",
+		"\n", " ",
+		"\r", " ",
+		"\t", " ",
+	)
+	return replacer.Replace(text)
+}
+
+// quoteFilterValue quotes an option value so ':' and whitespace survive the
+// filter option parser. Quotes can't be nested, so a quote closes the quoted
+// run, is backslash-escaped and reopens it.
+func quoteFilterValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// escapeFiltergraph escapes the characters the filtergraph parser splits on.
+func escapeFiltergraph(value string) string {
+	replacer := strings.NewReplacer(
+		"\\", "\\\\",
+		"'", "\\'",
+		"[", "\\[",
+		"]", "\\]",
+		",", "\\,",
+		";", "\\;",
+	)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,94 @@
+package filtergraph
+
+import "testing"
+
+func TestEscapeFFmpegText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "hello", `\'hello\'`},
+		{"apostrophe", "it's", `\'it\'\\\'\'s\'`},
+		{"percent", "100%", `\'100\\%\'`},
+		{"semicolon", "a;b", `\'a\;b\'`},
+		// quoting is enough for the option parser
+		{"colon", "12:30", `\'12:30\'`},
+		{"comma", "a,b", `\'a\,b\'`},
+		{"brackets", "[live]", `\'\[live\]\'`},
+		{"backslash", `a\b`, `\'a\\\\b\'`},
+		{"newline", "a\nb", `\'a b\'`},
+		{"crlf", "a\r\nb", `\'a b\'`},
+		{"tab", "a\tb", `\'a b\'`},
+		{"padding", "  pad  ", `\'  pad  \'`},
+		{"expansion is literal", "%{localtime}", `\'\\%{localtime}\'`},
+		{"empty", "", `\'\'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeFFmpegText(tt.text); got != tt.want {
+				t.Errorf("escapeFFmpegText(%q) = %s, want %s", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteFilterValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"abc", `'abc'`},
+		{"a:b c", `'a:b c'`},
+		{"it's", `'it'\''s'`},
+		{"''", `''\'''\'''`},
+	}
+	for _, tt := range tests {
+		if got := quoteFilterValue(tt.value); got != tt.want {
+			t.Errorf("quoteFilterValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeFiltergraph(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"abc", "abc"},
+		{`\`, `\\`},
+		{"'", `\'`},
+		{"[a]", `\[a\]`},
+		{"a,b;c", `a\,b\;c`},
+		// only the filtergraph separators
+		{"a:b=c %", "a:b=c %"},
+	}
+	for _, tt := range tests {
+		if got := escapeFiltergraph(tt.value); got != tt.want {
+			t.Errorf("escapeFiltergraph(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDrawTextExpand(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		expand bool
+		want   string
+	}{
+		{"expand off", "%{localtime}", false, `drawtext=text=\'\\%{localtime}\'`},
+		{"expand on", "%{localtime}", true, `drawtext=text=\'%{localtime}\'`},
+		{"expand on keeps escapes", `%{pts\:hms}`, true, `drawtext=text=\'%{pts\\:hms}\'`},
+		{"expand on quotes", "Now: %{localtime\\:%H}", true, `drawtext=text=\'Now: %{localtime\\:%H}\'`},
+		{"expand off apostrophe", "it's 9%", false, `drawtext=text=\'it\'\\\'\'s 9\\%\'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DrawText{Text: tt.text, Expand: tt.expand}.String()
+			if got != tt.want {
+				t.Errorf("DrawText{%q, Expand: %v} = %s, want %s", tt.text, tt.expand, got, tt.want)
+			}
+		})
+	}
+}
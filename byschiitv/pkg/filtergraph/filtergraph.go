@@ -0,0 +1,439 @@
+// Package filtergraph builds ffmpeg filter chains and graphs, escaping the
+// values for the filtergraph parser.
+package filtergraph
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter is a single node of an ffmpeg filter chain.
+type Filter interface {
+	// String renders the node as "name=key=value:...", escaped for use
+	// inside a filtergraph.
+	String() string
+	Validate() error
+}
+
+// FilterChain is a comma separated sequence of filters. Inputs and Outputs are
+// optional pad labels, only needed inside a FilterGraph.
+type FilterChain struct {
+	Inputs  []string
+	Filters []Filter
+	Outputs []string
+}
+
+// Chain builds an unlabeled chain, the usual -vf case.
+func Chain(filters ...Filter) FilterChain {
+	return FilterChain{Filters: filters}
+}
+
+// Then appends filters to the chain.
+func (c FilterChain) Then(filters ...Filter) FilterChain {
+	c.Filters = append(c.Filters, filters...)
+	return c
+}
+
+func (c FilterChain) Validate() error {
+	if len(c.Filters) == 0 {
+		return errors.New("empty filter chain")
+	}
+	for i, f := range c.Filters {
+		if f == nil {
+			return fmt.Errorf("filter %d: nil filter", i)
+		}
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("filter %d: %w", i, err)
+		}
+	}
+	// labels may be stream specifiers like 0:v
+	for _, label := range append(append([]string{}, c.Inputs...), c.Outputs...) {
+		if label == "" || strings.ContainsAny(label, "[]=,;' ") {
+			return fmt.Errorf("invalid pad label %q", label)
+		}
+	}
+	return nil
+}
+
+func (c FilterChain) String() string {
+	var b strings.Builder
+	for _, in := range c.Inputs {
+		b.WriteString("[" + in + "]")
+	}
+	for i, f := range c.Filters {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(f.String())
+	}
+	for _, out := range c.Outputs {
+		b.WriteString("[" + out + "]")
+	}
+	return b.String()
+}
+
+// FilterGraph is a semicolon separated list of chains, for -filter_complex.
+type FilterGraph []FilterChain
+
+func (g FilterGraph) Validate() error {
+	if len(g) == 0 {
+		return errors.New("empty filter graph")
+	}
+	for i, c := range g {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("chain %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (g FilterGraph) String() string {
+	chains := make([]string, len(g))
+	for i, c := range g {
+		chains[i] = c.String()
+	}
+	return strings.Join(chains, ";")
+}
+
+// filterOption is a key=value pair of a filter node. Empty values are skipped.
+type filterOption struct {
+	key   string
+	value string
+	// raw values are already escaped for the filtergraph
+	raw bool
+}
+
+// renderFilter assembles "name=k=v:k=v", escaping values only when needed so
+// the common numeric case stays readable in logs.
+func renderFilter(name string, opts ...filterOption) string {
+	var parts []string
+	for _, o := range opts {
+		if o.value == "" {
+			continue
+		}
+		if o.raw {
+			parts = append(parts, o.key+"="+o.value)
+			continue
+		}
+		parts = append(parts, o.key+"="+filterValue(o.value))
+	}
+	if len(parts) == 0 {
+		return name
+	}
+	return name + "=" + strings.Join(parts, ":")
+}
+
+func filterValue(v string) string {
+	if strings.ContainsAny(v, "\\':[],; \t\n") {
+		return escapeFiltergraph(quoteFilterValue(v))
+	}
+	return v
+}
+
+func itoaNonZero(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// Scale resizes the video. -1/-2 keep the aspect ratio as in ffmpeg.
+// FitInside shrinks to fit the box keeping the aspect ratio, to be followed
+// by a Pad.
+type Scale struct {
+	Width     int
+	Height    int
+	FitInside bool
+}
+
+func (s Scale) Validate() error {
+	if s.Width == 0 || s.Width < -2 || s.Height == 0 || s.Height < -2 {
+		return fmt.Errorf("scale: invalid size %dx%d", s.Width, s.Height)
+	}
+	return nil
+}
+
+func (s Scale) String() string {
+	if s.FitInside {
+		return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", s.Width, s.Height)
+	}
+	return fmt.Sprintf("scale=%d:%d", s.Width, s.Height)
+}
+
+// Pad letterboxes the video into a Width x Height frame, centered.
+type Pad struct {
+	Width  int
+	Height int
+	Color  string
+}
+
+func (p Pad) Validate() error {
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("pad: invalid size %dx%d", p.Width, p.Height)
+	}
+	return nil
+}
+
+func (p Pad) String() string {
+	return renderFilter("pad",
+		filterOption{key: "w", value: strconv.Itoa(p.Width)},
+		filterOption{key: "h", value: strconv.Itoa(p.Height)},
+		filterOption{key: "x", value: "(ow-iw)/2"},
+		filterOption{key: "y", value: "(oh-ih)/2"},
+		filterOption{key: "color", value: p.Color},
+	)
+}
+
+// FPS forces a constant output frame rate.
+type FPS struct {
+	Rate int
+}
+
+func (f FPS) Validate() error {
+	if f.Rate <= 0 {
+		return fmt.Errorf("fps: invalid rate %d", f.Rate)
+	}
+	return nil
+}
+
+func (f FPS) String() string {
+	return fmt.Sprintf("fps=%d", f.Rate)
+}
+
+// Format converts the pixel format.
+type Format struct {
+	PixFmt string
+}
+
+func (f Format) Validate() error {
+	if f.PixFmt == "" {
+		return errors.New("format: missing pixel format")
+	}
+	return nil
+}
+
+func (f Format) String() string {
+	return renderFilter("format", filterOption{key: "pix_fmts", value: f.PixFmt})
+}
+
+// Color is the lavfi solid color source.
+type Color struct {
+	Width  int
+	Height int
+	Rate   int
+	Color  string
+}
+
+func (c Color) Validate() error {
+	if c.Width <= 0 || c.Height <= 0 {
+		return fmt.Errorf("color: invalid size %dx%d", c.Width, c.Height)
+	}
+	if c.Rate <= 0 {
+		return fmt.Errorf("color: invalid rate %d", c.Rate)
+	}
+	if c.Color == "" {
+		return errors.New("color: missing color")
+	}
+	return nil
+}
+
+func (c Color) String() string {
+	return renderFilter("color",
+		filterOption{key: "size", value: fmt.Sprintf("%dx%d", c.Width, c.Height)},
+		filterOption{key: "rate", value: strconv.Itoa(c.Rate)},
+		filterOption{key: "color", value: c.Color},
+	)
+}
+
+// TestSource is one of the lavfi test pattern sources.
+type TestSource struct {
+	// Pattern is smptebars, smptehdbars, testsrc, testsrc2 or pal75bars
+	Pattern string
+	Width   int
+	Height  int
+	Rate    int
+}
+
+func (t TestSource) Validate() error {
+	switch t.Pattern {
+	case "smptebars", "smptehdbars", "testsrc", "testsrc2", "pal75bars":
+	default:
+		return fmt.Errorf("test source: unknown pattern %q", t.Pattern)
+	}
+	if t.Width <= 0 || t.Height <= 0 {
+		return fmt.Errorf("test source: invalid size %dx%d", t.Width, t.Height)
+	}
+	if t.Rate <= 0 {
+		return fmt.Errorf("test source: invalid rate %d", t.Rate)
+	}
+	return nil
+}
+
+func (t TestSource) String() string {
+	return renderFilter(t.Pattern,
+		filterOption{key: "size", value: fmt.Sprintf("%dx%d", t.Width, t.Height)},
+		filterOption{key: "rate", value: strconv.Itoa(t.Rate)},
+	)
+}
+
+// DrawText renders text on the video. Text is literal unless Expand is set, in
+// which case it is passed to drawtext as is so %{...} sequences are expanded.
+// X, Y, Alpha and Enable are ffmpeg expressions, written unescaped.
+type DrawText struct {
+	Text       string
+	Expand     bool
+	FontFile   string
+	FontSize   int
+	FontColor  string
+	X          string
+	Y          string
+	Alpha      string
+	Enable     string
+	Box        bool
+	BoxColor   string
+	BoxBorderW int
+}
+
+func (d DrawText) Validate() error {
+	if d.Text == "" {
+		return errors.New("drawtext: empty text")
+	}
+	if d.FontSize < 0 {
+		return fmt.Errorf("drawtext: invalid font size %d", d.FontSize)
+	}
+	if d.BoxBorderW < 0 {
+		return fmt.Errorf("drawtext: invalid box border %d", d.BoxBorderW)
+	}
+	return nil
+}
+
+func (d DrawText) String() string {
+	// always quoted, even when plain, so padding survives
+	text := escapeFFmpegText(d.Text)
+	if d.Expand {
+		text = escapeFiltergraph(quoteFilterValue(d.Text))
+	}
+	box := ""
+	if d.Box {
+		box = "1"
+	}
+	return renderFilter("drawtext",
+		filterOption{key: "text", value: text, raw: true},
+		filterOption{key: "fontfile", value: d.FontFile},
+		filterOption{key: "fontsize", value: itoaNonZero(d.FontSize)},
+		filterOption{key: "fontcolor", value: d.FontColor},
+		filterOption{key: "x", value: d.X},
+		filterOption{key: "y", value: d.Y},
+		filterOption{key: "alpha", value: d.Alpha},
+		filterOption{key: "enable", value: d.Enable},
+		filterOption{key: "box", value: box},
+		filterOption{key: "boxcolor", value: d.BoxColor},
+		filterOption{key: "boxborderw", value: itoaNonZero(d.BoxBorderW)},
+	)
+}
+
+// Overlay composites its second input on top of the first.
+type Overlay struct {
+	X        string
+	Y        string
+	Shortest bool
+}
+
+func (o Overlay) Validate() error {
+	return nil
+}
+
+func (o Overlay) String() string {
+	shortest := ""
+	if o.Shortest {
+		shortest = "1"
+	}
+	return renderFilter("overlay",
+		filterOption{key: "x", value: o.X},
+		filterOption{key: "y", value: o.Y},
+		filterOption{key: "shortest", value: shortest},
+	)
+}
+
+// ShowWaves draws the audio waveform as video.
+type ShowWaves struct {
+	Width  int
+	Height int
+	Rate   int
+	// Mode is point, line, p2p or cline
+	Mode   string
+	Colors string
+}
+
+func (w ShowWaves) Validate() error {
+	if w.Width <= 0 || w.Height <= 0 {
+		return fmt.Errorf("showwaves: invalid size %dx%d", w.Width, w.Height)
+	}
+	switch w.Mode {
+	case "", "point", "line", "p2p", "cline":
+	default:
+		return fmt.Errorf("showwaves: invalid mode %q", w.Mode)
+	}
+	return nil
+}
+
+func (w ShowWaves) String() string {
+	return renderFilter("showwaves",
+		filterOption{key: "s", value: fmt.Sprintf("%dx%d", w.Width, w.Height)},
+		filterOption{key: "rate", value: itoaNonZero(w.Rate)},
+		filterOption{key: "mode", value: w.Mode},
+		filterOption{key: "colors", value: w.Colors},
+	)
+}
+
+// ShowSpectrum draws a scrolling audio spectrum as video.
+type ShowSpectrum struct {
+	Width  int
+	Height int
+	// Color is the color scheme, e.g. intensity, rainbow, fire
+	Color string
+}
+
+func (sp ShowSpectrum) Validate() error {
+	if sp.Width <= 0 || sp.Height <= 0 {
+		return fmt.Errorf("showspectrum: invalid size %dx%d", sp.Width, sp.Height)
+	}
+	return nil
+}
+
+func (sp ShowSpectrum) String() string {
+	return renderFilter("showspectrum",
+		filterOption{key: "s", value: fmt.Sprintf("%dx%d", sp.Width, sp.Height)},
+		filterOption{key: "slide", value: "scroll"},
+		filterOption{key: "color", value: sp.Color},
+	)
+}
+
+// XStack tiles its Inputs video inputs into one frame. Layout places each
+// input as "x_y", separated by |, see ffmpeg's xstack.
+type XStack struct {
+	Inputs int
+	Layout string
+	// Fill paints the cells no input covers
+	Fill string
+}
+
+func (x XStack) Validate() error {
+	if x.Inputs < 2 {
+		return fmt.Errorf("xstack: needs at least 2 inputs, got %d", x.Inputs)
+	}
+	if n := strings.Count(x.Layout, "|") + 1; n != x.Inputs {
+		return fmt.Errorf("xstack: layout has %d cells for %d inputs", n, x.Inputs)
+	}
+	return nil
+}
+
+func (x XStack) String() string {
+	return renderFilter("xstack",
+		filterOption{key: "inputs", value: strconv.Itoa(x.Inputs)},
+		filterOption{key: "layout", value: x.Layout},
+		filterOption{key: "fill", value: x.Fill},
+	)
+}
@@ -0,0 +1,151 @@
+package filtergraph
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterChainString(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain FilterChain
+		want  string
+	}{
+		{"unlabeled", Chain(Scale{Width: 1280, Height: 720}, FPS{Rate: 25}), "scale=1280:720,fps=25"},
+		{
+			"labeled",
+			FilterChain{
+				Inputs:  []string{"0:v"},
+				Filters: []Filter{Format{PixFmt: "yuv420p"}},
+				Outputs: []string{"base"},
+			},
+			"[0:v]format=pix_fmts=yuv420p[base]",
+		},
+		{
+			"two inputs",
+			FilterChain{
+				Inputs:  []string{"base", "logo"},
+				Filters: []Filter{Overlay{X: "W-w-24", Y: "24", Shortest: true}},
+				Outputs: []string{"v"},
+			},
+			"[base][logo]overlay=x=W-w-24:y=24:shortest=1[v]",
+		},
+		{"then", Chain(FPS{Rate: 25}).Then(Format{PixFmt: "yuv420p"}), "fps=25,format=pix_fmts=yuv420p"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.chain.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if got := tt.chain.String(); got != tt.want {
+				t.Errorf("String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterGraphString(t *testing.T) {
+	graph := FilterGraph{
+		{Inputs: []string{"0:v"}, Filters: []Filter{Scale{Width: 160, Height: -1}}, Outputs: []string{"logo"}},
+		{Inputs: []string{"1:v", "logo"}, Filters: []Filter{Overlay{X: "24", Y: "24"}}, Outputs: []string{"v"}},
+	}
+	if err := graph.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	want := "[0:v]scale=160:-1[logo];[1:v][logo]overlay=x=24:y=24[v]"
+	if got := graph.String(); got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
+
+func TestRenderFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []filterOption
+		want string
+	}{
+		{"no options", nil, "f"},
+		{"all empty", []filterOption{{key: "a"}, {key: "b"}}, "f"},
+		{"skips empty", []filterOption{{key: "a", value: "1"}, {key: "b"}, {key: "c", value: "3"}}, "f=a=1:c=3"},
+		{"plain values unquoted", []filterOption{{key: "color", value: "#0f0f1e"}, {key: "x", value: "(w-tw)/2"}}, "f=color=#0f0f1e:x=(w-tw)/2"},
+		{"colon quoted", []filterOption{{key: "t", value: "12:30"}}, `f=t=\'12:30\'`},
+		{"space quoted", []filterOption{{key: "t", value: "a b"}}, `f=t=\'a b\'`},
+		{"comma quoted", []filterOption{{key: "t", value: "a,b"}}, `f=t=\'a\,b\'`},
+		{"raw as is", []filterOption{{key: "t", value: `\'x\'`, raw: true}}, `f=t=\'x\'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderFilter("f", tt.opts...); got != tt.want {
+				t.Errorf("renderFilter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterValidateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  interface{ Validate() error }
+		wantErr string
+	}{
+		{"empty chain", FilterChain{}, "empty filter chain"},
+		{"nil filter", Chain(FPS{Rate: 25}, nil), "filter 1: nil filter"},
+		{"invalid filter in chain", Chain(FPS{}), "filter 0: fps: invalid rate 0"},
+		{"empty pad label", FilterChain{Inputs: []string{""}, Filters: []Filter{FPS{Rate: 25}}}, `invalid pad label ""`},
+		{"bracket in pad label", FilterChain{Outputs: []string{"v]"}, Filters: []Filter{FPS{Rate: 25}}}, `invalid pad label "v]"`},
+		{"space in pad label", FilterChain{Inputs: []string{"a b"}, Filters: []Filter{FPS{Rate: 25}}}, `invalid pad label "a b"`},
+		{"empty graph", FilterGraph{}, "empty filter graph"},
+		{"invalid chain in graph", FilterGraph{Chain(FPS{Rate: 25}), FilterChain{}}, "chain 1: empty filter chain"},
+		{"scale zero width", Scale{Width: 0, Height: 720}, "scale: invalid size 0x720"},
+		{"scale negative height", Scale{Width: 1280, Height: -3}, "scale: invalid size 1280x-3"},
+		{"pad zero", Pad{Width: 0, Height: 720}, "pad: invalid size 0x720"},
+		{"pad negative", Pad{Width: 1280, Height: -1}, "pad: invalid size 1280x-1"},
+		{"fps", FPS{Rate: -1}, "fps: invalid rate -1"},
+		{"format", Format{}, "format: missing pixel format"},
+		{"color size", Color{Height: 720, Rate: 25, Color: "black"}, "color: invalid size 0x720"},
+		{"color rate", Color{Width: 1280, Height: 720, Color: "black"}, "color: invalid rate 0"},
+		{"color missing", Color{Width: 1280, Height: 720, Rate: 25}, "color: missing color"},
+		{"test source pattern", TestSource{Pattern: "plaid", Width: 1, Height: 1, Rate: 1}, `test source: unknown pattern "plaid"`},
+		{"test source size", TestSource{Pattern: "testsrc", Height: 1, Rate: 1}, "test source: invalid size 0x1"},
+		{"test source rate", TestSource{Pattern: "testsrc", Width: 1, Height: 1}, "test source: invalid rate 0"},
+		{"drawtext empty", DrawText{}, "drawtext: empty text"},
+		{"drawtext font size", DrawText{Text: "x", FontSize: -1}, "drawtext: invalid font size -1"},
+		{"drawtext box border", DrawText{Text: "x", BoxBorderW: -1}, "drawtext: invalid box border -1"},
+		{"showwaves size", ShowWaves{Width: 0, Height: 1}, "showwaves: invalid size 0x1"},
+		{"showwaves mode", ShowWaves{Width: 1, Height: 1, Mode: "bars"}, `showwaves: invalid mode "bars"`},
+		{"showspectrum size", ShowSpectrum{Width: 1}, "showspectrum: invalid size 1x0"},
+		{"xstack one input", XStack{Inputs: 1, Layout: "0_0"}, "xstack: needs at least 2 inputs, got 1"},
+		{"xstack layout mismatch", XStack{Inputs: 3, Layout: "0_0|w0_0"}, "xstack: layout has 2 cells for 3 inputs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterValidateOK(t *testing.T) {
+	filters := []Filter{
+		Scale{Width: -2, Height: 720},
+		Scale{Width: 320, Height: 180, FitInside: true},
+		Pad{Width: 320, Height: 180},
+		Color{Width: 1, Height: 1, Rate: 1, Color: "black"},
+		TestSource{Pattern: "smptebars", Width: 1, Height: 1, Rate: 1},
+		DrawText{Text: "x"},
+		Overlay{},
+		ShowWaves{Width: 1, Height: 1},
+		ShowSpectrum{Width: 1, Height: 1},
+		XStack{Inputs: 2, Layout: "0_0|w0_0"},
+	}
+	for _, f := range filters {
+		if err := f.Validate(); err != nil {
+			t.Errorf("%T.Validate() = %v", f, err)
+		}
+	}
+}
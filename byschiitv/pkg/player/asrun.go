@@ -0,0 +1,170 @@
+package player
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AsRunDir holds one as-run file per day, empty for none. Overridden from
+// AS_RUN_DIR in main.
+var AsRunDir = "asrun"
+
+// AsRunKeepDays is how many days of as-run files are kept (0 = all).
+// Overridden from AS_RUN_KEEP_DAYS in main.
+var AsRunKeepDays = 90
+
+// asRunDate is the layout of as-run dates, in file names and /history.
+const asRunDate = "2006-01-02"
+
+// AsRunEntry is one item that went on air, as it actually aired.
+type AsRunEntry struct {
+	ID    string    `json:"id"`
+	Type  string    `json:"type"`
+	Title string    `json:"title"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Status is ok, failed, skipped, timeout or stopped
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// PlannedStart is the item's start_at, if it had one
+	PlannedStart *time.Time `json:"planned_start,omitempty"`
+}
+
+// asRunLog appends aired items to the file of the day they started, each
+// line a JSON AsRunEntry.
+type asRunLog struct {
+	mu sync.Mutex
+	// pruned is the last day old files were removed
+	pruned string
+}
+
+var asRun = &asRunLog{}
+
+func asRunFile(day string) string {
+	return filepath.Join(AsRunDir, "asrun-"+day+".jsonl")
+}
+
+// record appends e to its day's file, removing files past asRunKeepDays
+// once a day.
+func (l *asRunLog) record(e AsRunEntry) {
+	if AsRunDir == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	day := e.Start.Format(asRunDate)
+	if err := os.MkdirAll(AsRunDir, 0o755); err != nil {
+		log.Printf("as-run: %v", err)
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("as-run: %v", err)
+		return
+	}
+	f, err := os.OpenFile(asRunFile(day), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("as-run: %v", err)
+		return
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("as-run: %v", err)
+	}
+	if l.pruned != day {
+		l.pruned = day
+		pruneAsRun(e.Start)
+	}
+}
+
+// pruneAsRun removes the files more than asRunKeepDays before now.
+func pruneAsRun(now time.Time) {
+	if AsRunKeepDays <= 0 {
+		return
+	}
+	oldest := now.AddDate(0, 0, -AsRunKeepDays).Format(asRunDate)
+	files, _ := filepath.Glob(filepath.Join(AsRunDir, "asrun-*.jsonl"))
+	for _, file := range files {
+		day := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "asrun-"), ".jsonl")
+		if day < oldest {
+			if err := os.Remove(file); err != nil {
+				log.Printf("as-run: %v", err)
+			}
+		}
+	}
+}
+
+// ParseAsRunDay checks a /history/asrun date: YYYY-MM-DD or "today".
+func ParseAsRunDay(day string) (string, error) {
+	if day == "today" {
+		return time.Now().Format(asRunDate), nil
+	}
+	if _, err := time.ParseInLocation(asRunDate, day, time.Local); err != nil {
+		return "", fmt.Errorf("date must be YYYY-MM-DD or today")
+	}
+	return day, nil
+}
+
+// ReadAsRun returns the entries of a day, in airing order, and
+// os.ErrNotExist when nothing aired that day.
+func ReadAsRun(day string) ([]AsRunEntry, error) {
+	if AsRunDir == "" {
+		return nil, errors.New("as-run log disabled")
+	}
+	f, err := os.Open(asRunFile(day))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []AsRunEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e AsRunEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// a line cut short by a crash
+			log.Printf("as-run %s: skipping line: %v", day, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// WriteAsRunCSV writes entries as CSV with a header row.
+func WriteAsRunCSV(w io.Writer, entries []AsRunEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "type", "title", "start", "end", "duration_seconds", "status", "reason", "planned_start"})
+	for _, e := range entries {
+		planned := ""
+		if e.PlannedStart != nil {
+			planned = e.PlannedStart.Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			e.ID,
+			e.Type,
+			e.Title,
+			e.Start.Format(time.RFC3339),
+			e.End.Format(time.RFC3339),
+			strconv.FormatFloat(e.End.Sub(e.Start).Seconds(), 'f', 1, 64),
+			e.Status,
+			e.Reason,
+			planned,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
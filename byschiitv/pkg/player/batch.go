@@ -0,0 +1,147 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"byschiitv/pkg/playlist"
+)
+
+// BatchOp is one step of a POST /playlist/batch:
+//
+//	{"op": "insert", "index": 2, "item": {...}}   (index omitted = append)
+//	{"op": "remove", "id": "..."}
+//	{"op": "move", "id": "...", "index": 0}
+//	{"op": "update", "id": "...", "item": {...fields to change}}
+//
+// Steps address items by entry ID, or by "index" for insert.
+type BatchOp struct {
+	Op    string                 `json:"op"`
+	ID    string                 `json:"id,omitempty"`
+	Index *int                   `json:"index,omitempty"`
+	Item  map[string]interface{} `json:"item,omitempty"`
+
+	// parsed is the element of an insert, parsed before taking the lock
+	parsed playlist.PlaylistElement
+}
+
+// Batch applies ops in order to a copy of the playlist and swaps it in only
+// if every step succeeds, under a single lock. The item on air keeps
+// playing unless a step removes it. It returns the new playlist length.
+func (s *Server) Batch(ctx context.Context, ops []BatchOp) (int, error) {
+	// inserted items may probe remote sources: do it before locking
+	var inserts []playlist.PlaylistElement
+	for i := range ops {
+		if ops[i].Op != "insert" {
+			continue
+		}
+		element, ok := playlist.ParseElement(ops[i].Item)
+		if !ok {
+			return 0, fmt.Errorf("step %d: unknown item type %v", i, ops[i].Item["type"])
+		}
+		ops[i].parsed = element
+		inserts = append(inserts, element)
+	}
+	if err := checkRemoteElements(ctx, inserts); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	playlist := slices.Clone(s.playlist)
+	for i, op := range ops {
+		var err error
+		if playlist, err = applyBatchOp(playlist, op); err != nil {
+			return 0, fmt.Errorf("step %d (%s): %w", i, op.Op, err)
+		}
+	}
+
+	// follow the current item to its new place
+	if s.currentlyPlaying >= 0 && s.currentlyPlaying < len(s.playlist) {
+		id := s.playlist[s.currentlyPlaying].EntryID()
+		old := s.currentlyPlaying
+		s.playlist = playlist
+		if i := s.indexOfID(id); i >= 0 {
+			s.currentlyPlaying = i
+		} else if s.onAir() {
+			// removed while airing: go on with what now sits in its place
+			s.jumpTo = min(old, len(playlist))
+			s.currentCancel()
+		} else {
+			s.currentlyPlaying = min(old, len(playlist))
+		}
+	} else {
+		s.playlist = playlist
+	}
+	s.changed()
+	return len(s.playlist), nil
+}
+
+// applyBatchOp applies one step to elements, which the caller owns.
+func applyBatchOp(elements []playlist.PlaylistElement, op BatchOp) ([]playlist.PlaylistElement, error) {
+	find := func() (int, error) {
+		if op.ID == "" {
+			return 0, fmt.Errorf("missing id")
+		}
+		for i, element := range elements {
+			if element.EntryID() == op.ID {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no item with id %s", op.ID)
+	}
+	switch op.Op {
+	case "insert":
+		if slices.ContainsFunc(elements, func(e playlist.PlaylistElement) bool { return e.EntryID() == op.parsed.EntryID() }) {
+			return nil, ErrDuplicateID
+		}
+		index := len(elements)
+		if op.Index != nil {
+			index = *op.Index
+		}
+		if index < 0 || index > len(elements) {
+			return nil, fmt.Errorf("index %d out of range", index)
+		}
+		return slices.Insert(elements, index, op.parsed), nil
+	case "remove":
+		i, err := find()
+		if err != nil {
+			return nil, err
+		}
+		return slices.Delete(elements, i, i+1), nil
+	case "move":
+		i, err := find()
+		if err != nil {
+			return nil, err
+		}
+		if op.Index == nil || *op.Index < 0 || *op.Index >= len(elements) {
+			return nil, fmt.Errorf("move needs an index within the playlist")
+		}
+		element := elements[i]
+		elements = slices.Delete(elements, i, i+1)
+		return slices.Insert(elements, *op.Index, element), nil
+	case "update":
+		i, err := find()
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := playlist.EncodeElements(elements[i : i+1])
+		if err != nil {
+			return nil, err
+		}
+		item := encoded[0]
+		for k, v := range op.Item {
+			item[k] = v
+		}
+		item["id"] = op.ID
+		element, ok := playlist.ParseElement(item)
+		if !ok {
+			return nil, fmt.Errorf("unknown item type %v", item["type"])
+		}
+		elements[i] = element
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+}
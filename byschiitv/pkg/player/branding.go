@@ -0,0 +1,138 @@
+package player
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"byschiitv/pkg/ffmpegopts"
+	"byschiitv/pkg/playlist"
+)
+
+// defaultBranding is the look the channel always had. Configured packages
+// start from it, so they only list what they change.
+var defaultBranding = ffmpegopts.Branding{
+	Name:         "default",
+	LogoPosition: "top-right",
+	LogoWidth:    160,
+	LowerThird:   ffmpegopts.LowerThird{FontSize: 24, FontColor: "white"},
+	Palette: ffmpegopts.Palette{
+		Background: "#0f0f1e",
+		Primary:    "#ff6b6b",
+		Secondary:  "#00d4ff",
+		Text:       "white",
+		Muted:      "#cccccc",
+		Accent:     "#4ecdc4",
+	},
+}
+
+// Brandings holds the packages and which one is on air. Switching applies
+// from the next item, since ffmpeg gets its overlays when it starts.
+type Brandings struct {
+	mu       sync.RWMutex
+	packages []ffmpegopts.Branding
+	active   int
+}
+
+// ChannelBranding is the channel look, set in main from BRANDING_FILE.
+var ChannelBranding = &Brandings{packages: []ffmpegopts.Branding{defaultBranding}}
+
+// ScreenText is the language in use, set in main from LANGUAGE and
+// STRINGS_FILE.
+var ScreenText = ffmpegopts.BuiltinStrings["en"]
+
+// activeBranding returns the package in use.
+func activeBranding() ffmpegopts.Branding {
+	ChannelBranding.mu.RLock()
+	defer ChannelBranding.mu.RUnlock()
+	return ChannelBranding.packages[ChannelBranding.active]
+}
+
+// Load replaces the packages with the ones in file, BRANDING_FILE, keeping
+// "default" available:
+//
+//	{"active": "night", "packages": [{"name": "night", "logo": "/media/logo.png", ...}]}
+func (b *Brandings) Load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config struct {
+		Active   string            `json:"active"`
+		Packages []json.RawMessage `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	packages := []ffmpegopts.Branding{defaultBranding}
+	for i, raw := range config.Packages {
+		pkg := defaultBranding
+		pkg.Name = ""
+		if err := json.Unmarshal(raw, &pkg); err != nil {
+			return fmt.Errorf("%s: package %d: %w", file, i, err)
+		}
+		if pkg.Name == "" {
+			return fmt.Errorf("%s: package %d has no name", file, i)
+		}
+		if err := pkg.Validate(); err != nil {
+			return fmt.Errorf("%s: %s: %w", file, pkg.Name, err)
+		}
+		if i := slices.IndexFunc(packages, func(p ffmpegopts.Branding) bool { return p.Name == pkg.Name }); i >= 0 {
+			packages[i] = pkg
+		} else {
+			packages = append(packages, pkg)
+		}
+	}
+	active := 0
+	if config.Active != "" {
+		active = slices.IndexFunc(packages, func(p ffmpegopts.Branding) bool { return p.Name == config.Active })
+		if active < 0 {
+			return fmt.Errorf("%s: no package named %q", file, config.Active)
+		}
+	}
+	b.mu.Lock()
+	b.packages, b.active = packages, active
+	b.mu.Unlock()
+	return nil
+}
+
+// Use switches to the package called name.
+func (b *Brandings) Use(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := slices.IndexFunc(b.packages, func(p ffmpegopts.Branding) bool { return p.Name == name })
+	if i < 0 {
+		return fmt.Errorf("no branding package named %q", name)
+	}
+	b.active = i
+	return nil
+}
+
+// List returns the packages and the name of the active one.
+func (b *Brandings) List() ([]ffmpegopts.Branding, string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return slices.Clone(b.packages), b.packages[b.active].Name
+}
+
+// resolveIdent returns the active package's ident as a plain video element.
+func resolveIdent(i playlist.IdentElement) (playlist.VideoElement, error) {
+	brand := activeBranding()
+	if brand.Ident == "" {
+		return playlist.VideoElement{}, fmt.Errorf("branding %q has no ident", brand.Name)
+	}
+	return playlist.VideoElement{Metadata: i.Metadata, Path: brand.Ident, QualityIndex: i.QualityIndex}, nil
+}
+
+// identDuration is how long the active ident airs.
+func identDuration(ctx context.Context) (time.Duration, error) {
+	brand := activeBranding()
+	if brand.Ident == "" {
+		return 0, fmt.Errorf("branding %q has no ident", brand.Name)
+	}
+	return GetVideoDuration(ctx, brand.Ident)
+}
@@ -0,0 +1,142 @@
+package player
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ntpServer, when set, is asked for the time to measure the clock offset.
+// Overridden from NTP_SERVER in main, e.g. "pool.ntp.org".
+var NTPServer = ""
+
+// ClockMaxOffset is how far off the clock may be before schedules are
+// flagged as unreliable. Overridden from CLOCK_MAX_OFFSET_SECONDS in main.
+var ClockMaxOffset = 2 * time.Second
+
+// clockCheckInterval is how often the clock is checked again.
+const clockCheckInterval = 15 * time.Minute
+
+// saneSince is the earliest believable time: a Pi without a working RTC
+// boots in 1970, or at the last time fake-hwclock saved.
+var saneSince = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ntpEpochOffset is the number of seconds from 1900 to the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// ClockStatus is how much the system clock can be trusted for start_at.
+type ClockStatus struct {
+	OK      bool      `json:"ok"`
+	Checked time.Time `json:"checked,omitempty"`
+	// Source is "ntp" when the offset was measured, "local" otherwise
+	Source        string  `json:"source,omitempty"`
+	OffsetSeconds float64 `json:"offset_seconds"`
+	Warning       string  `json:"warning,omitempty"`
+}
+
+// clockCheck holds the last clock check.
+type clockCheck struct {
+	mu     sync.Mutex
+	status ClockStatus
+}
+
+// wallClock is the clock check shared by the player and /status.
+var wallClock = &clockCheck{status: ClockStatus{OK: true}}
+
+func (c *clockCheck) Status() ClockStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// check looks at the local clock, and at ntpServer if set, and logs a
+// warning when it is off by more than clockMaxOffset.
+func (c *clockCheck) check(ctx context.Context) ClockStatus {
+	now := time.Now()
+	st := ClockStatus{OK: true, Checked: now, Source: "local"}
+	if now.Before(saneSince) {
+		st.OK = false
+		st.Warning = fmt.Sprintf("system clock says %s: no RTC and no time sync?", now.Format(time.RFC3339))
+	}
+	if NTPServer != "" {
+		offset, err := ntpOffset(ctx, NTPServer)
+		switch {
+		case err != nil:
+			if st.OK {
+				st.Warning = fmt.Sprintf("could not ask %s for the time: %v", NTPServer, err)
+			}
+		default:
+			st.Source = "ntp"
+			st.OffsetSeconds = offset.Seconds()
+			if offset.Abs() > ClockMaxOffset {
+				st.OK = false
+				st.Warning = fmt.Sprintf("system clock is off by %s according to %s", offset.Round(time.Millisecond), NTPServer)
+			}
+		}
+	}
+	if !st.OK {
+		log.Printf("WARNING clock: %s; scheduled start times will be wrong", st.Warning)
+	} else if st.Warning != "" {
+		log.Printf("clock: %s", st.Warning)
+	}
+	c.mu.Lock()
+	c.status = st
+	c.mu.Unlock()
+	return st
+}
+
+// run checks the clock now and every clockCheckInterval until ctx is done.
+func (c *clockCheck) run(ctx context.Context) {
+	c.check(ctx)
+	for sleepCtx(ctx, clockCheckInterval) {
+		c.check(ctx)
+	}
+}
+
+// ntpOffset asks an SNTP server how far the local clock is behind it.
+// A positive offset means the local clock is slow.
+func ntpOffset(ctx context.Context, server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x1b // version 3, client mode
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	if _, err := conn.Read(resp); err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if resp[0]&0x07 != 4 {
+		return 0, fmt.Errorf("not a server reply")
+	}
+	serverReceived := ntpTime(resp[32:40])
+	serverSent := ntpTime(resp[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes a 64 bit NTP timestamp.
+func ntpTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:]))
+	return time.Unix(secs, frac*1e9>>32)
+}
@@ -0,0 +1,58 @@
+package player
+
+import (
+	"slices"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// maxCueEvents bounds the cue history, oldest dropped first.
+const maxCueEvents = 100
+
+// CueEvent is a cue-out or cue-in at a wall clock time, so downstream
+// tooling can splice the channel at the break boundaries. The output is
+// FLV over RTMP, which has no room for SCTE-35, so cues are published by
+// GET /cues and the player forces a keyframe at each of them.
+type CueEvent struct {
+	Type string    `json:"type"`
+	ID   string    `json:"id"`
+	Item string    `json:"item"`
+	Time time.Time `json:"time"`
+	// Duration is the break length, on cue-out events
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// scheduleCues records the cue events of an element that started airing
+// at started, join into its runtime. Callers hold s.mu.
+func (s *Server) scheduleCues(element playlist.PlaylistElement, started time.Time, join time.Duration) {
+	for _, b := range element.Sched().Breaks {
+		if playlist.Seconds(b.At) < join {
+			continue
+		}
+		out := started.Add(playlist.Seconds(b.At) - join)
+		s.cues = append(s.cues,
+			CueEvent{Type: "cue-out", ID: element.EntryID(), Item: element.Desc(), Time: out, Duration: b.Duration},
+			CueEvent{Type: "cue-in", ID: element.EntryID(), Item: element.Desc(), Time: out.Add(playlist.Seconds(b.Duration))},
+		)
+	}
+	if len(s.cues) > maxCueEvents {
+		s.cues = s.cues[len(s.cues)-maxCueEvents:]
+	}
+}
+
+// dropCues forgets the cues of the element with the given ID that have not
+// happened yet, when it stops early. Callers hold s.mu.
+func (s *Server) dropCues(id string) {
+	now := time.Now()
+	s.cues = slices.DeleteFunc(s.cues, func(c CueEvent) bool {
+		return c.ID == id && c.Time.After(now)
+	})
+}
+
+// Cues returns the recent and upcoming cue events, oldest first.
+func (s *Server) Cues() []CueEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.cues)
+}
@@ -0,0 +1,96 @@
+package player
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// DeadLetterAfter is how many failed or skipped airings in a row move an
+// item out of the playlist into the dead letter list.
+// Overridden from DEAD_LETTER_AFTER in main.
+var DeadLetterAfter = 3
+
+// maxDeadItems bounds the dead letter list, oldest dropped first.
+const maxDeadItems = 200
+
+// DeadItem is a playlist element taken out after repeated failures, kept
+// for inspection and retry.
+type DeadItem struct {
+	ID      string                   `json:"id"`
+	Type    string                   `json:"type"`
+	Item    playlist.PlaylistElement `json:"item"`
+	Reason  string                   `json:"reason"`
+	Strikes int                      `json:"strikes"`
+	Time    time.Time                `json:"time"`
+}
+
+// recordOutcome counts a failed or skipped airing of element against it, and
+// dead-letters it once it reaches deadLetterAfter. A clean airing clears the
+// count. reason is empty on success.
+func (s *Server) recordOutcome(element playlist.PlaylistElement, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := element.EntryID()
+	if reason == "" {
+		delete(s.strikes, id)
+		return
+	}
+	if s.strikes == nil {
+		s.strikes = map[string]int{}
+	}
+	s.strikes[id]++
+	if DeadLetterAfter <= 0 || s.strikes[id] < DeadLetterAfter {
+		return
+	}
+	strikes := s.strikes[id]
+	delete(s.strikes, id)
+	idx := s.indexOfID(id)
+	if idx < 0 {
+		return
+	}
+	s.playlist = slices.Delete(s.playlist, idx, idx+1)
+	if idx < s.currentlyPlaying {
+		s.currentlyPlaying--
+	}
+	if s.currentlyPlaying >= len(s.playlist) && s.loop {
+		s.currentlyPlaying = 0
+	}
+	s.dead = append(s.dead, DeadItem{
+		ID:      id,
+		Type:    element.Type(),
+		Item:    element,
+		Reason:  reason,
+		Strikes: strikes,
+		Time:    time.Now(),
+	})
+	if len(s.dead) > maxDeadItems {
+		s.dead = s.dead[len(s.dead)-maxDeadItems:]
+	}
+	log.Printf("dead letter: %s after %d strikes: %s", element.Desc(), strikes, reason)
+	s.changed()
+}
+
+// DeadItems returns the dead letter list, oldest first.
+func (s *Server) DeadItems() []DeadItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.dead)
+}
+
+// RetryDead puts a dead-lettered item back at the end of the playlist and
+// returns its position.
+func (s *Server) RetryDead(id string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.dead, func(d DeadItem) bool { return d.ID == id })
+	if i < 0 {
+		return 0, false
+	}
+	s.playlist = append(s.playlist, s.dead[i].Item)
+	s.dead = slices.Delete(s.dead, i, i+1)
+	s.changed()
+	return len(s.playlist) - 1, true
+}
@@ -0,0 +1,181 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Free space thresholds, overridden from DISK_WARN_FREE_MB and
+// DISK_MIN_FREE_MB in main. Below diskWarnFree a volume is reported, below
+// diskMinFree nothing more is written to it.
+var (
+	DiskWarnFree int64 = 4 << 30
+	DiskMinFree  int64 = 1 << 30
+)
+
+// DiskExtraPaths are more volumes to watch, from DISK_PATHS (comma
+// separated) in main, e.g. the HLS volume nginx writes to.
+var DiskExtraPaths []string
+
+const (
+	// diskCheckInterval is how often the volumes are looked at
+	diskCheckInterval = time.Minute
+	// maxDiskEvents bounds the warning history, oldest dropped first
+	maxDiskEvents = 50
+)
+
+// Disk levels.
+const (
+	diskOK       = "ok"
+	diskWarning  = "warning"
+	diskCritical = "critical"
+)
+
+// DiskUsage is the space left on the volume holding Path.
+type DiskUsage struct {
+	Path       string `json:"path"`
+	FreeBytes  int64  `json:"free_bytes"`
+	TotalBytes int64  `json:"total_bytes"`
+	Level      string `json:"level"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DiskEvent is a volume changing level.
+type DiskEvent struct {
+	Time      time.Time `json:"time"`
+	Path      string    `json:"path"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	FreeBytes int64     `json:"free_bytes"`
+}
+
+// DiskStatus is the space on every watched volume, for /status.
+type DiskStatus struct {
+	Volumes []DiskUsage `json:"volumes"`
+	Events  []DiskEvent `json:"events,omitempty"`
+}
+
+// diskMonitor keeps the last check of the volumes and warns when one
+// runs low, before it fills up mid-playout.
+type diskMonitor struct {
+	mu     sync.Mutex
+	status DiskStatus
+	levels map[string]string
+}
+
+var disks = &diskMonitor{levels: map[string]string{}}
+
+// diskPaths are the folders the channel reads from or writes to.
+func diskPaths() []string {
+	paths := []string{MediaDir, YtdlpCacheDir, PromoDir}
+	if AsRunDir != "" {
+		paths = append(paths, AsRunDir)
+	}
+	paths = append(paths, DiskExtraPaths...)
+	return slices.Compact(paths)
+}
+
+func (d *diskMonitor) Status() DiskStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return DiskStatus{Volumes: slices.Clone(d.status.Volumes), Events: slices.Clone(d.status.Events)}
+}
+
+// run checks the volumes until ctx is done.
+func (d *diskMonitor) run(ctx context.Context) {
+	d.check(time.Now())
+	for sleepCtx(ctx, diskCheckInterval) {
+		d.check(time.Now())
+	}
+}
+
+// check measures every volume, logging the ones changing level.
+func (d *diskMonitor) check(now time.Time) {
+	var volumes []DiskUsage
+	for _, path := range diskPaths() {
+		volumes = append(volumes, diskUsage(path))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.Volumes = volumes
+	for _, v := range volumes {
+		if v.Error != "" {
+			continue
+		}
+		from := d.levels[v.Path]
+		if from == "" {
+			from = diskOK
+		}
+		if from == v.Level {
+			continue
+		}
+		d.levels[v.Path] = v.Level
+		log.Printf("disk: %s is %s, %d MB free", v.Path, v.Level, v.FreeBytes>>20)
+		d.status.Events = append(d.status.Events, DiskEvent{Time: now, Path: v.Path, From: from, To: v.Level, FreeBytes: v.FreeBytes})
+		if len(d.status.Events) > maxDiskEvents {
+			d.status.Events = d.status.Events[len(d.status.Events)-maxDiskEvents:]
+		}
+	}
+}
+
+// diskUsage measures the volume of path, or of its nearest existing parent
+// for folders created on first use.
+func diskUsage(path string) DiskUsage {
+	u := DiskUsage{Path: path}
+	free, total, err := diskFree(path)
+	if err != nil {
+		u.Error = err.Error()
+		return u
+	}
+	u.FreeBytes, u.TotalBytes = free, total
+	switch {
+	case free < DiskMinFree:
+		u.Level = diskCritical
+	case free < DiskWarnFree:
+		u.Level = diskWarning
+	default:
+		u.Level = diskOK
+	}
+	return u
+}
+
+func diskFree(path string) (free, total int64, err error) {
+	dir := filepath.Clean(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, 0, fmt.Errorf("statfs %s: %w", dir, err)
+	}
+	return int64(fs.Bavail) * int64(fs.Bsize), int64(fs.Blocks) * int64(fs.Bsize), nil
+}
+
+// checkSpace refuses to write about need bytes to dir when that would leave
+// its volume under diskMinFree. need may be 0 when the size is unknown.
+func checkSpace(dir string, need int64) error {
+	free, _, err := diskFree(dir)
+	if err != nil {
+		// a volume that cannot be measured is left to fail on its own
+		return nil
+	}
+	if free-need < DiskMinFree {
+		return fmt.Errorf("not enough space on %s: %d MB free, %d MB needed and %d MB kept free",
+			dir, free>>20, need>>20, DiskMinFree>>20)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package player
+
+// DrainStatus reports a drain started with /drain/on.
+type DrainStatus struct {
+	Draining bool `json:"draining"`
+	// Drained is true once the player stopped after the last item
+	Drained bool `json:"drained"`
+	// Remaining counts the items still to air, the current one included
+	Remaining int `json:"remaining"`
+}
+
+// SetDraining starts or ends a drain. While draining nothing can be
+// enqueued, and the player airs the rest of the playlist in order, without
+// looping or shuffling, then stops.
+func (s *Server) SetDraining(draining bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = draining
+	switch {
+	case draining && s.state == StatePlaying:
+		s.setState(StateDraining, "drain on")
+	case !draining && s.state == StateDraining:
+		s.setState(StatePlaying, "drain off")
+	}
+	s.publish()
+}
+
+func (s *Server) IsDraining() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.draining
+}
+
+func (s *Server) Drain() DrainStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := DrainStatus{Draining: s.draining}
+	if s.state != StateStopped {
+		st.Remaining = max(len(s.playlist)-s.currentlyPlaying, 0)
+	}
+	st.Drained = s.draining && s.state == StateStopped
+	return st
+}
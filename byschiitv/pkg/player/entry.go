@@ -0,0 +1,237 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// DedupWindow is how long an enqueue idempotency key is remembered.
+// Overridden from DEDUP_WINDOW_SECONDS in main.
+var DedupWindow = 10 * time.Minute
+
+// enqueuedKey is what an idempotency key resolved to.
+type enqueuedKey struct {
+	id   string
+	time time.Time
+}
+
+// ErrDuplicateID is returned when a client reuses the ID of a queued item.
+var ErrDuplicateID = errors.New("id already in the queue")
+
+// Peek errors: nothing follows, or shuffle mode has not picked yet.
+var (
+	ErrNothingQueued = errors.New("nothing queued")
+	ErrNextUnknown   = errors.New("shuffle picks the next item when the current one ends")
+)
+
+// indexOfID returns the playlist index of id, -1 if absent. Callers hold s.mu.
+func (s *Server) indexOfID(id string) int {
+	for i, element := range s.playlist {
+		if element.EntryID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Enqueue appends a /load style item, giving it an ID unless the client
+// chose one, and returns the ID and the item's playlist position.
+// A key seen within DedupWindow returns the item it enqueued instead, with
+// fresh false; its position is -1 once the item left the playlist.
+func (s *Server) Enqueue(key string, item map[string]interface{}) (id string, position int, fresh bool, err error) {
+	if key != "" {
+		s.mu.Lock()
+		s.pruneKeys()
+		prev, ok := s.enqueued[key]
+		if ok {
+			position := s.indexOfID(prev.id)
+			s.mu.Unlock()
+			return prev.id, position, false, nil
+		}
+		s.mu.Unlock()
+	}
+
+	parsed, err := parsePlaylist([]map[string]interface{}{item})
+	if err != nil {
+		return "", 0, false, err
+	}
+	if len(parsed) == 0 {
+		return "", 0, false, fmt.Errorf("unknown item type %v", item["type"])
+	}
+
+	id = parsed[0].EntryID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a concurrent retry may have won while parsing
+	if prev, ok := s.enqueued[key]; key != "" && ok {
+		return prev.id, s.indexOfID(prev.id), false, nil
+	}
+	if s.indexOfID(id) >= 0 {
+		return "", 0, false, ErrDuplicateID
+	}
+	s.playlist = append(s.playlist, parsed[0])
+	s.metrics.enqueue(1)
+	if key != "" {
+		if s.enqueued == nil {
+			s.enqueued = map[string]enqueuedKey{}
+		}
+		s.enqueued[key] = enqueuedKey{id: id, time: time.Now()}
+	}
+	s.changed()
+	return id, len(s.playlist) - 1, true, nil
+}
+
+// pruneKeys forgets idempotency keys older than DedupWindow. Callers hold
+// s.mu.
+func (s *Server) pruneKeys() {
+	for key, k := range s.enqueued {
+		if time.Since(k.time) > DedupWindow {
+			delete(s.enqueued, key)
+		}
+	}
+}
+
+// metadataKeys are the metadata fields elements read directly; any other
+// /enqueue metadata goes to Metadata.Extra.
+var metadataKeys = map[string]bool{"title": true, "description": true, "artwork": true, "category": true}
+
+// EnqueueItem builds the /load object of a POST /enqueue: item is a video
+// path or a /load object, metadata is merged into it.
+func EnqueueItem(item interface{}, metadata map[string]interface{}) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	switch item := item.(type) {
+	case string:
+		if item == "" {
+			return nil, errors.New("empty item")
+		}
+		out = map[string]interface{}{"type": "video", "path": item, "quality_index": float64(1)}
+	case map[string]interface{}:
+		out = item
+	default:
+		return nil, errors.New("item must be a path or an object")
+	}
+	extra, _ := out["extra"].(map[string]interface{})
+	for k, v := range metadata {
+		if metadataKeys[k] {
+			out[k] = v
+			continue
+		}
+		if extra == nil {
+			extra = map[string]interface{}{}
+		}
+		extra[k] = v
+	}
+	if extra != nil {
+		out["extra"] = extra
+	}
+	return out, nil
+}
+
+// ItemStatus is where a queued element stands, for GET /item/:id and /peek.
+type ItemStatus struct {
+	ID string `json:"id"`
+	// State is queued, playing, done (aired this pass of a non looping
+	// playlist) or dead
+	State string `json:"state"`
+	// Position is the playlist index, -1 for dead items
+	Position int `json:"position"`
+	// Ahead is how many items air before this one, -1 when it will not air
+	Ahead int                      `json:"ahead"`
+	Item  playlist.PlaylistElement `json:"item"`
+}
+
+// itemStatus describes the element at index. Callers hold s.mu.
+func (s *Server) itemStatus(index int) ItemStatus {
+	element := s.playlist[index]
+	st := ItemStatus{ID: element.EntryID(), State: "queued", Position: index, Item: element}
+	playing := s.onAir()
+	switch {
+	case index == s.currentlyPlaying && playing:
+		st.State = "playing"
+	case index >= s.currentlyPlaying:
+		st.Ahead = index - s.currentlyPlaying
+	case s.loop:
+		st.Ahead = len(s.playlist) - s.currentlyPlaying + index
+	default:
+		st.State = "done"
+		st.Ahead = -1
+	}
+	if playing && st.Ahead > 0 {
+		st.Ahead--
+	}
+	return st
+}
+
+// Item returns the status of the element with the given ID, looking in the
+// dead letter list too.
+func (s *Server) Item(id string) (ItemStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.indexOfID(id); i >= 0 {
+		return s.itemStatus(i), true
+	}
+	for _, d := range s.dead {
+		if d.ID == id {
+			return ItemStatus{ID: id, State: "dead", Position: -1, Ahead: -1, Item: d.Item}, true
+		}
+	}
+	return ItemStatus{}, false
+}
+
+// Peek returns the element the player starts next, without advancing:
+// a pending jump first, like advance.
+func (s *Server) Peek() (ItemStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.onAir() {
+		if s.shufflePending() {
+			return ItemStatus{}, ErrNextUnknown
+		}
+		next := s.upNext()
+		if next == nil {
+			return ItemStatus{}, ErrNothingQueued
+		}
+		return s.itemStatus(s.indexOfID(next.EntryID())), nil
+	}
+	if s.currentlyPlaying < 0 || s.currentlyPlaying >= len(s.playlist) {
+		return ItemStatus{}, ErrNothingQueued
+	}
+	return s.itemStatus(s.currentlyPlaying), nil
+}
+
+// RemoveItem takes the element with the given ID out of the playlist,
+// cancelling it if it is airing. It returns "removed" or "cancelled", empty
+// when no element has the ID.
+func (s *Server) RemoveItem(id string) (string, playlist.PlaylistElement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.indexOfID(id)
+	if i < 0 {
+		return "", nil
+	}
+	element := s.playlist[i]
+	s.playlist = slices.Delete(s.playlist, i, i+1)
+	delete(s.strikes, id)
+	outcome := "removed"
+	switch {
+	case i == s.currentlyPlaying && s.onAir():
+		// go on with the element that took this one's place
+		s.jumpTo = i
+		s.currentCancel()
+		outcome = "cancelled"
+	case i < s.currentlyPlaying:
+		s.currentlyPlaying--
+	}
+	if outcome == "removed" && s.jumpTo > i {
+		s.jumpTo--
+	}
+	if len(s.playlist) == 0 {
+		s.currentlyPlaying = 0
+	}
+	s.changed()
+	return outcome, element
+}
@@ -0,0 +1,217 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailoverAfter is how many failed publishes in a row move the stream to
+// the next destination. Overridden from RTMP_FAILOVER_AFTER in main.
+var FailoverAfter = 3
+
+// FailbackInterval is how often the primary is tried again while airing to
+// a backup. Overridden from RTMP_FAILBACK_SECONDS in main.
+var FailbackInterval = time.Minute
+
+// publishRetryDelay is the pause after an item that could not be published,
+// before the player tries the next one.
+var publishRetryDelay = 5 * time.Second
+
+// stderrTailBytes is how much of the ffmpeg log is kept to tell a publish
+// failure from a broken item.
+const stderrTailBytes = 8 << 10
+
+// destinations is the ordered RTMP_URL list: the first is the primary, the
+// others are backups used in turn when publishing keeps failing. It has
+// its own lock, since the player reads it without holding s.mu.
+type destinations struct {
+	mu       sync.Mutex
+	urls     []string
+	active   int
+	failures int
+	switched time.Time
+}
+
+// DestinationStatus is the publishing target, for GET /status.
+type DestinationStatus struct {
+	Active   string    `json:"active"`
+	Index    int       `json:"index"`
+	URLs     []string  `json:"urls"`
+	Failures int       `json:"failures"`
+	Switched time.Time `json:"switched,omitempty"`
+}
+
+// ParseDestinations splits a comma separated RTMP_URL.
+func ParseDestinations(list string) []string {
+	var urls []string
+	for _, u := range strings.Split(list, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// current returns the destination to publish to.
+func (d *destinations) current() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.urls[d.active]
+}
+
+func (d *destinations) status() DestinationStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return DestinationStatus{
+		Active:   d.urls[d.active],
+		Index:    d.active,
+		URLs:     append([]string(nil), d.urls...),
+		Failures: d.failures,
+		Switched: d.switched,
+	}
+}
+
+// succeeded resets the failure count of url.
+func (d *destinations) succeeded(url string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.urls[d.active] == url {
+		d.failures = 0
+	}
+}
+
+// failed counts a failed publish to url, moving to the next destination
+// after failoverAfter in a row. The last one wraps around to the primary.
+func (d *destinations) failed(url string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.urls[d.active] != url {
+		return
+	}
+	d.failures++
+	if d.failures < FailoverAfter || len(d.urls) < 2 {
+		return
+	}
+	d.use((d.active + 1) % len(d.urls))
+}
+
+// use switches to the destination at index. Callers hold d.mu.
+func (d *destinations) use(index int) {
+	log.Printf("rtmp: %d failures on %s, switching to %s", d.failures, d.urls[d.active], d.urls[index])
+	d.active = index
+	d.failures = 0
+	d.switched = time.Now()
+}
+
+// failback tries the primary every interval while a backup is active,
+// and returns to it once it answers again. The next item goes there; the
+// one airing is not interrupted.
+func (d *destinations) failback(ctx context.Context, interval time.Duration) {
+	for sleepCtx(ctx, interval) {
+		d.mu.Lock()
+		primary, onBackup := d.urls[0], d.active != 0
+		d.mu.Unlock()
+		if !onBackup || rtmpReachable(ctx, primary) != nil {
+			continue
+		}
+		d.mu.Lock()
+		if d.active != 0 {
+			log.Printf("rtmp: primary %s is back", primary)
+			d.active = 0
+			d.failures = 0
+			d.switched = time.Now()
+		}
+		d.mu.Unlock()
+	}
+}
+
+// PublishError is returned by StreamToRTMP when ffmpeg could not open or
+// write the RTMP output, as opposed to failing on the item itself.
+type PublishError struct {
+	URL    string
+	Detail string
+	Err    error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("publishing to %s: %v: %s", e.URL, e.Err, e.Detail)
+}
+
+func (e *PublishError) Unwrap() error { return e.Err }
+
+// stderrTail keeps the last stderrTailBytes written to it.
+type stderrTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - stderrTailBytes; over > 0 {
+		t.buf = t.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// publishFailure returns the line of an ffmpeg log showing it could not
+// open or write its output rtmpURL. Input errors, such as a live source
+// refusing the connection, do not count.
+func publishFailure(stderr, rtmpURL string) (string, bool) {
+	lines := strings.Split(stderr, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		lower := strings.ToLower(line)
+		switch {
+		case line == "", strings.HasPrefix(lower, "output #"):
+			// the banner naming the output, not an error
+		case strings.Contains(line, rtmpURL),
+			strings.Contains(lower, "error opening output"),
+			strings.Contains(lower, "av_interleaved_write_frame"),
+			strings.Contains(lower, "error muxing a packet"),
+			strings.Contains(lower, "error writing trailer"),
+			strings.Contains(lower, "broken pipe"):
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// checkDestination sorts out a streaming error: a publish failure counts
+// against the destination, and while it does not even accept connections,
+// up to failoverAfter in a row, the stream moves on to the next one.
+// Other errors are the item's, and leave the destination alone.
+func (d *destinations) checkDestination(ctx context.Context, url string, err error) {
+	if err == nil {
+		d.succeeded(url)
+		return
+	}
+	var perr *PublishError
+	if !errors.As(err, &perr) {
+		return
+	}
+	d.failed(url)
+	for range FailoverAfter {
+		if ctx.Err() != nil || d.current() != url {
+			return
+		}
+		err := rtmpReachable(ctx, url)
+		if err == nil {
+			return
+		}
+		log.Printf("rtmp: %s unreachable: %v", url, err)
+		d.failed(url)
+	}
+}
@@ -0,0 +1,59 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"byschiitv/pkg/playlist"
+)
+
+func TestPublishFailure(t *testing.T) {
+	const url = "rtmp://nginx:1935/live/stream"
+	tests := []struct {
+		name   string
+		stderr string
+		want   bool
+	}{
+		{"refused", "[tcp @ 0x1] Connection to tcp://nginx:1935 failed: Connection refused\n" +
+			"[out#0/flv @ 0x2] Error opening output " + url + ": Connection refused\n", true},
+		{"old ffmpeg", url + ": Input/output error\n", true},
+		{"dropped", "av_interleaved_write_frame(): Broken pipe\n[flv @ 0x3] Failed to update header\n", true},
+		{"banner only", "Output #0, flv, to '" + url + "':\n  Stream #0:0: Video: h264\n", false},
+		{"bad item", "Output #0, flv, to '" + url + "':\n/media/a.mp4: Invalid data found when processing input\n", false},
+		{"live source refused", "[tcp @ 0x1] Connection to tcp://cam:554 failed: Connection refused\n" +
+			"Error opening input file rtsp://cam/stream.\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := publishFailure(tt.stderr, url); got != tt.want {
+				t.Errorf("publishFailure = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamToRTMPPublishError(t *testing.T) {
+	const url = "rtmp://nginx:1935/live/stream"
+	for _, tt := range []struct {
+		stderr  string
+		publish bool
+	}{
+		{"Error opening output " + url + ": Connection refused\n", true},
+		{"/media/a.mp4: Invalid data found when processing input\n", false},
+	} {
+		useFakeRunner(t, &FakeRunner{
+			Respond: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+				io.WriteString(stderr, tt.stderr)
+				return errors.New("exit status 1")
+			},
+		})
+		err := StreamToRTMP(context.Background(), playlist.VideoElement{Path: "/media/a.mp4", QualityIndex: 1}, url)
+		var perr *PublishError
+		if errors.As(err, &perr) != tt.publish {
+			t.Errorf("%q: StreamToRTMP = %v, publish error %v", strings.TrimSpace(tt.stderr), err, tt.publish)
+		}
+	}
+}
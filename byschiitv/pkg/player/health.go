@@ -0,0 +1,130 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Health levels, worst last. Only unhealthy fails GET /healthz, so a
+// degraded channel is reported without being restarted.
+const (
+	HealthOK        = "ok"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+// healthTimeout bounds the checks that go to the network.
+const healthTimeout = 3 * time.Second
+
+// HealthCheck is the outcome of one check.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Health is GET /healthz: the worst status of the checks.
+type Health struct {
+	Status  string        `json:"status"`
+	Checked time.Time     `json:"checked"`
+	Checks  []HealthCheck `json:"checks"`
+}
+
+// Health checks what the channel needs to stay on air: the ffmpeg binaries,
+// the media folder, the RTMP destinations, free space and the player loop.
+func (s *Server) Health(ctx context.Context) Health {
+	h := Health{Status: HealthOK, Checked: time.Now()}
+	add := func(name, status, detail string) {
+		h.Checks = append(h.Checks, HealthCheck{Name: name, Status: status, Detail: detail})
+		if healthRank(status) > healthRank(h.Status) {
+			h.Status = status
+		}
+	}
+
+	if path, err := exec.LookPath("ffmpeg"); err != nil {
+		add("ffmpeg", HealthUnhealthy, err.Error())
+	} else {
+		add("ffmpeg", HealthOK, path)
+	}
+	// without ffprobe durations are unknown, but items still air
+	if path, err := exec.LookPath("ffprobe"); err != nil {
+		add("ffprobe", HealthDegraded, err.Error())
+	} else {
+		add("ffprobe", HealthOK, path)
+	}
+
+	switch entries, err := os.ReadDir(MediaDir); {
+	case err != nil:
+		add("media", HealthUnhealthy, err.Error())
+	case len(entries) == 0:
+		// an empty folder is usually a volume that did not get mounted
+		add("media", HealthDegraded, MediaDir+" is empty, is the volume mounted?")
+	default:
+		add("media", HealthOK, fmt.Sprintf("%s: %d entries", MediaDir, len(entries)))
+	}
+
+	dest := s.dest.status()
+	for i, u := range dest.URLs {
+		name := fmt.Sprintf("rtmp[%d]", i)
+		if err := resolveDestination(ctx, u); err != nil {
+			// only the active destination keeps the channel on air
+			if i == dest.Index {
+				add(name, HealthUnhealthy, err.Error())
+			} else {
+				add(name, HealthDegraded, err.Error())
+			}
+			continue
+		}
+		add(name, HealthOK, u)
+	}
+
+	// a full disk stops downloads and promos, not what is on air
+	for _, v := range disks.Status().Volumes {
+		switch {
+		case v.Error != "":
+			add("disk "+v.Path, HealthDegraded, v.Error)
+		case v.Level == diskCritical:
+			add("disk "+v.Path, HealthDegraded, fmt.Sprintf("%d MB free", v.FreeBytes>>20))
+		}
+	}
+
+	switch reason := s.playerStalled(); {
+	case reason != "":
+		add("player", HealthUnhealthy, reason)
+	case s.loadView().state == StateStopped:
+		add("player", HealthDegraded, "player stopped, /start to go on air")
+	default:
+		add("player", HealthOK, string(s.loadView().state))
+	}
+	return h
+}
+
+func healthRank(status string) int {
+	switch status {
+	case HealthDegraded:
+		return 1
+	case HealthUnhealthy:
+		return 2
+	}
+	return 0
+}
+
+// resolveDestination looks up the host of an RTMP URL.
+func resolveDestination(ctx context.Context, rtmpURL string) error {
+	u, err := url.Parse(rtmpURL)
+	if err != nil {
+		return err
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%q has no host", rtmpURL)
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	_, err = net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	return err
+}
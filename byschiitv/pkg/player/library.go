@@ -0,0 +1,57 @@
+package player
+
+import (
+	"fmt"
+	"path/filepath"
+	"textmatch"
+)
+
+// DefaultSearchLimit is how many library matches GET /library/search
+// returns unless asked otherwise.
+const DefaultSearchLimit = 20
+
+// SearchLibrary ranks the media files below dir against query with algo:
+// combined (default), levenshtein, cosine, jaccard, or substring and regex,
+// which only filter. Files are matched by their path below dir, so folder
+// names such as a series count too.
+func SearchLibrary(dir, query, algo string, limit int) ([]string, error) {
+	files, err := listMediaFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel := make([]string, len(files))
+	for i, f := range files {
+		if rel[i], err = filepath.Rel(dir, f); err != nil {
+			rel[i] = f
+		}
+	}
+
+	var ranked []string
+	switch algo {
+	case "", "combined":
+		ranked = textmatch.SortByCombined(rel, query)
+	case "levenshtein":
+		ranked = textmatch.SortByLevenshtein(rel, query)
+	case "cosine":
+		ranked = textmatch.SortByCosine(rel, query, 3)
+	case "jaccard":
+		ranked = textmatch.SortByJaccard(rel, query)
+	case "substring":
+		ranked = textmatch.FilterSubstring(rel, query)
+	case "regex":
+		if ranked, err = textmatch.FilterRegex(rel, query); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown search algorithm %q", algo)
+	}
+
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	out := make([]string, len(ranked))
+	for i, r := range ranked {
+		out[i] = filepath.Join(dir, r)
+	}
+	return out, nil
+}
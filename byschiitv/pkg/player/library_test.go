@@ -0,0 +1,46 @@
+package player
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSearchLibrary(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"Twin Peaks/S01E01 Pilot.mkv",
+		"Twin Peaks/S01E02.mkv",
+		"Blue Velvet (1986).mp4",
+		"notes.txt",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := SearchLibrary(dir, "twin peaks pilot", "", 2)
+	if err != nil {
+		t.Fatalf("SearchLibrary: %v", err)
+	}
+	want := []string{filepath.Join(dir, "Twin Peaks/S01E01 Pilot.mkv"), filepath.Join(dir, "Twin Peaks/S01E02.mkv")}
+	if !slices.Equal(got, want) {
+		t.Errorf("combined = %q, want %q", got, want)
+	}
+
+	got, err = SearchLibrary(dir, "velvet", "substring", 0)
+	if err != nil || !slices.Equal(got, []string{filepath.Join(dir, "Blue Velvet (1986).mp4")}) {
+		t.Errorf("substring = %q, %v", got, err)
+	}
+	if _, err := SearchLibrary(dir, "(", "regex", 0); err == nil {
+		t.Error("invalid regex accepted")
+	}
+	if _, err := SearchLibrary(dir, "x", "soundex", 0); err == nil {
+		t.Error("unknown algorithm accepted")
+	}
+}
@@ -0,0 +1,82 @@
+package player
+
+import (
+	"context"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// ListQuery filters and pages /list.
+type ListQuery struct {
+	// Type keeps only elements of this type, empty for all
+	Type string
+	// FromIndex skips the elements before this playlist index
+	FromIndex int
+	// Offset and Limit page the filtered elements; Limit 0 means no limit
+	Offset int
+	Limit  int
+}
+
+// ListEntry locates a listed element in the playlist.
+type ListEntry struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	// Start is the estimated airing time, omitted for elements already
+	// aired and in shuffle mode
+	Start *time.Time `json:"start,omitempty"`
+}
+
+// ListPage is one page of /list.
+type ListPage struct {
+	Queue   []playlist.PlaylistElement `json:"queue"`
+	Entries []ListEntry                `json:"entries"`
+	// Total counts the elements matching the filters, before paging
+	Total   int    `json:"total"`
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+	Version uint64 `json:"version"`
+}
+
+// ListPage filters and pages the published playlist. Start estimates add
+// up the probed durations from the item on air, like EPG.
+func (s *Server) ListPage(ctx context.Context, q ListQuery) ListPage {
+	v := s.loadView()
+	page := ListPage{Queue: []playlist.PlaylistElement{}, Entries: []ListEntry{}, Offset: q.Offset, Limit: q.Limit, Version: v.version}
+
+	var matches []int
+	for i := max(q.FromIndex, 0); i < len(v.playlist); i++ {
+		if q.Type == "" || v.playlist[i].Type() == q.Type {
+			matches = append(matches, i)
+		}
+	}
+	page.Total = len(matches)
+	if q.Offset > 0 {
+		matches = matches[min(q.Offset, len(matches)):]
+	}
+	if q.Limit > 0 && q.Limit < len(matches) {
+		matches = matches[:q.Limit]
+	}
+
+	estimate := !v.shuffle && v.current >= 0 && len(matches) > 0
+	start := time.Now()
+	if !v.started.IsZero() {
+		start = v.started
+	}
+	next := v.current // the element whose start is start
+	for _, i := range matches {
+		entry := ListEntry{Index: i, Type: v.playlist[i].Type()}
+		if estimate && i >= v.current {
+			for ; next < i; next++ {
+				if dur, err := s.elementDuration(ctx, v.playlist[next], 0); err == nil {
+					start = start.Add(dur)
+				}
+			}
+			at := start
+			entry.Start = &at
+		}
+		page.Queue = append(page.Queue, v.playlist[i])
+		page.Entries = append(page.Entries, entry)
+	}
+	return page
+}
@@ -0,0 +1,30 @@
+package player
+
+import (
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// NowPlaying is the /nowplaying payload.
+type NowPlaying struct {
+	Playing   bool              `json:"playing"`
+	Index     int               `json:"index"`
+	Type      string            `json:"type,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Metadata  playlist.Metadata `json:"metadata"`
+	StartedAt time.Time         `json:"started_at,omitempty"`
+}
+
+// EPGEntry is one programme of the /epg export.
+type EPGEntry struct {
+	Index           int               `json:"index"`
+	Type            string            `json:"type"`
+	Title           string            `json:"title"`
+	Metadata        playlist.Metadata `json:"metadata"`
+	Start           time.Time         `json:"start"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	// DurationKnown is false for items that can't be probed (live without a
+	// duration, random picks); following start times are then lower bounds.
+	DurationKnown bool `json:"duration_known"`
+}
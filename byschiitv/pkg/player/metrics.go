@@ -0,0 +1,124 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// airingBuckets are the upper bounds, in seconds, of the airing duration
+// histogram: from short bumpers to feature films.
+var airingBuckets = []float64{10, 30, 60, 300, 900, 1800, 3600, 7200}
+
+// queueMetrics counts what went through the queue since start, for
+// /metrics. It has its own lock so the player never waits on s.mu for it.
+type queueMetrics struct {
+	mu       sync.Mutex
+	enqueued int
+	started  int
+	// outcomes counts finished airings by outcome: ok, failed, skipped,
+	// timeout or stopped
+	outcomes map[string]int
+	// buckets[i] counts airings of at most airingBuckets[i] seconds
+	buckets []int
+	count   int
+	sum     float64
+}
+
+func (m *queueMetrics) enqueue(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued += n
+}
+
+func (m *queueMetrics) start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started++
+}
+
+// finish records an airing that lasted d.
+func (m *queueMetrics) finish(outcome string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.outcomes == nil {
+		m.outcomes = map[string]int{}
+		m.buckets = make([]int, len(airingBuckets))
+	}
+	m.outcomes[outcome]++
+	secs := d.Seconds()
+	for i, le := range airingBuckets {
+		if secs <= le {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.sum += secs
+}
+
+// airingOutcomes are always exported, so rates start from zero.
+var airingOutcomes = []string{"ok", "failed", "skipped", "timeout", "stopped"}
+
+// WriteMetrics writes the queue metrics in the Prometheus text format.
+func (s *Server) WriteMetrics(w io.Writer) {
+	s.mu.RLock()
+	depth := len(s.playlist)
+	ahead := max(depth-s.currentlyPlaying-1, 0)
+	dead := len(s.dead)
+	playing, paused := 0, 0
+	if s.onAir() {
+		playing = 1
+	}
+	if s.state == StatePaused {
+		paused = 1
+	}
+	s.mu.RUnlock()
+	tel := hardware.read(context.Background())
+
+	m := &s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	gauge := func(name, help string, v int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+	counter := func(name, help string, v int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	gauge("byschiitv_queue_depth", "Items in the playlist.", depth)
+	gauge("byschiitv_queue_ahead", "Items after the current one.", ahead)
+	gauge("byschiitv_dead_items", "Items in the dead letter list.", dead)
+	gauge("byschiitv_playing", "Whether an item is on air.", playing)
+	gauge("byschiitv_paused", "Whether the player is paused.", paused)
+	gauge("byschiitv_thermal_steps", "Presets taken off the quality because of the heat.", thermal.Status().Steps)
+	counter("byschiitv_enqueued_total", "Items added to the playlist.", m.enqueued)
+	counter("byschiitv_started_total", "Airings started by the player.", m.started)
+
+	fmt.Fprintf(w, "# HELP byschiitv_finished_total Airings finished, by outcome.\n# TYPE byschiitv_finished_total counter\n")
+	for _, outcome := range airingOutcomes {
+		fmt.Fprintf(w, "byschiitv_finished_total{outcome=%q} %d\n", outcome, m.outcomes[outcome])
+	}
+
+	fmt.Fprintf(w, "# HELP byschiitv_airing_seconds How long items stayed on air.\n# TYPE byschiitv_airing_seconds histogram\n")
+	for i, le := range airingBuckets {
+		n := 0
+		if m.buckets != nil {
+			n = m.buckets[i]
+		}
+		fmt.Fprintf(w, "byschiitv_airing_seconds_bucket{le=\"%g\"} %d\n", le, n)
+	}
+	fmt.Fprintf(w, "byschiitv_airing_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	fmt.Fprintf(w, "byschiitv_airing_seconds_sum %g\nbyschiitv_airing_seconds_count %d\n", m.sum, m.count)
+
+	writeTelemetryMetrics(w, tel)
+
+	if volumes := disks.Status().Volumes; len(volumes) > 0 {
+		fmt.Fprintf(w, "# HELP byschiitv_disk_free_bytes Free space on the volume of each watched folder.\n# TYPE byschiitv_disk_free_bytes gauge\n")
+		for _, v := range volumes {
+			if v.Error == "" {
+				fmt.Fprintf(w, "byschiitv_disk_free_bytes{path=%q} %d\n", v.Path, v.FreeBytes)
+			}
+		}
+	}
+}
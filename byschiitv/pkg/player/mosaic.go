@@ -0,0 +1,200 @@
+package player
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"byschiitv/pkg/ffmpegopts"
+	"byschiitv/pkg/filtergraph"
+)
+
+// Mosaic settings, from MOSAIC_SOURCES (comma separated channel streams)
+// and MOSAIC_URL (where the grid is published) in main. The mosaic is off
+// unless both are set.
+var (
+	MosaicSources []string
+	MosaicURL     = ""
+)
+
+const (
+	mosaicWidth, mosaicHeight, mosaicRate = 1280, 720, 25
+	// mosaicRetry is the wait before restarting a failed mosaic, doubled
+	// on each failure in a row up to mosaicMaxRetry
+	mosaicRetry    = 5 * time.Second
+	mosaicMaxRetry = 2 * time.Minute
+	// mosaicHealthy is how long a run must last to reset the retry wait
+	mosaicHealthy = time.Minute
+)
+
+// MosaicStatus is the multiviewer, for GET /mosaic.
+type MosaicStatus struct {
+	Enabled   bool      `json:"enabled"`
+	Running   bool      `json:"running"`
+	Sources   []string  `json:"sources,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// MosaicRunner keeps one ffmpeg compositing every channel into a grid.
+// A channel going off air ends ffmpeg, so it is restarted with backoff.
+type MosaicRunner struct {
+	mu     sync.Mutex
+	status MosaicStatus
+}
+
+var Mosaic = &MosaicRunner{}
+
+func (m *MosaicRunner) Status() MosaicStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// run publishes the mosaic until ctx is done.
+func (m *MosaicRunner) run(ctx context.Context, sources []string, out string) {
+	m.mu.Lock()
+	m.status = MosaicStatus{Enabled: true, Sources: sources, URL: out}
+	m.mu.Unlock()
+	args, err := FfmpegMosaicCommand(sources, out)
+	if err != nil {
+		log.Printf("mosaic: %v", err)
+		m.mu.Lock()
+		m.status.LastError = err.Error()
+		m.mu.Unlock()
+		return
+	}
+	args = append([]string{"-hide_banner", "-loglevel", "error"}, args...)
+	log.Printf("mosaic: %d channels to %s", len(sources), out)
+
+	wait := mosaicRetry
+	for ctx.Err() == nil {
+		started := time.Now()
+		m.mu.Lock()
+		m.status.Running, m.status.Since = true, started
+		m.mu.Unlock()
+
+		var stderr bytes.Buffer
+		err := commandRunner.Run(ctx, "ffmpeg", args, nil, &stderr)
+
+		m.mu.Lock()
+		m.status.Running = false
+		if ctx.Err() == nil {
+			m.status.Restarts++
+			m.status.LastError = strings.TrimSpace(stderr.String())
+			if m.status.LastError == "" && err != nil {
+				m.status.LastError = err.Error()
+			}
+		}
+		m.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > mosaicHealthy {
+			wait = mosaicRetry
+		}
+		log.Printf("mosaic: ffmpeg ended (%v), restarting in %s", err, wait)
+		if !sleepCtx(ctx, wait) {
+			return
+		}
+		wait = min(wait*2, mosaicMaxRetry)
+	}
+}
+
+// mosaicGrid is the columns and rows fitting n tiles, as square as possible.
+func mosaicGrid(n int) (cols, rows int) {
+	cols = int(math.Ceil(math.Sqrt(float64(n))))
+	rows = (n + cols - 1) / cols
+	return cols, rows
+}
+
+// mosaicLabel names a tile after its stream key, or its host.
+func mosaicLabel(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return source
+	}
+	if name := path.Base(u.Path); name != "." && name != "/" {
+		return name
+	}
+	return u.Host
+}
+
+// FfmpegMosaicCommand stacks sources into a labelled grid published to
+// out, with the audio of the first source.
+func FfmpegMosaicCommand(sources []string, out string) ([]string, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("mosaic: no sources")
+	}
+	cols, rows := mosaicGrid(len(sources))
+	tileW, tileH := mosaicWidth/cols, mosaicHeight/rows
+	// yuv420p needs even sizes
+	tileW, tileH = tileW&^1, tileH&^1
+
+	var args []string
+	var graph filtergraph.FilterGraph
+	var cells, tiles []string
+	for i, source := range sources {
+		args = append(args, ffmpegopts.LiveInputArgs(source)...)
+		args = append(args, "-i", source)
+		tile := "tile" + strconv.Itoa(i)
+		graph = append(graph, filtergraph.FilterChain{
+			Inputs: []string{strconv.Itoa(i) + ":v"},
+			Filters: []filtergraph.Filter{
+				filtergraph.Scale{Width: tileW, Height: tileH, FitInside: true},
+				filtergraph.Pad{Width: tileW, Height: tileH, Color: "black"},
+				filtergraph.FPS{Rate: mosaicRate},
+				filtergraph.Format{PixFmt: "yuv420p"},
+				filtergraph.DrawText{
+					Text:       mosaicLabel(source),
+					FontSize:   20,
+					FontColor:  "white",
+					X:          "10",
+					Y:          "10",
+					Box:        true,
+					BoxColor:   "black@0.6",
+					BoxBorderW: 4,
+				},
+			},
+			Outputs: []string{tile},
+		})
+		tiles = append(tiles, tile)
+		cells = append(cells, fmt.Sprintf("%d_%d", (i%cols)*tileW, (i/cols)*tileH))
+	}
+	if len(sources) > 1 {
+		graph = append(graph, filtergraph.FilterChain{
+			Inputs:  tiles,
+			Filters: []filtergraph.Filter{filtergraph.XStack{Inputs: len(sources), Layout: strings.Join(cells, "|"), Fill: "black"}},
+			Outputs: []string{"v"},
+		})
+	} else {
+		graph[0].Outputs = []string{"v"}
+	}
+	if err := graph.Validate(); err != nil {
+		return nil, fmt.Errorf("mosaic filter: %w", err)
+	}
+
+	return append(args,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "0:a?",
+		"-c:v", "h264_v4l2m2m",
+		"-b:v", "2000k",
+		"-g", strconv.Itoa(mosaicRate*2),
+		"-c:a", "aac",
+		"-b:a", "96k",
+		"-ar", "48000",
+		"-f", "flv",
+		out,
+	), nil
+}
@@ -0,0 +1,188 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"byschiitv/pkg/playlist"
+)
+
+// QueueStateFile is where the playlist and saved playlists survive restarts.
+// Overridden from QUEUE_STATE_FILE, empty disables persistence.
+var QueueStateFile = "queue_state.json"
+
+// QueueState is the persisted part of the Server.
+type QueueState struct {
+	Version  uint64                              `json:"version"`
+	Playlist []map[string]interface{}            `json:"playlist"`
+	Saved    map[string][]map[string]interface{} `json:"saved,omitempty"`
+	Loop     bool                                `json:"loop"`
+	Shuffle  bool                                `json:"shuffle,omitempty"`
+	Dead     []deadState                         `json:"dead,omitempty"`
+}
+
+// deadState is a DeadItem with its element encoded like the playlist.
+type deadState struct {
+	DeadItem
+	Item map[string]interface{} `json:"item"`
+}
+
+// snapshotState encodes the persisted part of the Server. Callers hold s.mu.
+func (s *Server) snapshotState() (QueueState, error) {
+	st := QueueState{Version: s.version, Loop: s.loop, Shuffle: s.shuffle, Saved: map[string][]map[string]interface{}{}}
+	var err error
+	if st.Playlist, err = playlist.EncodeElements(s.playlist); err != nil {
+		return st, fmt.Errorf("playlist: %w", err)
+	}
+	for name, elements := range s.saved {
+		if st.Saved[name], err = playlist.EncodeElements(elements); err != nil {
+			return st, fmt.Errorf("playlist %q: %w", name, err)
+		}
+	}
+	for _, d := range s.dead {
+		items, err := playlist.EncodeElements([]playlist.PlaylistElement{d.Item})
+		if err != nil {
+			return st, fmt.Errorf("dead item %s: %w", d.ID, err)
+		}
+		st.Dead = append(st.Dead, deadState{DeadItem: d, Item: items[0]})
+	}
+	return st, nil
+}
+
+// applyState replaces the persisted part of the Server. Callers hold s.mu.
+func (s *Server) applyState(st QueueState) {
+	s.playlist = playlist.DecodeElements(st.Playlist)
+	s.loop = st.Loop
+	s.shuffle = st.Shuffle
+	s.saved = nil
+	for name, items := range st.Saved {
+		if s.saved == nil {
+			s.saved = make(map[string][]playlist.PlaylistElement)
+		}
+		s.saved[name] = playlist.DecodeElements(items)
+	}
+	s.dead = nil
+	for _, d := range st.Dead {
+		if element, ok := playlist.ParseElement(d.Item); ok {
+			d.DeadItem.Item = element
+			s.dead = append(s.dead, d.DeadItem)
+		}
+	}
+}
+
+// persist saves the queue state and publishes the new view. Every playlist
+// change goes through it. Callers hold s.mu.
+func (s *Server) persist() {
+	s.publish()
+	if s.statePath == "" {
+		return
+	}
+	st, err := s.snapshotState()
+	if err != nil {
+		log.Printf("queue: can't encode %v", err)
+		return
+	}
+	if err := writeState(s.statePath, st); err != nil {
+		log.Printf("queue: can't save %s: %v", s.statePath, err)
+	}
+}
+
+// writeState writes st to a synced temp file, keeps the current file as
+// .bak and renames the temp file over it. A crash at any point leaves either
+// the new or the previous state readable.
+func writeState(path string, st QueueState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path, path+".bak"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readState loads the state file, falling back to the backup when it is
+// missing or corrupt.
+func readState(path string) (QueueState, error) {
+	var errs []error
+	for _, file := range []string{path, path + ".bak"} {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		var st QueueState
+		if err == nil {
+			err = json.Unmarshal(data, &st)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		if file != path {
+			log.Printf("queue: recovered state from %s", file)
+		}
+		return st, nil
+	}
+	if len(errs) > 0 {
+		return QueueState{}, errors.Join(errs...)
+	}
+	return QueueState{}, os.ErrNotExist
+}
+
+// Snapshot exports the playlist, saved playlists, modes and dead letters in
+// the state file format, for backups or moving the queue to another
+// instance.
+func (s *Server) Snapshot() (QueueState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshotState()
+}
+
+// Import replaces the queue with a Snapshot. The item on air keeps playing,
+// the player goes on from the same index in the new playlist.
+func (s *Server) Import(st QueueState) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyState(st)
+	s.changed()
+	return len(s.playlist)
+}
+
+// restore loads the persisted queue into a new Server.
+func (s *Server) restore() {
+	if s.statePath == "" {
+		return
+	}
+	st, err := readState(s.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("queue: starting empty, can't read state: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyState(st)
+	s.version = st.Version
+	log.Printf("queue: restored %d items and %d saved playlists from %s", len(s.playlist), len(s.saved), s.statePath)
+}
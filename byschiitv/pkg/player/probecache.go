@@ -0,0 +1,146 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DurationCacheFile keeps probed durations across restarts, so a restart
+// does not re-probe the whole library. Overridden from DURATION_CACHE_FILE,
+// empty disables it.
+var DurationCacheFile = "duration_cache.json"
+
+// durationCacheFlush is how often new probe results are written out.
+const durationCacheFlush = 30 * time.Second
+
+// probedFile is a probed duration and the file state it was probed at.
+type probedFile struct {
+	Size     int64         `json:"size"`
+	ModTime  time.Time     `json:"mtime"`
+	Duration time.Duration `json:"duration"`
+}
+
+// durationCache remembers ffprobe results of local files, valid as long as
+// the file keeps its size and modification time.
+type durationCache struct {
+	mu    sync.Mutex
+	files map[string]probedFile
+	// dirty is set by store until the next save
+	dirty bool
+}
+
+// probeCache is used by GetVideoDuration for every local file.
+var probeCache = &durationCache{files: map[string]probedFile{}}
+
+// lookup returns the cached duration of path, if the file did not change.
+// ok is false for anything that is not a local file.
+func (c *durationCache) lookup(path string) (time.Duration, os.FileInfo, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.files[path]
+	if !ok || f.Size != info.Size() || !f.ModTime.Equal(info.ModTime()) {
+		return 0, info, false
+	}
+	return f.Duration, info, true
+}
+
+func (c *durationCache) store(path string, info os.FileInfo, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = probedFile{Size: info.Size(), ModTime: info.ModTime(), Duration: d}
+	c.dirty = true
+}
+
+// load reads a saved cache. Stale entries are harmless: lookup checks them
+// against the file.
+func (c *durationCache) load(file string) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	var files map[string]probedFile
+	if err == nil {
+		err = json.Unmarshal(data, &files)
+	}
+	if err != nil {
+		log.Printf("duration cache: ignoring %s: %v", file, err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, f := range files {
+		c.files[path] = f
+	}
+	log.Printf("duration cache: %d files from %s", len(files), file)
+}
+
+// save writes the cache if it changed since the last save, dropping the
+// entries of files that are gone.
+func (c *durationCache) save(file string) {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return
+	}
+	for path := range c.files {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			delete(c.files, path)
+		}
+	}
+	data, err := json.Marshal(c.files)
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		log.Printf("duration cache: %v", err)
+		return
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("duration cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		log.Printf("duration cache: %v", err)
+	}
+}
+
+// persistEvery saves the cache to file every interval until done is closed,
+// then once more.
+func (c *durationCache) persistEvery(done <-chan struct{}, file string, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.save(file)
+		case <-done:
+			c.save(file)
+			return
+		}
+	}
+}
+
+// PersistDurations loads the cache from DurationCacheFile and saves it
+// every durationCacheFlush until done is closed. The returned channel is
+// closed after the last save. An empty DurationCacheFile disables it.
+func PersistDurations(done <-chan struct{}) <-chan struct{} {
+	saved := make(chan struct{})
+	if DurationCacheFile == "" {
+		close(saved)
+		return saved
+	}
+	probeCache.load(DurationCacheFile)
+	go func() {
+		probeCache.persistEvery(done, DurationCacheFile, durationCacheFlush)
+		close(saved)
+	}()
+	return saved
+}
@@ -0,0 +1,145 @@
+package player
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// SlowEncodeWindow is how long ffmpeg may stay below realtime speed before
+// the current item is restarted at a lower quality preset.
+// Overridden from SLOW_ENCODE_SECONDS in main.
+var SlowEncodeWindow = 20 * time.Second
+
+// EncoderBehindError is returned by StreamToRTMP when the encoder could not keep
+// up with realtime for slowEncodeWindow. Position is where the stream was cut,
+// so the item can be restarted from there.
+type EncoderBehindError struct {
+	Element  playlist.VideoElement
+	Position time.Duration
+}
+
+func (e *EncoderBehindError) Error() string {
+	return fmt.Sprintf("encoder behind realtime on %s at %s (quality %d)", e.Element.Path, e.Position, e.Element.QualityIndex)
+}
+
+// QualityEvent records an automatic quality downgrade.
+type QualityEvent struct {
+	Time          time.Time `json:"time"`
+	Item          string    `json:"item"`
+	FromQuality   int       `json:"from_quality"`
+	ToQuality     int       `json:"to_quality"`
+	OffsetSeconds float64   `json:"offset_seconds"`
+}
+
+// slowSpeed is the encode speed below which the encoder counts as behind.
+// With -re ffmpeg never runs faster than realtime and settles just under
+// 1x, so the threshold leaves some room.
+const slowSpeed = 0.9
+
+// speedSpan is how far back the speed is measured, smoothing out the bursts
+// of the progress reports.
+const speedSpan = 2 * time.Second
+
+// progressSample is the output position reached at a wall clock time.
+type progressSample struct {
+	wall time.Time
+	out  time.Duration
+}
+
+// speedMonitor consumes ffmpeg "-progress pipe:1" output and calls onSlow once
+// the encode speed stays below slowSpeed for longer than window. The speed is
+// measured as output time over wall clock time: ffmpeg's own speed= is
+// cumulative since the start, so a stall late in a long item barely moves
+// it. A nil onSlow only swallows the progress output.
+type speedMonitor struct {
+	mu        sync.Mutex
+	buf       []byte
+	window    time.Duration
+	onSlow    func()
+	now       func() time.Time
+	samples   []progressSample
+	slowSince time.Time
+	outTime   time.Duration
+	slow      bool
+}
+
+func newSpeedMonitor(window time.Duration, onSlow func()) *speedMonitor {
+	return &speedMonitor{window: window, onSlow: onSlow, now: time.Now}
+}
+
+func (m *speedMonitor) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buf = append(m.buf, p...)
+	for {
+		i := bytes.IndexByte(m.buf, '\n')
+		if i < 0 {
+			break
+		}
+		m.handleLine(strings.TrimSpace(string(m.buf[:i])))
+		m.buf = m.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (m *speedMonitor) handleLine(line string) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok || key != "out_time_us" {
+		return
+	}
+	us, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || us < 0 {
+		// "N/A" while ffmpeg is still starting up
+		return
+	}
+	m.outTime = time.Duration(us) * time.Microsecond
+	m.sample(m.outTime)
+}
+
+// sample records the output position out and checks the speed since the
+// sample speedSpan back.
+func (m *speedMonitor) sample(out time.Duration) {
+	now := m.now()
+	m.samples = append(m.samples, progressSample{wall: now, out: out})
+	// only the newest sample at least speedSpan old is needed
+	for len(m.samples) > 1 && now.Sub(m.samples[1].wall) >= speedSpan {
+		m.samples = m.samples[1:]
+	}
+	if m.onSlow == nil {
+		return
+	}
+	from := m.samples[0]
+	elapsed := now.Sub(from.wall)
+	if elapsed < speedSpan {
+		return
+	}
+	speed := float64(out-from.out) / float64(elapsed)
+	if speed >= slowSpeed {
+		m.slowSince = time.Time{}
+		return
+	}
+	if m.slowSince.IsZero() {
+		m.slowSince = now
+		return
+	}
+	if !m.slow && now.Sub(m.slowSince) >= m.window {
+		m.slow = true
+		log.Printf("encoder below realtime (%.2fx) for %s, downgrading", speed, m.window)
+		m.onSlow()
+	}
+}
+
+// tripped reports whether the monitor gave up on the current encode, and the
+// output position reached at that point.
+func (m *speedMonitor) tripped() (bool, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.slow, m.outTime
+}
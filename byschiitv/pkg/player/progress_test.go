@@ -0,0 +1,65 @@
+package player
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// phase is a stretch of progress reports at a steady speed.
+type phase struct {
+	length time.Duration
+	speed  float64
+}
+
+func TestSpeedMonitorHandleLine(t *testing.T) {
+	const window = 20 * time.Second
+	tests := []struct {
+		name   string
+		phases []phase
+		want   bool
+	}{
+		{"steady just under realtime", []phase{{60 * time.Second, 0.99}}, false},
+		{"realtime", []phase{{60 * time.Second, 1}}, false},
+		{"sustained stall", []phase{{10 * time.Second, 1}, {30 * time.Second, 0}}, true},
+		{"sustained slow encode", []phase{{10 * time.Second, 1}, {30 * time.Second, 0.7}}, true},
+		{"stall late in a long item", []phase{{time.Hour, 1}, {30 * time.Second, 0}}, true},
+		{"stall shorter than the window", []phase{{10 * time.Second, 1}, {15 * time.Second, 0}, {30 * time.Second, 1}}, false},
+		{
+			"recovery resets",
+			[]phase{{10 * time.Second, 1}, {15 * time.Second, 0}, {10 * time.Second, 1}, {15 * time.Second, 0}, {10 * time.Second, 1}},
+			false,
+		},
+	}
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(logOutput) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := time.Date(2026, 10, 15, 20, 0, 0, 0, time.UTC)
+			tripped := false
+			m := newSpeedMonitor(window, func() { tripped = true })
+			m.now = func() time.Time { return clock }
+
+			// ffmpeg reports twice a second
+			const step = 500 * time.Millisecond
+			var out time.Duration
+			m.handleLine("out_time_us=N/A")
+			for _, p := range tt.phases {
+				for elapsed := time.Duration(0); elapsed < p.length; elapsed += step {
+					clock = clock.Add(step)
+					out += time.Duration(float64(step) * p.speed)
+					m.handleLine(fmt.Sprintf("out_time_us=%d", out.Microseconds()))
+					m.handleLine("speed=0.99x")
+				}
+			}
+			if tripped != tt.want {
+				t.Errorf("tripped = %v, want %v", tripped, tt.want)
+			}
+			if slow, pos := m.tripped(); slow != tt.want || (!slow && pos != out.Truncate(time.Microsecond)) {
+				t.Errorf("tripped() = %v, %s", slow, pos)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package player
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"byschiitv/pkg/filtergraph"
+	"byschiitv/pkg/playlist"
+)
+
+// PromoDir is where rendered promos are written. Overridden from PROMO_DIR
+// in main; it must be readable by the player, like any video path.
+var PromoDir = filepath.Join(os.TempDir(), "byschiitv-promo")
+
+const (
+	// DefaultPromoSeconds is the length of a promo unless asked otherwise
+	DefaultPromoSeconds = 15
+	// MaxPromoEntries is how many programmes fit on the promo card
+	MaxPromoEntries = 5
+	// promoRenderTimeout bounds one render
+	promoRenderTimeout = 5 * time.Minute
+	// maxPromoJobs is how many renders are remembered, oldest dropped first
+	maxPromoJobs = 20
+)
+
+// promoSkipTypes are the programme types a promo leaves out: breaks and
+// fillers rather than programmes.
+var promoSkipTypes = map[string]bool{"idle": true, "textcard": true, "testpattern": true, "ident": true}
+
+// promoEntries picks up to count programmes starting at or after from.
+func promoEntries(epg []EPGEntry, from time.Time, count int) []EPGEntry {
+	var picked []EPGEntry
+	for _, entry := range epg {
+		if len(picked) == count {
+			break
+		}
+		if promoSkipTypes[entry.Type] || entry.Start.Before(from) {
+			continue
+		}
+		picked = append(picked, entry)
+	}
+	return picked
+}
+
+// FfmpegPromoCommand renders a "tonight on" card to the file out: the
+// programmes of entries with their start time and artwork, in the colors of
+// the active branding package.
+func FfmpegPromoCommand(out string, seconds int, entries []EPGEntry) ([]string, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("nothing to promote")
+	}
+	const width, height, rate = 1280, 720, 25
+	const rowTop, rowHeight = 170, 100
+	const thumbWidth, thumbHeight = 160, 90
+	brand := activeBranding()
+	palette := brand.Palette
+	duration := strconv.Itoa(seconds)
+
+	background := filtergraph.Color{Width: width, Height: height, Rate: rate, Color: palette.Background}
+	if err := background.Validate(); err != nil {
+		return nil, fmt.Errorf("promo background: %w", err)
+	}
+	args := []string{
+		"-f", "lavfi", "-t", duration, "-i", background.String(),
+		"-f", "lavfi", "-t", duration, "-i", "anullsrc=channel_layout=stereo:sample_rate=44100",
+	}
+
+	card := filtergraph.FilterChain{Inputs: []string{"0:v"}, Filters: []filtergraph.Filter{
+		filtergraph.Format{PixFmt: "yuv420p"},
+		filtergraph.DrawText{
+			Text:      ScreenText.TonightOn,
+			FontFile:  brand.Font,
+			FontSize:  56,
+			FontColor: palette.Primary,
+			X:         "(w-text_w)/2",
+			Y:         "60",
+			Alpha:     "min(1,t)",
+		},
+	}}
+	var graph filtergraph.FilterGraph
+	input := 2
+	for i, entry := range entries {
+		y := rowTop + i*rowHeight
+		// rows appear one after the other
+		alpha := fmt.Sprintf("min(1,max(0,t-%.1f))", 0.5+0.3*float64(i))
+		card = card.Then(
+			filtergraph.DrawText{
+				Text:      entry.Start.Local().Format("15:04"),
+				FontFile:  brand.Font,
+				FontSize:  36,
+				FontColor: palette.Secondary,
+				X:         "120",
+				Y:         strconv.Itoa(y + 25),
+				Alpha:     alpha,
+			},
+			filtergraph.DrawText{
+				Text:      entry.Title,
+				FontFile:  brand.Font,
+				FontSize:  36,
+				FontColor: palette.Text,
+				X:         strconv.Itoa(260 + thumbWidth + 30),
+				Y:         strconv.Itoa(y + 25),
+				Alpha:     alpha,
+			},
+		)
+		if entry.Metadata.Artwork == "" {
+			continue
+		}
+		args = append(args, "-loop", "1", "-t", duration, "-i", entry.Metadata.Artwork)
+		thumb := fmt.Sprintf("thumb%d", i)
+		graph = append(graph, filtergraph.FilterChain{
+			Inputs: []string{strconv.Itoa(input) + ":v"},
+			Filters: []filtergraph.Filter{
+				filtergraph.Scale{Width: thumbWidth, Height: thumbHeight, FitInside: true},
+				filtergraph.Pad{Width: thumbWidth, Height: thumbHeight, Color: "black"},
+			},
+			Outputs: []string{thumb},
+		})
+		input++
+		// each thumbnail is composited on what the previous step produced
+		composited := fmt.Sprintf("card%d", i)
+		card.Outputs = []string{composited}
+		graph = append(graph, card)
+		card = filtergraph.FilterChain{
+			Inputs:  []string{composited, thumb},
+			Filters: []filtergraph.Filter{filtergraph.Overlay{X: "260", Y: strconv.Itoa(y), Shortest: true}},
+		}
+	}
+	graph = append(graph, brand.OverlayLogo(card, input)...)
+	if err := graph.Validate(); err != nil {
+		return nil, fmt.Errorf("promo filter: %w", err)
+	}
+
+	args = append(args, brand.LogoInputArgs()...)
+	return append(args,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "1:a",
+		"-t", duration,
+		"-c:v", "h264_v4l2m2m",
+		"-b:v", "2000k",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "64k",
+		"-movflags", "+faststart",
+		"-y", out,
+	), nil
+}
+
+// RenderPromo renders a promo of up to count programmes starting from from,
+// as listed by the EPG, and returns the file path. The file is named after
+// the job id, so renders started together never overwrite each other.
+func (s *Server) RenderPromo(ctx context.Context, id string, from time.Time, count, seconds int) (string, []EPGEntry, error) {
+	entries := promoEntries(s.EPG(ctx), from, count)
+	out := filepath.Join(PromoDir, "promo-"+time.Now().Format("20060102-150405")+"-"+id+".mp4")
+	args, err := FfmpegPromoCommand(out, seconds, entries)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(PromoDir, 0o755); err != nil {
+		return "", nil, fmt.Errorf("promo dir: %w", err)
+	}
+	// 2000k of video and 64k of audio
+	if err := checkSpace(PromoDir, int64(seconds)*2064*1000/8); err != nil {
+		return "", nil, err
+	}
+	var stderr bytes.Buffer
+	args = append([]string{"-hide_banner", "-loglevel", "error"}, args...)
+	if err := commandRunner.Run(ctx, "ffmpeg", args, nil, &stderr); err != nil {
+		return "", nil, fmt.Errorf("rendering promo: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, entries, nil
+}
+
+// PromoJob is a promo render started by POST /promo, polled with
+// GET /promo/:id.
+type PromoJob struct {
+	ID string `json:"id"`
+	// Status is rendering, done or failed
+	Status     string     `json:"status"`
+	Path       string     `json:"path,omitempty"`
+	Programmes []EPGEntry `json:"programmes,omitempty"`
+	// Item is what was, or with enqueue would be, added to the playlist
+	Item     *playlist.VideoElement `json:"item,omitempty"`
+	Enqueue  bool                   `json:"enqueue"`
+	Enqueued bool                   `json:"enqueued"`
+	Error    string                 `json:"error,omitempty"`
+	Started  time.Time              `json:"started"`
+	Finished time.Time              `json:"finished,omitempty"`
+}
+
+// promoJobs holds the recent renders, oldest first.
+type promoJobs struct {
+	mu   sync.Mutex
+	jobs []*PromoJob
+}
+
+// StartPromo renders a promo in the background, as RenderPromo, and with
+// enqueue appends it to the playlist once rendered, unless draining by then.
+func (s *Server) StartPromo(from time.Time, count, seconds int, enqueue bool) PromoJob {
+	job := &PromoJob{ID: playlist.NewEntryID(), Status: "rendering", Enqueue: enqueue, Started: time.Now()}
+	s.promos.mu.Lock()
+	s.promos.jobs = append(s.promos.jobs, job)
+	if len(s.promos.jobs) > maxPromoJobs {
+		s.promos.jobs = s.promos.jobs[len(s.promos.jobs)-maxPromoJobs:]
+	}
+	started := *job
+	s.promos.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), promoRenderTimeout)
+		defer cancel()
+		path, entries, err := s.RenderPromo(ctx, job.ID, from, count, seconds)
+		var item *playlist.VideoElement
+		enqueued := false
+		if err == nil {
+			item = &playlist.VideoElement{
+				Metadata:     playlist.Metadata{Title: ScreenText.TonightOn},
+				Entry:        playlist.Entry{ID: playlist.NewEntryID()},
+				Path:         path,
+				QualityIndex: 1,
+			}
+			// like /enqueue, nothing is added while draining
+			if enqueue && !s.IsDraining() {
+				s.AppendElement(*item)
+				enqueued = true
+			}
+		} else {
+			log.Printf("promo: %v", err)
+		}
+
+		s.promos.mu.Lock()
+		defer s.promos.mu.Unlock()
+		job.Finished = time.Now()
+		job.Path, job.Programmes, job.Item, job.Enqueued = path, entries, item, enqueued
+		job.Status = "done"
+		if err != nil {
+			job.Status, job.Error = "failed", err.Error()
+		}
+	}()
+	return started
+}
+
+// PromoJob returns the render with id.
+func (s *Server) PromoJob(id string) (PromoJob, bool) {
+	s.promos.mu.Lock()
+	defer s.promos.mu.Unlock()
+	for _, job := range s.promos.jobs {
+		if job.ID == id {
+			return *job, true
+		}
+	}
+	return PromoJob{}, false
+}
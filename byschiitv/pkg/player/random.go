@@ -0,0 +1,41 @@
+package player
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+// maxAiredHistory bounds the list of recently aired files kept for
+// RandomElement.
+const maxAiredHistory = 500
+
+// pickRandom chooses a file from files not present in the last avoidLast
+// entries of aired (oldest first). If everything aired recently, the file
+// aired longest ago wins so the rotation still moves forward.
+func pickRandom(files []string, aired []string, avoidLast int) (string, error) {
+	if len(files) == 0 {
+		return "", fmt.Errorf("no media files to pick from")
+	}
+	if avoidLast > len(aired) {
+		avoidLast = len(aired)
+	}
+	recent := aired[len(aired)-avoidLast:]
+
+	var candidates []string
+	for _, f := range files {
+		if !slices.Contains(recent, f) {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[rand.IntN(len(candidates))], nil
+	}
+
+	for _, f := range recent {
+		if slices.Contains(files, f) {
+			return f, nil
+		}
+	}
+	return files[rand.IntN(len(files))], nil
+}
@@ -0,0 +1,156 @@
+package player
+
+import (
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// ReconcileTolerance is how far from its start_at an item may start and
+// still count as on time, unless the request asks otherwise.
+const ReconcileTolerance = 5 * time.Second
+
+// ReconcileItem is one planned or aired item of a reconciliation.
+type ReconcileItem struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Status is on_time, late, early, skipped (planned, never aired),
+	// pending (planned later today) or unplanned (aired, not planned)
+	Status       string     `json:"status"`
+	PlannedStart *time.Time `json:"planned_start,omitempty"`
+	ActualStart  *time.Time `json:"actual_start,omitempty"`
+	ActualEnd    *time.Time `json:"actual_end,omitempty"`
+	// DriftSeconds is actual minus planned start, positive when late
+	DriftSeconds *float64 `json:"drift_seconds,omitempty"`
+	// Outcome is how the airing ended, see AsRunEntry.Status
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// Reconciliation compares the planned schedule of a day, the items with a
+// start_at, with its as-run log.
+type Reconciliation struct {
+	Date             string          `json:"date"`
+	ToleranceSeconds float64         `json:"tolerance_seconds"`
+	Planned          int             `json:"planned"`
+	Aired            int             `json:"aired"`
+	OnTime           int             `json:"on_time"`
+	Drifted          int             `json:"drifted"`
+	Skipped          int             `json:"skipped"`
+	Pending          int             `json:"pending"`
+	Unplanned        int             `json:"unplanned"`
+	MaxDriftSeconds  float64         `json:"max_drift_seconds"`
+	Items            []ReconcileItem `json:"items"`
+}
+
+// plannedItem is an item with a start_at on the reconciled day.
+type plannedItem struct {
+	id    string
+	title string
+	start time.Time
+}
+
+// Reconcile diffs the plan of day (YYYY-MM-DD) against what aired. The plan
+// is made of the playlist items with a start_at that day, plus the ones
+// that aired with one and have since left the playlist.
+func (s *Server) Reconcile(day string, tolerance time.Duration) (Reconciliation, error) {
+	aired, err := ReadAsRun(day)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Reconciliation{}, err
+	}
+	onDay := func(t *time.Time) bool {
+		return t != nil && t.Local().Format(asRunDate) == day
+	}
+
+	var plan []plannedItem
+	planned := map[string]bool{}
+	for _, element := range s.loadView().playlist {
+		if startAt := element.Sched().StartAt; onDay(startAt) && !planned[element.EntryID()] {
+			planned[element.EntryID()] = true
+			plan = append(plan, plannedItem{id: element.EntryID(), title: playlist.DisplayTitle(element), start: *startAt})
+		}
+	}
+	for _, e := range aired {
+		if onDay(e.PlannedStart) && !planned[e.ID] {
+			planned[e.ID] = true
+			plan = append(plan, plannedItem{id: e.ID, title: e.Title, start: *e.PlannedStart})
+		}
+	}
+
+	rec := Reconciliation{Date: day, ToleranceSeconds: tolerance.Seconds(), Planned: len(plan), Aired: len(aired), Items: []ReconcileItem{}}
+	matched := make([]bool, len(aired))
+	now := time.Now()
+	for _, p := range plan {
+		item := ReconcileItem{ID: p.id, Title: p.title, PlannedStart: &p.start}
+		// an item looping all day airs many times: take the closest airing
+		best := -1
+		for i, e := range aired {
+			if matched[i] || e.ID != p.id {
+				continue
+			}
+			if best < 0 || e.Start.Sub(p.start).Abs() < aired[best].Start.Sub(p.start).Abs() {
+				best = i
+			}
+		}
+		switch {
+		case best >= 0:
+			matched[best] = true
+			e := aired[best]
+			drift := e.Start.Sub(p.start)
+			seconds := math.Round(drift.Seconds()*10) / 10
+			item.ActualStart, item.ActualEnd = &e.Start, &e.End
+			item.DriftSeconds = &seconds
+			item.Outcome = e.Status
+			switch {
+			case drift > tolerance:
+				item.Status = "late"
+			case drift < -tolerance:
+				item.Status = "early"
+			default:
+				item.Status = "on_time"
+			}
+			if item.Status == "on_time" {
+				rec.OnTime++
+			} else {
+				rec.Drifted++
+			}
+			rec.MaxDriftSeconds = max(rec.MaxDriftSeconds, math.Abs(seconds))
+		case p.start.After(now):
+			item.Status = "pending"
+			rec.Pending++
+		default:
+			item.Status = "skipped"
+			rec.Skipped++
+		}
+		rec.Items = append(rec.Items, item)
+	}
+	for i, e := range aired {
+		if matched[i] {
+			continue
+		}
+		rec.Unplanned++
+		rec.Items = append(rec.Items, ReconcileItem{
+			ID:          e.ID,
+			Title:       e.Title,
+			Status:      "unplanned",
+			ActualStart: &e.Start,
+			ActualEnd:   &e.End,
+			Outcome:     e.Status,
+		})
+	}
+
+	// in broadcast order: planned time, or aired time for unplanned items
+	at := func(item ReconcileItem) time.Time {
+		if item.PlannedStart != nil {
+			return *item.PlannedStart
+		}
+		return *item.ActualStart
+	}
+	slices.SortStableFunc(rec.Items, func(a, b ReconcileItem) int {
+		return at(a).Compare(at(b))
+	})
+	return rec, nil
+}
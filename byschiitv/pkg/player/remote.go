@@ -0,0 +1,53 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// remoteCheckTimeout bounds the reachability check done when a remote item is
+// added, so a dead NAS doesn't hang /load.
+const remoteCheckTimeout = 10 * time.Second
+
+// CheckRemoteURL verifies that url can be streamed. A HEAD request is tried
+// first; servers that refuse HEAD get a second chance through ffprobe.
+func CheckRemoteURL(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, remoteCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid url %s: %w", url, err)
+	}
+	resp, headErr := http.DefaultClient.Do(req)
+	if headErr == nil {
+		resp.Body.Close()
+		if resp.StatusCode < http.StatusBadRequest {
+			return nil
+		}
+		headErr = fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	if _, err := GetVideoDuration(ctx, url); err != nil {
+		return fmt.Errorf("%s unreachable: %w", url, headErr)
+	}
+	return nil
+}
+
+// checkRemoteElements checks every remote video of a playlist.
+func checkRemoteElements(ctx context.Context, elements []playlist.PlaylistElement) error {
+	var errs []error
+	for _, element := range elements {
+		if video, ok := element.(playlist.VideoElement); ok && playlist.IsRemoteURL(video.Path) {
+			if err := CheckRemoteURL(ctx, video.Path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
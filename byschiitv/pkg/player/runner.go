@@ -0,0 +1,38 @@
+package player
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+)
+
+// Runner runs the external tools: ffmpeg, ffprobe and yt-dlp. Everything
+// goes through commandRunner, so tests can swap in a FakeRunner (runner_test.go) and check
+// the arguments without the tools installed.
+type Runner interface {
+	// Run runs name with args until it exits or ctx is cancelled.
+	// stdout and stderr may be nil to discard the output.
+	Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error
+}
+
+// commandRunner is the Runner used by StreamToRTMP, GetVideoDuration and
+// yt-dlp calls.
+var commandRunner Runner = execRunner{}
+
+// execRunner runs real processes with os/exec.
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// runOutput runs name and returns its standard output, like exec.Cmd.Output.
+func runOutput(ctx context.Context, stderr io.Writer, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	err := commandRunner.Run(ctx, name, args, &out, stderr)
+	return out.Bytes(), err
+}
@@ -0,0 +1,189 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"byschiitv/pkg/ffmpegopts"
+	"byschiitv/pkg/playlist"
+)
+
+// FakeCall is a command a FakeRunner was asked to run.
+type FakeCall struct {
+	Name string
+	Args []string
+}
+
+// FakeRunner records commands instead of running them. Respond, when set,
+// writes the command's output and returns its error; otherwise commands
+// succeed silently, or end with ctx.Err() if ctx is already done.
+type FakeRunner struct {
+	mu      sync.Mutex
+	calls   []FakeCall
+	Respond func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error
+}
+
+func (f *FakeRunner) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, FakeCall{Name: name, Args: slices.Clone(args)})
+	respond := f.Respond
+	f.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if respond == nil {
+		return nil
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	return respond(ctx, name, args, stdout, stderr)
+}
+
+// Calls returns the commands run so far.
+func (f *FakeRunner) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// useFakeRunner swaps commandRunner for f until the test ends.
+func useFakeRunner(t *testing.T, f *FakeRunner) *FakeRunner {
+	t.Helper()
+	saved := commandRunner
+	commandRunner = f
+	t.Cleanup(func() { commandRunner = saved })
+	return f
+}
+
+func TestGetVideoDurationProbesWithFfprobe(t *testing.T) {
+	fake := useFakeRunner(t, &FakeRunner{
+		Respond: func(_ context.Context, name string, args []string, stdout, stderr io.Writer) error {
+			fmt.Fprint(stdout, `{"format": {"filename": "x.mp4", "duration": "83.520000"}}`)
+			return nil
+		},
+	})
+	// a missing file is never cached, so the probe always runs
+	path := t.TempDir() + "/missing.mp4"
+
+	d, err := GetVideoDuration(context.Background(), path)
+	if err != nil {
+		t.Fatalf("GetVideoDuration: %v", err)
+	}
+	if want := 83520 * time.Millisecond; d != want {
+		t.Errorf("duration = %s, want %s", d, want)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("ran %d commands, want 1", len(calls))
+	}
+	want := []string{"-v", "error", "-show_format", "-of", "json", path}
+	if calls[0].Name != "ffprobe" || !slices.Equal(calls[0].Args, want) {
+		t.Errorf("ran %s %q, want ffprobe %q", calls[0].Name, calls[0].Args, want)
+	}
+}
+
+func TestGetVideoDurationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		wantErr string
+	}{
+		{"runner fails", "", errors.New("exit status 1"), "ffprobe failed"},
+		{"not json", "garbage", nil, "failed to parse ffprobe output"},
+		{"no duration", `{"format": {"duration": "N/A"}}`, nil, "invalid duration format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeRunner(t, &FakeRunner{
+				Respond: func(_ context.Context, name string, args []string, stdout, stderr io.Writer) error {
+					fmt.Fprint(stdout, tt.output)
+					return tt.err
+				},
+			})
+			_, err := GetVideoDuration(context.Background(), t.TempDir()+"/missing.mp4")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, does not wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestStreamToRTMPVideoArgs(t *testing.T) {
+	fake := useFakeRunner(t, &FakeRunner{})
+	const url = "rtmp://nginx:1935/live/stream"
+	video := playlist.VideoElement{Path: "/media/film.mp4", QualityIndex: 1, TrimStart: 10}
+
+	if err := StreamToRTMP(context.Background(), video, url); err != nil {
+		t.Fatalf("StreamToRTMP: %v", err)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Name != "ffmpeg" {
+		t.Fatalf("calls = %v, want one ffmpeg", calls)
+	}
+	want, err := ffmpegopts.FfmpegCommand(activeBranding(), video.Path, url, false, 1, "", 10*time.Second, 0)
+	if err != nil {
+		t.Fatalf("FfmpegCommand: %v", err)
+	}
+	args := calls[0].Args
+	if !slices.Equal(args, want) {
+		t.Errorf("args = %q\nwant %q", args, want)
+	}
+	if args[len(args)-1] != url || args[len(args)-3] != "-f" || args[len(args)-2] != "flv" {
+		t.Errorf("args do not end with -f flv %s: %q", url, args)
+	}
+	if i := slices.Index(args, "-ss"); i < 0 || args[i+1] != "10.000" || i > slices.Index(args, "-i") {
+		t.Errorf("want input seek -ss 10.000 before -i: %q", args)
+	}
+}
+
+func TestStreamToRTMPPastTrimEnd(t *testing.T) {
+	fake := &FakeRunner{}
+	useFakeRunner(t, fake)
+	// restarted at 50s into a file trimmed to end at 40s
+	video := playlist.VideoElement{Path: "/media/film.mp4", QualityIndex: 1, TrimStart: 10, TrimEnd: 40, Offset: 40 * time.Second}
+	if err := StreamToRTMP(context.Background(), video, "rtmp://nginx/live/stream"); err != nil {
+		t.Fatalf("StreamToRTMP: %v", err)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("aired past the trimmed end: %v", calls)
+	}
+	if _, err := ffmpegopts.FfmpegCommand(activeBranding(), video.Path, "rtmp://nginx/live/stream", false, 1, "", 50*time.Second, 40*time.Second); err == nil {
+		t.Error("FfmpegCommand accepted a start past the end")
+	}
+}
+
+func TestStreamToRTMPRunnerError(t *testing.T) {
+	failure := errors.New("exit status 1")
+	useFakeRunner(t, &FakeRunner{
+		Respond: func(context.Context, string, []string, io.Writer, io.Writer) error { return failure },
+	})
+	err := StreamToRTMP(context.Background(), playlist.VideoElement{Path: "/media/film.mp4"}, "rtmp://nginx/live/stream")
+	if !errors.Is(err, failure) {
+		t.Errorf("err = %v, want it to wrap %v", err, failure)
+	}
+}
+
+func TestStreamToRTMPCancelled(t *testing.T) {
+	useFakeRunner(t, &FakeRunner{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := StreamToRTMP(ctx, playlist.VideoElement{Path: "/media/film.mp4"}, "rtmp://nginx/live/stream")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
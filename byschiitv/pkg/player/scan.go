@@ -0,0 +1,107 @@
+package player
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MediaDir is the library scanned at startup and by /rescan.
+// Overridden from MEDIA_DIR in main.
+var MediaDir = "/media"
+
+// ScanWorkers bounds the ffprobe processes of a scan, leaving the Pi room
+// to keep encoding the stream. Overridden from SCAN_WORKERS in main.
+var ScanWorkers = min(runtime.NumCPU(), 4)
+
+// ScanStatus reports the progress of a library scan.
+type ScanStatus struct {
+	Running  bool      `json:"running"`
+	Dir      string    `json:"dir"`
+	Total    int       `json:"total"`
+	Done     int       `json:"done"`
+	Failed   int       `json:"failed"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// mediaScan probes the library in the background, so durations are cached
+// before the EPG or the schedulebuilder need them.
+type mediaScan struct {
+	mu     sync.Mutex
+	status ScanStatus
+}
+
+// Rescan starts a scan of MediaDir unless one is running. It returns the
+// status and whether this call started the scan.
+func (s *Server) Rescan() (ScanStatus, bool) {
+	s.scan.mu.Lock()
+	defer s.scan.mu.Unlock()
+	if s.scan.status.Running {
+		return s.scan.status, false
+	}
+	s.scan.status = ScanStatus{Running: true, Dir: MediaDir, Started: time.Now()}
+	go s.runScan(MediaDir)
+	return s.scan.status, true
+}
+
+// ScanStatus returns the progress of the running or last scan.
+func (s *Server) ScanStatus() ScanStatus {
+	s.scan.mu.Lock()
+	defer s.scan.mu.Unlock()
+	return s.scan.status
+}
+
+func (s *Server) runScan(dir string) {
+	update := func(f func(st *ScanStatus)) {
+		s.scan.mu.Lock()
+		defer s.scan.mu.Unlock()
+		f(&s.scan.status)
+	}
+	defer update(func(st *ScanStatus) {
+		st.Running = false
+		st.Finished = time.Now()
+		log.Printf("scan: %s done, %d files, %d failed, in %s", dir, st.Total, st.Failed, st.Finished.Sub(st.Started).Round(time.Second))
+	})
+	if DurationCacheFile != "" {
+		defer probeCache.save(DurationCacheFile)
+	}
+
+	files, err := listMediaFiles(dir)
+	if err != nil {
+		log.Printf("scan: %v", err)
+		update(func(st *ScanStatus) { st.Error = err.Error() })
+		return
+	}
+	update(func(st *ScanStatus) { st.Total = len(files) })
+
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for range max(ScanWorkers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				_, err := GetVideoDuration(context.Background(), path)
+				update(func(st *ScanStatus) {
+					st.Done++
+					if err != nil {
+						st.Failed++
+						log.Printf("scan: %v", err)
+					}
+					if st.Done%100 == 0 {
+						log.Printf("scan: %d/%d", st.Done, st.Total)
+					}
+				})
+			}
+		}()
+	}
+	for _, path := range files {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
+}
@@ -0,0 +1,84 @@
+package player
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// waitForStart blocks until sc.StartAt (or ctx is done) and returns how late
+// the element is, 0 when on time or unscheduled.
+func waitForStart(ctx context.Context, sc playlist.Scheduling) time.Duration {
+	if sc.StartAt == nil {
+		return 0
+	}
+	if clock := wallClock.Status(); !clock.OK {
+		log.Printf("WARNING clock: start at %s relies on an untrusted clock: %s", sc.StartAt.Format(time.RFC3339), clock.Warning)
+	}
+	until := time.Until(*sc.StartAt)
+	if until <= 0 {
+		return -until
+	}
+	log.Printf("waiting %s for scheduled start at %s", until.Round(time.Second), sc.StartAt.Format(time.RFC3339))
+	timer := time.NewTimer(until)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	return 0
+}
+
+// joinInProgress starts item join into its runtime, like a broadcaster joining
+// a programme already in progress: files start join in, with ffmpeg -ss,
+// and fixed length items air for what is left of their slot. Other items
+// air in full. ok is false when the item would already be over.
+func joinInProgress(ctx context.Context, item playlist.PlaylistElement, join time.Duration) (playlist.PlaylistElement, bool) {
+	switch item := item.(type) {
+	case playlist.VideoElement:
+		if d, err := GetVideoDuration(ctx, item.Path); err == nil && item.Offset+join >= item.Trimmed(d) {
+			return nil, false
+		}
+		item.Offset += join
+		log.Printf("joining %s in progress at %s", item.Desc(), item.Offset.Round(time.Second))
+		return item, true
+	case playlist.AudioElement:
+		if d, err := GetVideoDuration(ctx, item.Path); err == nil && item.Offset+join >= d {
+			return nil, false
+		}
+		item.Offset += join
+		log.Printf("joining %s in progress at %s", item.Desc(), item.Offset.Round(time.Second))
+		return item, true
+	case playlist.IdleElement:
+		item.IdleSeconds -= int(join.Seconds())
+		if item.IdleSeconds <= 0 {
+			return nil, false
+		}
+		return item, true
+	case playlist.TestPatternElement:
+		item.DurationSeconds = shortenBy(item.DurationSeconds, playlist.DefaultTestPatternSeconds, join)
+		return item, item.DurationSeconds > 0
+	case playlist.TextCardElement:
+		item.DurationSeconds = shortenBy(item.DurationSeconds, playlist.DefaultTextCardSeconds, join)
+		return item, item.DurationSeconds > 0
+	case playlist.LiveElement:
+		// a live source cannot seek, but a bounded relay can still end on time
+		if item.DurationSeconds > 0 {
+			item.DurationSeconds = shortenBy(item.DurationSeconds, 0, join)
+			return item, item.DurationSeconds > 0
+		}
+		return item, true
+	default:
+		return item, true
+	}
+}
+
+// shortenBy takes join off a length in seconds, def when unset.
+func shortenBy(seconds, def int, join time.Duration) int {
+	if seconds <= 0 {
+		seconds = def
+	}
+	return max(seconds-int(join.Seconds()), 0)
+}
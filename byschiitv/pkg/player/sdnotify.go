@@ -0,0 +1,164 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Readiness and watchdog notifications for systemd, see sd_notify(3). They
+// are off unless systemd sets NOTIFY_SOCKET, as it does for Type=notify
+// units and never inside Docker:
+//
+//	[Service]
+//	Type=notify
+//	NotifyAccess=main
+//	WatchdogSec=60
+//	Restart=on-failure
+
+const (
+	// readyRetry is the wait between reachability checks before READY=1
+	readyRetry = 5 * time.Second
+	// playerStallAfter is how long the player loop may go without a
+	// heartbeat outside of an airing before it counts as wedged
+	playerStallAfter = 10 * time.Second
+	// lockStallAfter is how long taking s.mu may take before the server
+	// counts as deadlocked
+	lockStallAfter = 5 * time.Second
+)
+
+// SdNotify sends state to systemd, doing nothing outside of a notify unit.
+func SdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// a leading @ is an abstract socket
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("sd_notify: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("sd_notify: %w", err)
+	}
+	return nil
+}
+
+// watchdogInterval is how often systemd wants WATCHDOG=1, zero when the
+// watchdog is off or meant for another process.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// rtmpReachable dials the host of an RTMP URL, port 1935 unless given.
+func rtmpReachable(ctx context.Context, rtmpURL string) error {
+	u, err := url.Parse(rtmpURL)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "1935")
+	}
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// NotifyReady tells systemd the server is up once the media scan is done
+// and the RTMP destination answers, reporting what it waits for meanwhile.
+func (s *Server) NotifyReady(ctx context.Context) {
+	for {
+		var waiting string
+		st := s.ScanStatus()
+		switch {
+		case st.Started.IsZero():
+			waiting = "waiting for the media scan"
+		case st.Running:
+			waiting = fmt.Sprintf("scanning %s: %d/%d", st.Dir, st.Done, st.Total)
+		default:
+			dest := s.dest.current()
+			if err := rtmpReachable(ctx, dest); err != nil {
+				waiting = fmt.Sprintf("waiting for %s: %v", dest, err)
+			}
+		}
+		if waiting == "" {
+			break
+		}
+		SdNotify("STATUS=" + waiting)
+		if !sleepCtx(ctx, readyRetry) {
+			return
+		}
+	}
+	if err := SdNotify("READY=1\nSTATUS=on air"); err != nil {
+		log.Printf("systemd: %v", err)
+	}
+}
+
+// beat records that the player loop went round.
+func (s *Server) beat() {
+	s.heartbeat.Store(time.Now().UnixNano())
+}
+
+// playerStalled says why the player looks wedged, "" when it does not:
+// mu held for too long, or a running loop that stopped going round while
+// nothing airs. Airing and waiting for start_at block the loop by design.
+func (s *Server) playerStalled() string {
+	deadline := time.Now().Add(lockStallAfter)
+	for !s.mu.TryRLock() {
+		if time.Now().After(deadline) {
+			return fmt.Sprintf("server lock held for more than %s", lockStallAfter)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	s.mu.RUnlock()
+
+	switch s.loadView().state {
+	case StateStopped, StatePlaying, StateDraining:
+		return ""
+	}
+	if since := time.Since(time.Unix(0, s.heartbeat.Load())); since > playerStallAfter {
+		return fmt.Sprintf("player loop silent for %s", since.Round(time.Second))
+	}
+	return ""
+}
+
+// watchdog pets the systemd watchdog at half its interval while the player
+// is healthy, so a wedged process misses it and gets restarted.
+func (s *Server) watchdog(ctx context.Context) {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+	log.Printf("systemd: watchdog every %s", interval)
+	for sleepCtx(ctx, interval/2) {
+		if reason := s.playerStalled(); reason != "" {
+			log.Printf("systemd: not petting the watchdog: %s", reason)
+			continue
+		}
+		if err := SdNotify("WATCHDOG=1"); err != nil {
+			log.Printf("systemd: %v", err)
+		}
+	}
+}
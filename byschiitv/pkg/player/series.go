@@ -0,0 +1,131 @@
+package player
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SeriesStateFile is where episode positions survive restarts.
+// Overridden from SERIES_STATE_FILE.
+var SeriesStateFile = "series_state.json"
+
+var mediaExtensions = map[string]struct{}{
+	".mp4": {}, ".mkv": {}, ".avi": {}, ".mov": {}, ".flv": {}, ".wmv": {},
+	".mpg": {}, ".mpeg": {}, ".webm": {}, ".m4v": {}, ".ts": {},
+}
+
+// listMediaFiles returns the media files below dir sorted by path, which for
+// the usual naming (S01E01, S01E02, Season 2/...) is airing order.
+func listMediaFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, ok := mediaExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// SeriesTracker remembers the last aired episode of each series directory.
+// Tracking the file rather than an index keeps the position right when
+// episodes are added later.
+type SeriesTracker struct {
+	mu        sync.Mutex
+	path      string
+	lastAired map[string]string
+}
+
+func NewSeriesTracker(path string) *SeriesTracker {
+	t := &SeriesTracker{path: path, lastAired: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("series: can't read %s: %v", path, err)
+		}
+		return t
+	}
+	if err := json.Unmarshal(data, &t.lastAired); err != nil {
+		log.Printf("series: ignoring corrupt %s: %v", path, err)
+		t.lastAired = map[string]string{}
+	}
+	return t
+}
+
+// NextEpisode returns the episode following the last aired one, starting over
+// once the series is finished.
+func (t *SeriesTracker) NextEpisode(dir string) (string, error) {
+	files, err := listMediaFiles(dir)
+	if err != nil {
+		return "", fmt.Errorf("series %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("series %s: no episodes found", dir)
+	}
+
+	t.mu.Lock()
+	last := t.lastAired[dir]
+	t.mu.Unlock()
+
+	i := sort.SearchStrings(files, last)
+	if i < len(files) && files[i] == last {
+		i++
+	}
+	if last == "" || i >= len(files) {
+		i = 0
+	}
+	return files[i], nil
+}
+
+// Aired marks episode as watched and persists the position.
+func (t *SeriesTracker) Aired(dir, episode string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastAired[dir] = episode
+	if err := t.save(); err != nil {
+		log.Printf("series: can't save %s: %v", t.path, err)
+	}
+}
+
+// Positions returns the last aired episode per series directory.
+func (t *SeriesTracker) Positions() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]string, len(t.lastAired))
+	for k, v := range t.lastAired {
+		out[k] = v
+	}
+	return out
+}
+
+// save writes the state to a temp file and renames it over the old one, so a
+// crash mid-write never leaves a truncated file behind.
+func (t *SeriesTracker) save() error {
+	data, err := json.MarshalIndent(t.lastAired, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
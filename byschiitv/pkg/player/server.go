@@ -0,0 +1,946 @@
+// Package player is the playout engine: the queue, the ffmpeg runner and
+// everything that keeps the channel on air. cmd/iptvsim serves it over HTTP.
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+const maxQualityEvents = 100
+
+// maxPlaylistDepth stops playlists that (indirectly) include themselves.
+const maxPlaylistDepth = 8
+
+// Server holds the queue and worker control.
+//
+// mu guards every field but view, heartbeat, metrics and series, which are
+// atomic or lock themselves.
+// Readers take mu.RLock, anything that changes state or calls persist takes
+// mu.Lock. Helpers documented "Callers hold s.mu" never lock. Nothing probes
+// durations, runs ffmpeg or waits while holding mu: the player loop only
+// locks around short bookkeeping sections.
+type Server struct {
+	// view is the lock-free copy read by pollers, see snapshot.go
+	view atomic.Pointer[statusView]
+	// heartbeat is when the player loop last went round, see sdnotify.go
+	heartbeat        atomic.Int64
+	viewSeq          uint64
+	mu               sync.RWMutex
+	playlist         []playlist.PlaylistElement
+	currentlyPlaying int
+	loop             bool
+	// shuffle picks the next item at random, by Scheduling.Weight
+	shuffle bool
+	// worker control: if called, stops after current item
+	playerCancel context.CancelFunc
+	state        PlayerState
+	stateEvents  []StateChange
+	// paused holds the player after the current item until resumed
+	paused bool
+	// draining refuses new items and stops the player at the end
+	draining bool
+	// version counts playlist changes, see version.go
+	version uint64
+	// editMu serializes versioned edits from the API
+	editMu sync.Mutex
+	// jumpTo is the index the player goes to after the current item,
+	// -1 to advance normally
+	jumpTo int
+	// current item control
+	currentCancel context.CancelFunc
+	// where the stream is published, see failover.go
+	dest *destinations
+	// automatic quality downgrades, most recent last
+	qualityEvents []QualityEvent
+	series        *SeriesTracker
+	// paths of aired files, oldest first, for RandomElement
+	aired []string
+	// named playlists referenced by PlaylistRefElement
+	saved map[string][]playlist.PlaylistElement
+	// when the current item started airing, zero when idle
+	currentStarted time.Time
+	// statePath is where the queue is persisted, empty for nowhere
+	statePath string
+	// strikes counts failed or skipped airings in a row, by entry ID
+	strikes map[string]int
+	// dead holds the items taken out of the playlist by strikes
+	dead []DeadItem
+	// enqueued maps /enqueue idempotency keys to what they added
+	enqueued map[string]enqueuedKey
+	metrics  queueMetrics
+	scan     mediaScan
+	// background promo renders, see promo.go
+	promos promoJobs
+	// cue-out/cue-in events of the recent and current items
+	cues []CueEvent
+}
+
+type PlayerStatus struct {
+	State             PlayerState
+	Running           bool
+	Playing           bool
+	Paused            bool
+	CurrentIdx        int
+	Loop              bool
+	Length            int
+	ProgrammedSeconds int
+	ProgrammedHours   float32
+	Destination       DestinationStatus
+	Clock             ClockStatus
+	Hardware          Telemetry
+	Thermal           ThermalStatus
+	Disk              DiskStatus
+}
+
+// NewServer publishes to rtmpURL, a comma separated list of destinations
+// in order of preference.
+func NewServer(rtmpURL string) *Server {
+	urls := ParseDestinations(rtmpURL)
+	if len(urls) == 0 {
+		urls = []string{"rtmp://iptvsim-nginx:1935/live/stream"}
+	}
+	s := &Server{
+		loop:      true,
+		state:     StateStopped,
+		jumpTo:    -1,
+		dest:      &destinations{urls: urls},
+		series:    NewSeriesTracker(SeriesStateFile),
+		statePath: QueueStateFile,
+	}
+	s.restore()
+	s.mu.Lock()
+	s.publish()
+	s.mu.Unlock()
+	return s
+}
+
+// RunBackground runs the services around the player until ctx is done:
+// with backup destinations, going back to the primary; the clock check
+// start_at depends on; the systemd watchdog; the thermal governor; the
+// disk monitor; and the multiviewer, when configured.
+func (s *Server) RunBackground(ctx context.Context) {
+	go s.dest.failback(ctx, FailbackInterval)
+	go wallClock.run(ctx)
+	go s.watchdog(ctx)
+	go thermal.run(ctx)
+	go disks.run(ctx)
+	if MosaicURL != "" && len(MosaicSources) > 0 {
+		go Mosaic.run(ctx, MosaicSources, MosaicURL)
+	}
+}
+
+func (s *Server) Append(item string) int {
+	return s.AppendElement(playlist.VideoElement{Entry: playlist.Entry{ID: playlist.NewEntryID()}, Path: item, QualityIndex: 1})
+}
+
+// AppendElement adds element at the end of the playlist and returns the
+// new length.
+func (s *Server) AppendElement(element playlist.PlaylistElement) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.playlist = append(s.playlist, element)
+	s.metrics.enqueue(1)
+	s.changed()
+	return len(s.playlist)
+}
+
+// Status reports the player and the total programmed time, from the
+// published view: durations are probed once per playlist version, without
+// holding any lock.
+func (s *Server) Status(ctx context.Context) PlayerStatus {
+	v := s.loadView()
+	st := PlayerStatus{
+		State:       v.state,
+		Running:     v.state != StateStopped,
+		Playing:     v.onAir,
+		Paused:      v.state == StatePaused,
+		CurrentIdx:  v.current,
+		Loop:        v.loop,
+		Length:      len(v.playlist),
+		Destination: s.dest.status(),
+		Clock:       wallClock.Status(),
+		Hardware:    hardware.read(ctx),
+		Thermal:     thermal.Status(),
+		Disk:        disks.Status(),
+	}
+
+	duration := s.programmedSeconds(ctx, v)
+	st.ProgrammedSeconds = duration
+	st.ProgrammedHours = float32(duration) / 3600.0
+	return st
+}
+
+func (s *Server) Remove(index int) (playlist.PlaylistElement, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index < 0 || index >= len(s.playlist) {
+		return nil, false
+	}
+	item := s.playlist[index]
+	s.playlist = slices.Delete(s.playlist, index, index+1)
+	s.changed()
+	return item, true
+}
+
+func (s *Server) List() []playlist.PlaylistElement {
+	return slices.Clone(s.loadView().playlist)
+}
+
+func (s *Server) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.playlist = nil
+	s.changed()
+}
+
+func (s *Server) Current() (playlist.PlaylistElement, bool) {
+	v := s.loadView()
+	if v.current < 0 || v.current >= len(v.playlist) {
+		return nil, false
+	}
+	return v.playlist[v.current], true
+}
+
+func (s *Server) Insert(index int, element playlist.PlaylistElement) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index < 0 || index > len(s.playlist) {
+		return false
+	}
+	s.playlist = slices.Insert(s.playlist, index, element)
+	s.changed()
+	return true
+}
+
+func (s *Server) Length() int {
+	return len(s.loadView().playlist)
+}
+
+// se player running state = true
+// significa che il player e' in esecuzione (puo' essere in pausa)
+// appena un video va in lista, viene riprodotto
+func (s *Server) IsRunning() bool {
+	return s.loadView().state != StateStopped
+}
+
+func (s *Server) IsPlaying() bool {
+	return s.loadView().onAir
+}
+
+// Next cuts the item on air short; the player then advances as usual.
+// False when nothing is on air.
+func (s *Server) Next() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.onAir() {
+		return false
+	}
+	s.jumpTo = -1
+	s.currentCancel()
+	return true
+}
+
+// Previous cuts the item on air short and goes back to the one before it.
+func (s *Server) Previous() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.onAir() {
+		return false
+	}
+	prev := s.currentlyPlaying - 1
+	if prev < 0 {
+		if !s.loop {
+			return false
+		}
+		prev = len(s.playlist) - 1
+	}
+	s.jumpTo = prev
+	s.currentCancel()
+	return true
+}
+
+// SetPaused pauses or resumes the player. Pausing lets the current item
+// finish, then waits instead of starting the next one.
+func (s *Server) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+	if !paused && s.state == StatePaused {
+		s.setState(StateIdle, "resumed")
+	}
+	s.publish()
+}
+
+func (s *Server) IsPaused() bool {
+	return s.loadView().paused
+}
+
+func (s *Server) SetLoop(loop bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loop = loop
+	s.persist()
+}
+
+// SetShuffle switches between playlist order and weighted random picks.
+func (s *Server) SetShuffle(shuffle bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shuffle = shuffle
+	s.persist()
+}
+
+func (s *Server) IsShuffle() bool {
+	return s.loadView().shuffle
+}
+
+func (s *Server) IsLoop() bool {
+	return s.loadView().loop
+}
+
+func (s *Server) StartPlayer() bool {
+	s.mu.Lock()
+	if s.state != StateStopped {
+		s.mu.Unlock()
+		return false
+	}
+	playerLoopCtx, cancel := context.WithCancel(context.Background())
+	s.playerCancel = cancel
+	s.currentlyPlaying = 0
+	s.jumpTo = -1
+	s.setState(StateIdle, "started")
+	s.mu.Unlock()
+
+	go s.playerLoop(playerLoopCtx)
+
+	return true
+}
+
+// GetDuration returns the duration of the video at the given playlist index.
+// Returns error if index is invalid or ffprobe fails.
+func (s *Server) GetDuration(ctx context.Context, index int) (time.Duration, error) {
+	s.mu.RLock()
+	if index < 0 || index >= len(s.playlist) {
+		n := len(s.playlist)
+		s.mu.RUnlock()
+		return 0, fmt.Errorf("index %d out of bounds (playlist length: %d)", index, n)
+	}
+	item := s.playlist[index]
+	s.mu.RUnlock()
+
+	dur, err := s.elementDuration(ctx, item, 0)
+	if err != nil {
+		return 0, fmt.Errorf("index %d: %w", index, err)
+	}
+	return dur, nil
+}
+
+// elementDuration probes how long an element will air. Must be called
+// without s.mu held: probing runs external tools.
+func (s *Server) elementDuration(ctx context.Context, element playlist.PlaylistElement, depth int) (time.Duration, error) {
+	switch item := element.(type) {
+	case playlist.IdleElement:
+		return time.Duration(item.IdleSeconds) * time.Second, nil
+	case playlist.VideoElement:
+		dur, err := GetVideoDuration(ctx, item.Path)
+		if err != nil {
+			return 0, fmt.Errorf("ffprobe error for %s: %w", item.Path, err)
+		}
+		return item.Trimmed(dur), nil
+	case playlist.TestPatternElement:
+		if item.DurationSeconds <= 0 {
+			return playlist.DefaultTestPatternSeconds * time.Second, nil
+		}
+		return time.Duration(item.DurationSeconds) * time.Second, nil
+	case playlist.AudioElement:
+		dur, err := GetVideoDuration(ctx, item.Path)
+		if err != nil {
+			return 0, fmt.Errorf("ffprobe error for %s: %w", item.Path, err)
+		}
+		return dur, nil
+	case playlist.TextCardElement:
+		if item.DurationSeconds <= 0 {
+			return playlist.DefaultTextCardSeconds * time.Second, nil
+		}
+		return time.Duration(item.DurationSeconds) * time.Second, nil
+	case playlist.LiveElement:
+		if item.DurationSeconds <= 0 {
+			return 0, fmt.Errorf("live item %s has no fixed duration", item.URL)
+		}
+		return time.Duration(item.DurationSeconds) * time.Second, nil
+	case playlist.SeriesElement:
+		episode, err := s.series.NextEpisode(item.Directory)
+		if err != nil {
+			return 0, err
+		}
+		dur, err := GetVideoDuration(ctx, episode)
+		if err != nil {
+			return 0, fmt.Errorf("ffprobe error for %s: %w", episode, err)
+		}
+		return dur, nil
+	case playlist.RandomElement:
+		return 0, fmt.Errorf("random item from %s has no fixed duration", item.Directory)
+	case playlist.IdentElement:
+		return identDuration(ctx)
+	case playlist.YouTubeElement:
+		dur, err := GetYouTubeDuration(ctx, item.URL)
+		if err != nil {
+			return 0, fmt.Errorf("yt-dlp error for %s: %w", item.URL, err)
+		}
+		return dur, nil
+	case playlist.PlaylistRefElement:
+		if depth >= maxPlaylistDepth {
+			return 0, fmt.Errorf("playlist %q nested too deep", item.Name)
+		}
+		children, ok := s.SavedPlaylist(item.Name)
+		if !ok {
+			return 0, fmt.Errorf("playlist %q not found", item.Name)
+		}
+		var total time.Duration
+		for _, child := range children {
+			dur, err := s.elementDuration(ctx, child, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			total += dur
+		}
+		return total, nil
+	default:
+		return 0, fmt.Errorf("unknown playlist item type %T", element)
+	}
+}
+
+func (s *Server) playerLoop(playerLoopCtx context.Context) {
+	log.Println("worker: started")
+	defer func() {
+		s.mu.Lock()
+		s.playerCancel = nil
+		s.setState(StateStopped, "player loop ended")
+		s.mu.Unlock()
+		log.Println("worker: stopped")
+	}()
+
+	for {
+		s.beat()
+		select {
+		case <-playerLoopCtx.Done():
+			return
+		default:
+			s.mu.Lock()
+			if s.paused {
+				s.setState(StatePaused, "paused")
+				s.mu.Unlock()
+				sleepCtx(playerLoopCtx, 250*time.Millisecond)
+				continue
+			}
+			if s.currentlyPlaying < 0 || s.currentlyPlaying >= len(s.playlist) {
+				if s.draining {
+					s.mu.Unlock()
+					log.Println("worker: drained")
+					return
+				}
+				s.setState(StateIdle, "nothing to air")
+				s.mu.Unlock()
+				sleepCtx(playerLoopCtx, 250*time.Millisecond) // Wait before checking again
+				continue
+			}
+			item := s.playlist[s.currentlyPlaying]
+
+			itemCtx, itemCancel := context.WithCancel(playerLoopCtx)
+			s.currentCancel = itemCancel
+			s.setState(s.airingState(), item.Desc())
+			s.publish()
+			s.mu.Unlock()
+
+			// timed items wait for their slot, and once late may be
+			// skipped or joined in progress
+			late := waitForStart(itemCtx, item.Sched())
+			if itemCtx.Err() == nil && item.Sched().SkipLate(late) {
+				log.Printf("skipping %s: it should have started %s ago", item.Desc(), late.Round(time.Second))
+				itemCancel()
+				s.mu.Lock()
+				s.advance()
+				s.currentCancel = nil
+				s.publish()
+				s.mu.Unlock()
+				continue
+			}
+			join := item.Sched().JoinOffset(late)
+
+			s.mu.Lock()
+			s.currentStarted = time.Now()
+			started := s.currentStarted
+			if idle, ok := item.(playlist.IdleElement); ok {
+				item = s.fillIdle(idle, s.currentStarted.Add(-join))
+			}
+			s.scheduleCues(item, started, join)
+			s.publish()
+			s.mu.Unlock()
+			s.metrics.start()
+			announce(item)
+
+			// simBackGroundTask(itemCtx, item)
+			// Stream the video file, loop_count times in a row
+			repeats := max(item.Sched().LoopCount, 1)
+			failure := ""
+			playCtx, playCancel := item.Sched().WithTimeout(itemCtx)
+			outcome := "ok"
+			publishFailed := false
+			for i := 0; i < repeats && playCtx.Err() == nil; i++ {
+				rtmpURL := s.dest.current()
+				err := s.playItem(playCtx, item, rtmpURL, join)
+				if err != nil && err != context.Canceled {
+					log.Printf("streaming error: %v", err)
+					failure = err.Error()
+				}
+				var perr *PublishError
+				if errors.As(err, &perr) {
+					publishFailed = true
+				}
+				if playCtx.Err() == nil {
+					s.dest.checkDestination(playerLoopCtx, rtmpURL, err)
+				}
+				// only the first airing is tied to the start time
+				join = 0
+			}
+			if itemCtx.Err() == nil && playCtx.Err() == context.DeadlineExceeded {
+				failure = fmt.Sprintf("timed out after %ds", item.Sched().TimeoutSeconds)
+				log.Printf("%s: %s", item.Desc(), failure)
+				outcome = "timeout"
+			}
+			playCancel()
+			itemCancel()
+
+			s.mu.Lock()
+			s.advance()
+			s.dropCues(item.EntryID())
+			s.currentCancel = nil
+			s.currentStarted = time.Time{}
+			s.publish()
+			s.mu.Unlock()
+
+			// a /stop is nobody's fault, a /next counts like a failure
+			if playerLoopCtx.Err() == nil {
+				if itemCtx.Err() != nil {
+					failure = "skipped"
+					outcome = "skipped"
+				} else if failure != "" && outcome == "ok" {
+					outcome = "failed"
+				}
+				// a destination refusing the stream is not the item's fault
+				if !publishFailed {
+					s.recordOutcome(item, failure)
+				}
+			} else {
+				outcome = "stopped"
+			}
+			s.metrics.finish(outcome, time.Since(started))
+			asRun.record(AsRunEntry{
+				ID:           item.EntryID(),
+				Type:         item.Type(),
+				Title:        playlist.DisplayTitle(item),
+				Start:        started,
+				End:          time.Now(),
+				Status:       outcome,
+				Reason:       failure,
+				PlannedStart: item.Sched().StartAt,
+			})
+
+			if outcome == "failed" || outcome == "timeout" {
+				s.mu.Lock()
+				s.setState(StateError, failure)
+				s.mu.Unlock()
+			}
+			// give the destination time to come back rather than running
+			// through the whole playlist
+			if publishFailed {
+				sleepCtx(playerLoopCtx, publishRetryDelay)
+			}
+		}
+	}
+}
+
+// playItem airs a playlist element, returning when it ends or ctx is
+// cancelled.
+// join skips that much of the element, see Scheduling.LatePolicy.
+func (s *Server) playItem(ctx context.Context, element playlist.PlaylistElement, rtmpURL string, join time.Duration) error {
+	return s.playElement(ctx, element, rtmpURL, 0, join)
+}
+
+// playElement plays nested playlists as a unit: skipping with /next cancels
+// ctx and so skips the whole block.
+func (s *Server) playElement(ctx context.Context, element playlist.PlaylistElement, rtmpURL string, depth int, join time.Duration) error {
+	ref, ok := element.(playlist.PlaylistRefElement)
+	if !ok {
+		return s.playSingle(ctx, element, rtmpURL, join)
+	}
+	if depth >= maxPlaylistDepth {
+		return fmt.Errorf("playlist %q nested too deep", ref.Name)
+	}
+	// looked up at play time, so edits to the saved playlist apply
+	children, ok := s.SavedPlaylist(ref.Name)
+	if !ok {
+		return fmt.Errorf("playlist %q not found", ref.Name)
+	}
+	log.Printf("playlist %q: %d items", ref.Name, len(children))
+	for _, child := range children {
+		late := waitForStart(ctx, child.Sched())
+		if ctx.Err() == nil && child.Sched().SkipLate(late) {
+			log.Printf("playlist %q: skipping %s: it should have started %s ago", ref.Name, child.Desc(), late.Round(time.Second))
+			continue
+		}
+		err := s.playElement(ctx, child, rtmpURL, depth+1, child.Sched().JoinOffset(late))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var perr *PublishError
+		if errors.As(err, &perr) {
+			// the rest of the block would fail the same way
+			return err
+		}
+		if err != nil {
+			log.Printf("playlist %q: streaming error: %v", ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// playSingle airs one concrete element.
+func (s *Server) playSingle(ctx context.Context, element playlist.PlaylistElement, rtmpURL string, join time.Duration) error {
+	item, err := s.resolveElement(ctx, element)
+	if err != nil {
+		return err
+	}
+	if join > 0 {
+		// resolving and probing took a while: catch up with the clock
+		if startAt := element.Sched().StartAt; startAt != nil {
+			join = max(join, time.Since(*startAt))
+		}
+		joined, ok := joinInProgress(ctx, item, join)
+		if !ok {
+			log.Printf("skipping %s: its slot is already over", item.Desc())
+			return nil
+		}
+		item = joined
+	}
+
+	if video, ok := item.(playlist.VideoElement); ok {
+		s.recordAired(video.Path)
+		video.CuePoints = playlist.CuePoints(element.Sched().Breaks, video.Offset)
+		video.QualityIndex = thermal.apply(video.QualityIndex, video.AspectRatio43)
+		item = video
+	}
+	if live, ok := item.(playlist.LiveElement); ok {
+		live.QualityIndex = thermal.apply(live.QualityIndex, live.AspectRatio43)
+		item = live
+	}
+
+	err = StreamToRTMP(ctx, item, rtmpURL)
+	// encoder can't keep up: restart from the same point one preset lower
+	var behind *EncoderBehindError
+	for errors.As(err, &behind) {
+		softer := behind.Element
+		softer.QualityIndex++
+		softer.Offset = behind.Position
+		softer.CuePoints = playlist.CuePoints(element.Sched().Breaks, softer.Offset)
+		s.recordQualityEvent(QualityEvent{
+			Time:          time.Now(),
+			Item:          softer.Path,
+			FromQuality:   behind.Element.QualityIndex,
+			ToQuality:     softer.QualityIndex,
+			OffsetSeconds: softer.Offset.Seconds(),
+		})
+		err = StreamToRTMP(ctx, softer, rtmpURL)
+	}
+
+	// only an episode that aired to the end counts as watched
+	if series, ok := element.(playlist.SeriesElement); ok && err == nil {
+		s.series.Aired(series.Directory, item.(playlist.VideoElement).Path)
+	}
+	return err
+}
+
+// resolveElement turns elements whose actual source is only known at play
+// time into something StreamToRTMP can air.
+func (s *Server) resolveElement(ctx context.Context, item playlist.PlaylistElement) (playlist.PlaylistElement, error) {
+	switch item := item.(type) {
+	case playlist.YouTubeElement:
+		return resolveYouTube(ctx, item)
+	case playlist.IdentElement:
+		return resolveIdent(item)
+	case playlist.SeriesElement:
+		episode, err := s.series.NextEpisode(item.Directory)
+		if err != nil {
+			return nil, err
+		}
+		return playlist.VideoElement{
+			Metadata:      item.Metadata,
+			Path:          episode,
+			QualityIndex:  item.QualityIndex,
+			AspectRatio43: item.AspectRatio43,
+			TextBanner:    item.TextBanner,
+		}, nil
+	case playlist.RandomElement:
+		files, err := listMediaFiles(item.Directory)
+		if err != nil {
+			return nil, fmt.Errorf("random %s: %w", item.Directory, err)
+		}
+		s.mu.RLock()
+		aired := slices.Clone(s.aired)
+		s.mu.RUnlock()
+		path, err := pickRandom(files, aired, item.AvoidLast)
+		if err != nil {
+			return nil, fmt.Errorf("random %s: %w", item.Directory, err)
+		}
+		return playlist.VideoElement{
+			Metadata:      item.Metadata,
+			Path:          path,
+			QualityIndex:  item.QualityIndex,
+			AspectRatio43: item.AspectRatio43,
+			TextBanner:    item.TextBanner,
+		}, nil
+	}
+	return item, nil
+}
+
+func (s *Server) recordAired(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aired = append(s.aired, path)
+	if len(s.aired) > maxAiredHistory {
+		s.aired = s.aired[len(s.aired)-maxAiredHistory:]
+	}
+}
+
+// upNext returns the element the player starts after the current one, as
+// advance picks it: a pending jump, else the following element. It is nil at
+// the end of a non looping playlist, and in shuffle mode, where the pick is
+// only made when the current item ends. Callers hold s.mu.
+func (s *Server) upNext() playlist.PlaylistElement {
+	if s.shufflePending() {
+		return nil
+	}
+	next := s.currentlyPlaying + 1
+	if s.jumpTo >= 0 {
+		next = s.jumpTo
+	}
+	if next < 0 || next >= len(s.playlist) {
+		if !s.loop || s.draining || len(s.playlist) == 0 {
+			return nil
+		}
+		next = 0
+	}
+	return s.playlist[next]
+}
+
+// shufflePending reports whether advance will pick the next element at
+// random. Callers hold s.mu.
+func (s *Server) shufflePending() bool {
+	return s.jumpTo < 0 && s.shuffle && !s.draining && len(s.playlist) > 0
+}
+
+// upNextTitle returns the title of the element up next, empty when unknown.
+// Callers hold s.mu.
+func (s *Server) upNextTitle() string {
+	next := s.upNext()
+	if next == nil {
+		return ""
+	}
+	return playlist.DisplayTitle(next)
+}
+
+// fillIdle completes a break that starts at start with what follows it:
+// the next item starts when the break ends, or at its own start time when
+// it is scheduled later. Callers hold s.mu.
+func (s *Server) fillIdle(idle playlist.IdleElement, start time.Time) playlist.IdleElement {
+	idle.StartsAt = start.Add(time.Duration(idle.IdleSeconds) * time.Second)
+	next := s.upNext()
+	if next == nil {
+		return idle
+	}
+	if startAt := next.Sched().StartAt; startAt != nil && startAt.After(start) {
+		idle.StartsAt = *startAt
+	}
+	idle.NextTitle = playlist.DisplayTitle(next)
+	if idle.AutoNext {
+		idle.Description = next.Meta().Description
+	}
+	return idle
+}
+
+// NowPlaying describes the element on air.
+func (s *Server) NowPlaying() NowPlaying {
+	v := s.loadView()
+	np := NowPlaying{Index: v.current}
+	if !v.onAir || v.current < 0 || v.current >= len(v.playlist) {
+		return np
+	}
+	item := v.playlist[v.current]
+	np.Playing = true
+	np.Type = item.Type()
+	np.Title = playlist.DisplayTitle(item)
+	np.Metadata = item.Meta()
+	np.StartedAt = v.started
+	return np
+}
+
+// EPG lists the programmes from the one on air to the end of the playlist,
+// with start times estimated from probed durations.
+func (s *Server) EPG(ctx context.Context) []EPGEntry {
+	s.mu.RLock()
+	from := s.currentlyPlaying
+	if from < 0 {
+		from = 0
+	}
+	items := slices.Clone(s.playlist[min(from, len(s.playlist)):])
+	start := time.Now()
+	if !s.currentStarted.IsZero() {
+		start = s.currentStarted
+	}
+	s.mu.RUnlock()
+
+	entries := make([]EPGEntry, 0, len(items))
+	for i, item := range items {
+		entry := EPGEntry{
+			Index:    from + i,
+			Type:     item.Type(),
+			Title:    playlist.DisplayTitle(item),
+			Metadata: item.Meta(),
+			Start:    start,
+		}
+		if dur, err := s.elementDuration(ctx, item, 0); err == nil {
+			entry.DurationSeconds = dur.Seconds()
+			entry.DurationKnown = true
+			start = start.Add(dur)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// SeriesPositions returns the last aired episode of every tracked series.
+func (s *Server) SeriesPositions() map[string]string {
+	return s.series.Positions()
+}
+
+func (s *Server) recordQualityEvent(ev QualityEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log.Printf("quality downgrade: %s %d -> %d at %.0fs", ev.Item, ev.FromQuality, ev.ToQuality, ev.OffsetSeconds)
+	s.qualityEvents = append(s.qualityEvents, ev)
+	if len(s.qualityEvents) > maxQualityEvents {
+		s.qualityEvents = s.qualityEvents[len(s.qualityEvents)-maxQualityEvents:]
+	}
+}
+
+// QualityEvents returns the recorded automatic downgrades, oldest first.
+func (s *Server) QualityEvents() []QualityEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]QualityEvent, len(s.qualityEvents))
+	copy(out, s.qualityEvents)
+	return out
+}
+
+func (s *Server) StopPlayer() bool {
+	s.mu.Lock()
+	if s.state == StateStopped || s.playerCancel == nil {
+		s.mu.Unlock()
+		return false
+	}
+	if s.currentCancel != nil {
+		s.currentCancel()
+	}
+	s.playerCancel()
+	s.mu.Unlock()
+	return true
+}
+
+func (s *Server) LoadPlaylist(items []map[string]interface{}) error {
+	// parsed and checked before touching the current playlist
+	playlist, err := parsePlaylist(items)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.playlist = playlist
+	s.changed()
+	return nil
+}
+
+// SavePlaylist stores a named playlist that "playlist" elements can refer to.
+func (s *Server) SavePlaylist(name string, items []map[string]interface{}) (int, error) {
+	elements, err := parsePlaylist(items)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saved == nil {
+		s.saved = make(map[string][]playlist.PlaylistElement)
+	}
+	s.saved[name] = elements
+	s.persist()
+	return len(elements), nil
+}
+
+func (s *Server) SavedPlaylist(name string) ([]playlist.PlaylistElement, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	playlist, ok := s.saved[name]
+	if !ok {
+		return nil, false
+	}
+	return slices.Clone(playlist), true
+}
+
+// SavedPlaylists returns the saved playlist names with their length.
+func (s *Server) SavedPlaylists() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]int, len(s.saved))
+	for name, playlist := range s.saved {
+		out[name] = len(playlist)
+	}
+	return out
+}
+
+func (s *Server) DeletePlaylist(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.saved[name]; !ok {
+		return false
+	}
+	delete(s.saved, name)
+	s.persist()
+	return true
+}
+
+// parsePlaylist parses a /load style JSON list and checks its remote sources.
+func parsePlaylist(items []map[string]interface{}) ([]playlist.PlaylistElement, error) {
+	var elements []playlist.PlaylistElement
+	for _, item := range items {
+		element, ok := playlist.ParseElement(item)
+		if !ok {
+			continue
+		}
+		elements = append(elements, element)
+	}
+
+	if err := checkRemoteElements(context.Background(), elements); err != nil {
+		return nil, err
+	}
+	return elements, nil
+}
@@ -0,0 +1,360 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// newTestServer returns a server keeping its files in a temporary folder,
+// with ffmpeg and ffprobe replaced by fake, and stops its player at the end.
+func newTestServer(t *testing.T, fake *FakeRunner) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	savedQueue, savedSeries, savedAsRun := QueueStateFile, SeriesStateFile, AsRunDir
+	QueueStateFile, SeriesStateFile, AsRunDir = dir+"/queue.json", dir+"/series.json", dir+"/asrun"
+	useFakeRunner(t, fake)
+	log.SetOutput(io.Discard)
+
+	s := NewServer("rtmp://nginx:1935/live/stream")
+	t.Cleanup(func() {
+		s.StopPlayer()
+		waitForState(t, s, StateStopped)
+		QueueStateFile, SeriesStateFile, AsRunDir = savedQueue, savedSeries, savedAsRun
+		log.SetOutput(logOutput)
+	})
+	return s
+}
+
+// logOutput is where the log went before the tests silenced it.
+var logOutput = log.Writer()
+
+// waitForState polls the lock-free view until the player reaches state.
+func waitForState(t *testing.T, s *Server, state PlayerState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.loadView().state != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("player still %s, want %s", s.loadView().state, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// slowFfmpeg makes every ffmpeg run last a moment, like a very short item,
+// and every probe fail, so nothing is cached.
+func slowFfmpeg() *FakeRunner {
+	return &FakeRunner{
+		Respond: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+			if name != "ffmpeg" {
+				return fmt.Errorf("%s: not available in tests", name)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(2 * time.Millisecond):
+				return nil
+			}
+		},
+	}
+}
+
+func TestPlayerAirsThroughTheRunner(t *testing.T) {
+	fake := slowFfmpeg()
+	s := newTestServer(t, fake)
+	s.Append("/media/a.mp4")
+	s.Append("/media/b.mp4")
+	if !s.StartPlayer() {
+		t.Fatal("StartPlayer() = false")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		aired := map[string]bool{}
+		for _, call := range fake.Calls() {
+			if call.Name == "ffmpeg" {
+				for _, arg := range call.Args {
+					aired[arg] = true
+				}
+			}
+		}
+		if aired["/media/a.mp4"] && aired["/media/b.mp4"] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("both items not aired, calls: %v", fake.Calls())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestConcurrentPollsAndEdits polls the read side while the API edits the
+// playlist and the player loop airs it. Run it with -race: it checks the
+// locking of Server and the lock-free statusView.
+func TestConcurrentPollsAndEdits(t *testing.T) {
+	s := newTestServer(t, slowFfmpeg())
+	for i := range 5 {
+		s.Append(fmt.Sprintf("/media/%d.mp4", i))
+	}
+	if !s.StartPlayer() {
+		t.Fatal("StartPlayer() = false")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ctx.Err() == nil; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	// pollers
+	run(func(int) {
+		st := s.Status(ctx)
+		if st.Length < 0 || (st.Playing && !st.Running) {
+			t.Errorf("inconsistent status %+v", st)
+		}
+	})
+	run(func(int) { _ = s.List() })
+	run(func(int) { _ = s.NowPlaying() })
+	run(func(int) { _ = s.EPG(ctx) })
+
+	// editors
+	run(func(i int) {
+		s.Append(fmt.Sprintf("/media/extra-%d.mp4", i))
+		time.Sleep(time.Millisecond)
+	})
+	run(func(int) {
+		if len(s.List()) > 8 {
+			s.Remove(0)
+		}
+		time.Sleep(time.Millisecond)
+	})
+	run(func(int) {
+		s.Next()
+		time.Sleep(3 * time.Millisecond)
+	})
+	run(func(i int) {
+		items := []map[string]interface{}{
+			{"type": "video", "path": fmt.Sprintf("/media/loaded-%d.mp4", i)},
+			{"type": "video", "path": "/media/loaded-b.mp4"},
+		}
+		if err := s.LoadPlaylist(items); err != nil {
+			t.Errorf("LoadPlaylist: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	wg.Wait()
+	if st := s.Status(context.Background()); !st.Running {
+		t.Errorf("player stopped during the test: %+v", st)
+	}
+}
+
+func TestStartStopWhilePolling(t *testing.T) {
+	s := newTestServer(t, slowFfmpeg())
+	s.Append("/media/a.mp4")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			s.Status(ctx)
+			s.NowPlaying()
+		}
+	}()
+	for ctx.Err() == nil {
+		s.StartPlayer()
+		time.Sleep(time.Millisecond)
+		s.StopPlayer()
+		waitForState(t, s, StateStopped)
+	}
+	wg.Wait()
+}
+
+func TestFillIdleCountsDownToTheNextStart(t *testing.T) {
+	s := newTestServer(t, slowFfmpeg())
+	start := time.Date(2026, 10, 15, 20, 0, 0, 0, time.Local)
+	later := start.Add(10 * time.Minute)
+	earlier := start.Add(-time.Minute)
+	tests := []struct {
+		name    string
+		startAt *time.Time
+		want    time.Time
+	}{
+		{"unscheduled", nil, start.Add(30 * time.Second)},
+		{"scheduled later", &later, later},
+		{"scheduled before the break", &earlier, start.Add(30 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			next := playlist.VideoElement{Path: "/media/next.mp4", Scheduling: playlist.Scheduling{StartAt: tt.startAt}}
+			s.playlist = []playlist.PlaylistElement{playlist.IdleElement{IdleSeconds: 30}, next}
+			s.currentlyPlaying = 0
+			if got := s.fillIdle(playlist.IdleElement{IdleSeconds: 30}, start).StartsAt; !got.Equal(tt.want) {
+				t.Errorf("StartsAt = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeekFollowsAdvance(t *testing.T) {
+	s := newTestServer(t, slowFfmpeg())
+	tests := []struct {
+		name    string
+		jumpTo  int
+		shuffle bool
+		loop    bool
+		want    string // id, or the error
+	}{
+		{"following", -1, false, false, "b"},
+		{"pending jump", 0, false, false, "a"},
+		{"jump past the end, looping", 3, false, true, "a"},
+		{"jump past the end", 3, false, false, ErrNothingQueued.Error()},
+		{"shuffle", -1, true, false, ErrNextUnknown.Error()},
+		{"jump in shuffle mode", 2, true, false, "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.mu.Lock()
+			s.playlist = []playlist.PlaylistElement{
+				playlist.VideoElement{Entry: playlist.Entry{ID: "a"}, Path: "/media/a.mp4"},
+				playlist.VideoElement{Entry: playlist.Entry{ID: "b"}, Path: "/media/b.mp4"},
+				playlist.VideoElement{Entry: playlist.Entry{ID: "c"}, Path: "/media/c.mp4"},
+			}
+			s.currentlyPlaying, s.jumpTo = 0, tt.jumpTo
+			s.shuffle, s.loop = tt.shuffle, tt.loop
+			s.state, s.currentCancel = StatePlaying, func() {}
+			s.mu.Unlock()
+			defer func() {
+				s.mu.Lock()
+				s.state, s.currentCancel, s.jumpTo = StateStopped, nil, -1
+				s.mu.Unlock()
+			}()
+
+			st, err := s.Peek()
+			got := st.ID
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("Peek = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusProbesOncePerVersion(t *testing.T) {
+	fake := slowFfmpeg()
+	s := newTestServer(t, fake)
+	probes := func() int {
+		n := 0
+		for _, call := range fake.Calls() {
+			if call.Name == "ffprobe" {
+				n++
+			}
+		}
+		return n
+	}
+	s.Append("/media/a.mp4")
+	s.Append("/media/b.mp4")
+
+	s.Status(context.Background())
+	first := probes()
+	if first == 0 {
+		t.Fatal("Status probed nothing")
+	}
+	for range 3 {
+		s.Status(context.Background())
+	}
+	if got := probes(); got != first {
+		t.Errorf("%d probes after polling the same version again, want %d", got, first)
+	}
+	s.Append("/media/c.mp4")
+	s.Status(context.Background())
+	if got := probes(); got <= first {
+		t.Errorf("new version not probed: %d probes", got)
+	}
+}
+
+// TestPublishFailuresAreNotStrikes airs to a destination that refuses every
+// stream: the items are not to blame, so none is dead-lettered.
+func TestPublishFailuresAreNotStrikes(t *testing.T) {
+	const url = "rtmp://127.0.0.1:1/live/stream"
+	fake := &FakeRunner{
+		Respond: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+			if name != "ffmpeg" {
+				return fmt.Errorf("%s: not available in tests", name)
+			}
+			io.WriteString(stderr, "Error opening output "+url+": Connection refused\n")
+			return errors.New("exit status 1")
+		},
+	}
+	// restored after newTestServer stops the player
+	savedDelay := publishRetryDelay
+	publishRetryDelay = time.Millisecond
+	t.Cleanup(func() { publishRetryDelay = savedDelay })
+	s := newTestServer(t, fake)
+	s.dest = &destinations{urls: []string{url}}
+	s.Append("/media/a.mp4")
+	s.Append("/media/b.mp4")
+	if !s.StartPlayer() {
+		t.Fatal("StartPlayer() = false")
+	}
+
+	// every item fails well past deadLetterAfter times
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.Calls()) < 4*DeadLetterAfter {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d ffmpeg runs", len(fake.Calls()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if dead := s.DeadItems(); len(dead) != 0 {
+		t.Errorf("dead-lettered %d items on publish failures: %+v", len(dead), dead)
+	}
+	if n := len(s.List()); n != 2 {
+		t.Errorf("playlist has %d items, want 2", n)
+	}
+}
+
+func TestItemFailuresAreStrikes(t *testing.T) {
+	fake := &FakeRunner{
+		Respond: func(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+			if name != "ffmpeg" {
+				return fmt.Errorf("%s: not available in tests", name)
+			}
+			io.WriteString(stderr, "/media/a.mp4: Invalid data found when processing input\n")
+			return errors.New("exit status 1")
+		},
+	}
+	s := newTestServer(t, fake)
+	s.Append("/media/a.mp4")
+	if !s.StartPlayer() {
+		t.Fatal("StartPlayer() = false")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.DeadItems()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("broken item never dead-lettered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,125 @@
+package player
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// statusView is an immutable copy of the playlist and player state, swapped
+// in atomically after every change. Pollers (/list, /nowplaying, /status
+// style calls) read it without touching s.mu, so they never wait on the
+// player loop or on writers.
+type statusView struct {
+	playlist []playlist.PlaylistElement
+	version  uint64
+	// seq counts publishes: any change of what the view shows
+	seq uint64
+	// changed is closed when a newer view replaces this one
+	changed chan struct{}
+	current int
+	state   PlayerState
+	onAir   bool
+	paused  bool
+	loop    bool
+	shuffle bool
+	started time.Time
+	// programmed is shared by the views of the same version
+	programmed *programmedTime
+}
+
+// programmedTime is the total airtime of one playlist version, probed once
+// by the first /status poll that needs it.
+type programmedTime struct {
+	once    sync.Once
+	seconds int
+}
+
+// publish swaps in a fresh statusView. Callers hold s.mu for writing.
+func (s *Server) publish() {
+	s.viewSeq++
+	old := s.view.Load()
+	// a new version probes its durations again
+	programmed := &programmedTime{}
+	if old != nil && old.version == s.version {
+		programmed = old.programmed
+	}
+	s.view.Store(&statusView{
+		changed:    make(chan struct{}),
+		playlist:   slices.Clone(s.playlist),
+		version:    s.version,
+		seq:        s.viewSeq,
+		current:    s.currentlyPlaying,
+		state:      s.state,
+		onAir:      s.onAir(),
+		paused:     s.paused,
+		loop:       s.loop,
+		shuffle:    s.shuffle,
+		started:    s.currentStarted,
+		programmed: programmed,
+	})
+	if old != nil {
+		close(old.changed)
+	}
+}
+
+// Change summarizes a statusView for long polling clients.
+type Change struct {
+	// Seq counts the changes, to pass back as since
+	Seq     uint64
+	Version uint64
+	State   PlayerState
+	Index   int
+	Playing bool
+}
+
+// WaitChange blocks until the view is newer than seq, ctx is done or
+// timeout passes, and returns the latest view.
+func (s *Server) WaitChange(ctx context.Context, seq uint64, timeout time.Duration) Change {
+	v := s.waitView(ctx, seq, timeout)
+	return Change{Seq: v.seq, Version: v.version, State: v.state, Index: v.current, Playing: v.onAir}
+}
+
+func (s *Server) waitView(ctx context.Context, seq uint64, timeout time.Duration) *statusView {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		v := s.loadView()
+		if v.seq > seq || v.changed == nil {
+			return v
+		}
+		select {
+		case <-v.changed:
+		case <-timer.C:
+			return s.loadView()
+		case <-ctx.Done():
+			return s.loadView()
+		}
+	}
+}
+
+// loadView returns the latest statusView.
+func (s *Server) loadView() *statusView {
+	if v := s.view.Load(); v != nil {
+		return v
+	}
+	return &statusView{state: StateStopped, programmed: &programmedTime{}}
+}
+
+// programmedSeconds is the total airtime of the view's playlist. Durations
+// are probed once per version, without holding s.mu, and not cut short
+// when the poll that probes them goes away.
+func (s *Server) programmedSeconds(ctx context.Context, v *statusView) int {
+	v.programmed.once.Do(func() {
+		ctx := context.WithoutCancel(ctx)
+		for _, item := range v.playlist {
+			if dur, err := s.elementDuration(ctx, item, 0); err == nil {
+				v.programmed.seconds += int(dur.Seconds())
+			}
+		}
+	})
+	return v.programmed.seconds
+}
@@ -0,0 +1,130 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// PlayerState is what the player loop is doing.
+type PlayerState string
+
+const (
+	// StateStopped: no player loop, /start starts one.
+	StateStopped PlayerState = "stopped"
+	// StateIdle: running, waiting for something to air.
+	StateIdle PlayerState = "idle"
+	// StatePlaying: an item is on air, or waiting for its start_at.
+	StatePlaying PlayerState = "playing"
+	// StatePaused: running, holding between items until /resume.
+	StatePaused PlayerState = "paused"
+	// StateDraining: airing the rest of the playlist before stopping.
+	StateDraining PlayerState = "draining"
+	// StateError: the last item failed, until the next one starts.
+	StateError PlayerState = "error"
+)
+
+// stateTransitions are the valid moves between player states.
+var stateTransitions = map[PlayerState][]PlayerState{
+	StateStopped:  {StateIdle},
+	StateIdle:     {StatePlaying, StateDraining, StatePaused, StateStopped},
+	StatePlaying:  {StateIdle, StatePaused, StateDraining, StateError, StateStopped},
+	StatePaused:   {StatePlaying, StateDraining, StateIdle, StateStopped},
+	StateDraining: {StatePlaying, StatePaused, StateError, StateStopped},
+	StateError:    {StatePlaying, StateDraining, StateIdle, StatePaused, StateStopped},
+}
+
+// maxStateEvents bounds the state change history, oldest dropped first.
+const maxStateEvents = 100
+
+// StateChange is one player state transition.
+type StateChange struct {
+	From   PlayerState `json:"from"`
+	To     PlayerState `json:"to"`
+	Reason string      `json:"reason,omitempty"`
+	Time   time.Time   `json:"time"`
+}
+
+// setState moves the player to state, refusing transitions missing from
+// stateTransitions. Staying in the same state is a no-op. Callers hold s.mu.
+func (s *Server) setState(to PlayerState, reason string) error {
+	from := s.state
+	if from == to {
+		return nil
+	}
+	if !slices.Contains(stateTransitions[from], to) {
+		log.Printf("player: refusing %s -> %s (%s)", from, to, reason)
+		return fmt.Errorf("invalid player transition %s -> %s", from, to)
+	}
+	s.state = to
+	s.stateEvents = append(s.stateEvents, StateChange{From: from, To: to, Reason: reason, Time: time.Now()})
+	if len(s.stateEvents) > maxStateEvents {
+		s.stateEvents = s.stateEvents[len(s.stateEvents)-maxStateEvents:]
+	}
+	log.Printf("player: %s -> %s (%s)", from, to, reason)
+	s.publish()
+	return nil
+}
+
+// onAir reports whether an item is on air. Callers hold s.mu.
+func (s *Server) onAir() bool {
+	return (s.state == StatePlaying || s.state == StateDraining) && s.currentCancel != nil
+}
+
+// airingState is Playing, or Draining during a drain. Callers hold s.mu.
+func (s *Server) airingState() PlayerState {
+	if s.draining {
+		return StateDraining
+	}
+	return StatePlaying
+}
+
+// State returns the player state and its recent changes, oldest first.
+func (s *Server) State() (PlayerState, []StateChange) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state, slices.Clone(s.stateEvents)
+}
+
+// advance moves currentlyPlaying to the element the player airs next: a
+// jump requested by /next, /previous or a removal, a weighted pick in
+// shuffle mode, or the following element, wrapping around when looping.
+// At the end it leaves currentlyPlaying past the last element, so anything
+// appended airs next, and returns false. Callers hold s.mu.
+func (s *Server) advance() bool {
+	switch {
+	case s.jumpTo >= 0:
+		s.currentlyPlaying = s.jumpTo
+		s.jumpTo = -1
+	case s.shuffle && !s.draining && len(s.playlist) > 0:
+		s.currentlyPlaying = playlist.PickWeighted(s.playlist, s.currentlyPlaying)
+		return true
+	default:
+		s.currentlyPlaying++
+	}
+	if s.currentlyPlaying >= 0 && s.currentlyPlaying < len(s.playlist) {
+		return true
+	}
+	if s.loop && !s.draining && len(s.playlist) > 0 {
+		s.currentlyPlaying = 0
+		return true
+	}
+	s.currentlyPlaying = len(s.playlist)
+	return false
+}
+
+// sleepCtx waits d, returning false early if ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
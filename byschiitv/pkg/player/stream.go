@@ -0,0 +1,172 @@
+package player
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"byschiitv/pkg/ffmpegopts"
+	"byschiitv/pkg/playlist"
+)
+
+// StreamToRTMP starts an FFmpeg command to stream a video file to nginx-rtmp.
+// It listens on ctx and stops the stream when cancelled.
+func StreamToRTMP(ctx context.Context, video playlist.PlaylistElement, rtmpURL string) error {
+	log.Print("streaming: ", video.Desc())
+	// the package active when the item starts, for its whole run
+	brand := activeBranding()
+
+	var args []string
+	var err error
+	runCtx := ctx
+	var monitor *speedMonitor
+	switch video := video.(type) {
+	case playlist.IdleElement:
+		args, err = ffmpegopts.FfmpegIdleStreamCommand(
+			brand,
+			ScreenText,
+			rtmpURL,
+			video.IdleSeconds,
+			video.NextTitle,
+			video.Description,
+			video.StartsAt.Unix(),
+		)
+		if err != nil {
+			return err
+		}
+	case playlist.VideoElement:
+		banner := ""
+		if video.TextBanner {
+			banner = video.BannerText()
+		}
+		start, end := playlist.Seconds(video.TrimStart)+video.Offset, playlist.Seconds(video.TrimEnd)
+		if end > 0 && start >= end {
+			// joined or restarted past the trimmed end: nothing left to air
+			log.Printf("streaming skipped: %s has nothing left after %s", video.Desc(), start)
+			return nil
+		}
+		args, err = ffmpegopts.FfmpegCommand(brand, video.Path, rtmpURL, video.AspectRatio43, video.QualityIndex, banner, start, end)
+		if err != nil {
+			return err
+		}
+		if len(video.CuePoints) > 0 {
+			// output options go anywhere before the destination
+			args = slices.Insert(args, len(args)-1, "-force_key_frames", ffmpegopts.ForceKeyframes(video.CuePoints))
+		}
+		var cancelRun context.CancelFunc
+		runCtx, cancelRun = context.WithCancel(ctx)
+		defer cancelRun()
+		// only worth acting on the speed if there is a cheaper preset to fall back to
+		var onSlow func()
+		if video.QualityIndex < ffmpegopts.LowestQuality(video.AspectRatio43) {
+			onSlow = cancelRun
+		}
+		monitor = newSpeedMonitor(SlowEncodeWindow, onSlow)
+	case playlist.TestPatternElement:
+		args, err = ffmpegopts.FfmpegTestPatternCommand(rtmpURL, video)
+		if err != nil {
+			return err
+		}
+	case playlist.AudioElement:
+		args, err = ffmpegopts.FfmpegAudioCommand(brand, rtmpURL, video)
+		if err != nil {
+			return err
+		}
+	case playlist.TextCardElement:
+		args, err = ffmpegopts.FfmpegTextCardCommand(brand, rtmpURL, video)
+		if err != nil {
+			return err
+		}
+	case playlist.LiveElement:
+		args, err = ffmpegopts.FfmpegLiveCommand(brand, video.URL, rtmpURL, video.AspectRatio43, video.QualityIndex, video.DurationSeconds)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown video element type")
+	}
+
+	// Optional: capture output for logging
+	var stdout io.Writer = os.Stdout
+	if monitor != nil {
+		stdout = monitor
+	}
+
+	tail := &stderrTail{}
+	err = commandRunner.Run(runCtx, "ffmpeg", args, stdout, io.MultiWriter(os.Stderr, tail))
+	if monitor != nil {
+		if slow, pos := monitor.tripped(); slow && ctx.Err() == nil {
+			v := video.(playlist.VideoElement)
+			return &EncoderBehindError{Element: v, Position: v.Offset + pos}
+		}
+	}
+	if err != nil {
+		// Check if it was cancelled vs actual error
+		if ctx.Err() == context.Canceled {
+			log.Printf("streaming interrupted: %s", video.Desc())
+			return ctx.Err()
+		}
+		if detail, ok := publishFailure(tail.String(), rtmpURL); ok {
+			return &PublishError{URL: rtmpURL, Detail: detail, Err: err}
+		}
+		return fmt.Errorf("ffmpeg error: %w", err)
+	}
+
+	log.Printf("streaming completed: %s", video.Desc())
+	return nil
+}
+
+// ffprobe output structure
+type FFProbeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// ProbeTimeout bounds each ffprobe or yt-dlp duration probe, so a hung
+// mount or server can't block a caller forever.
+// Overridden from PROBE_TIMEOUT_SECONDS in main.
+var ProbeTimeout = 30 * time.Second
+
+// GetVideoDuration uses ffprobe to get the duration of a video file.
+// Local files are probed once, see probeCache.
+func GetVideoDuration(ctx context.Context, videoPath string) (time.Duration, error) {
+	cached, info, ok := probeCache.lookup(videoPath)
+	if ok {
+		return cached, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+	// ffprobe -v error -show_format -of json input.mp4
+	output, err := runOutput(ctx, nil, "ffprobe",
+		"-v", "error",
+		"-show_format",
+		"-of", "json",
+		videoPath,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed for %s: %w", videoPath, err)
+	}
+
+	var probe FFProbeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	durationSeconds, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration format: %w", err)
+	}
+
+	d := time.Duration(durationSeconds * float64(time.Second))
+	if info != nil {
+		probeCache.store(videoPath, info, d)
+	}
+	return d, nil
+}
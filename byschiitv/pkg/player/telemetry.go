@@ -0,0 +1,243 @@
+package player
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where the hardware readings come from. Outside of a Pi the thermal zone
+// may still exist; vcgencmd does not, and throttling is then left out.
+var (
+	thermalZoneFile = "/sys/class/thermal/thermal_zone0/temp"
+	loadAvgFile     = "/proc/loadavg"
+	memInfoFile     = "/proc/meminfo"
+)
+
+const (
+	// telemetryMaxAge is how long a reading is reused, so polling /status
+	// or /metrics does not run vcgencmd every time
+	telemetryMaxAge = 5 * time.Second
+	// vcgencmdTimeout bounds one vcgencmd call
+	vcgencmdTimeout = 2 * time.Second
+)
+
+// throttleFlags are the bits of vcgencmd get_throttled, in order. Bit n is
+// the condition now, bit n+16 whether it happened since boot.
+var throttleFlags = []string{"under_voltage", "frequency_capped", "throttled", "soft_temp_limit"}
+
+// Throttling is the decoded vcgencmd get_throttled.
+type Throttling struct {
+	Raw string `json:"raw"`
+	// Now and SinceBoot list the flags of throttleFlags that are set
+	Now       []string `json:"now,omitempty"`
+	SinceBoot []string `json:"since_boot,omitempty"`
+}
+
+// Telemetry is the state of the hardware the encoder runs on, to correlate
+// encode problems with heat and throttling.
+type Telemetry struct {
+	Read               time.Time   `json:"read"`
+	TemperatureCelsius *float64    `json:"temperature_celsius,omitempty"`
+	Throttling         *Throttling `json:"throttling,omitempty"`
+	Load               []float64   `json:"load,omitempty"`
+	MemTotalBytes      int64       `json:"mem_total_bytes,omitempty"`
+	MemAvailableBytes  int64       `json:"mem_available_bytes,omitempty"`
+	Errors             []string    `json:"errors,omitempty"`
+}
+
+// telemetrySampler caches the last reading.
+type telemetrySampler struct {
+	mu   sync.Mutex
+	last Telemetry
+}
+
+var hardware = &telemetrySampler{}
+
+// read returns a reading at most telemetryMaxAge old.
+func (t *telemetrySampler) read(ctx context.Context) Telemetry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.last.Read) < telemetryMaxAge {
+		return t.last
+	}
+	t.last = readTelemetry(ctx)
+	return t.last
+}
+
+// readTelemetry reads every source, recording the ones that failed.
+func readTelemetry(ctx context.Context) Telemetry {
+	tel := Telemetry{Read: time.Now()}
+	fail := func(what string, err error) {
+		tel.Errors = append(tel.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+	if temp, err := readTemperature(thermalZoneFile); err != nil {
+		fail("temperature", err)
+	} else {
+		tel.TemperatureCelsius = &temp
+	}
+	if th, err := readThrottling(ctx); err != nil {
+		fail("throttling", err)
+	} else {
+		tel.Throttling = &th
+	}
+	if load, err := readLoadAvg(loadAvgFile); err != nil {
+		fail("load", err)
+	} else {
+		tel.Load = load
+	}
+	if total, available, err := readMemInfo(memInfoFile); err != nil {
+		fail("memory", err)
+	} else {
+		tel.MemTotalBytes, tel.MemAvailableBytes = total, available
+	}
+	return tel
+}
+
+// readTemperature reads a thermal zone, in millidegrees.
+func readTemperature(file string) (float64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	milli, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0, err
+	}
+	return milli / 1000, nil
+}
+
+// readThrottling runs vcgencmd get_throttled, which prints "throttled=0x50000".
+func readThrottling(ctx context.Context) (Throttling, error) {
+	ctx, cancel := context.WithTimeout(ctx, vcgencmdTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	out, err := runOutput(ctx, &stderr, "vcgencmd", "get_throttled")
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return Throttling{}, fmt.Errorf("%w: %s", err, msg)
+	}
+	if err != nil {
+		return Throttling{}, err
+	}
+	return parseThrottled(string(out))
+}
+
+func parseThrottled(out string) (Throttling, error) {
+	raw, ok := strings.CutPrefix(strings.TrimSpace(out), "throttled=")
+	if !ok {
+		return Throttling{}, fmt.Errorf("unexpected output %q", out)
+	}
+	bits, err := strconv.ParseUint(raw, 0, 32)
+	if err != nil {
+		return Throttling{}, fmt.Errorf("unexpected output %q", out)
+	}
+	th := Throttling{Raw: raw}
+	for i, flag := range throttleFlags {
+		if bits&(1<<i) != 0 {
+			th.Now = append(th.Now, flag)
+		}
+		if bits&(1<<(i+16)) != 0 {
+			th.SinceBoot = append(th.SinceBoot, flag)
+		}
+	}
+	return th, nil
+}
+
+// readLoadAvg reads the 1, 5 and 15 minute load averages.
+func readLoadAvg(file string) ([]float64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("unexpected %s: %q", file, data)
+	}
+	load := make([]float64, 3)
+	for i := range load {
+		if load[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return nil, err
+		}
+	}
+	return load, nil
+}
+
+// readMemInfo reads MemTotal and MemAvailable, given in kB.
+func readMemInfo(file string) (total, available int64, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			available = kb * 1024
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if total == 0 {
+		return 0, 0, fmt.Errorf("no MemTotal in %s", file)
+	}
+	return total, available, nil
+}
+
+// writeTelemetryMetrics writes the readings that are available in the
+// Prometheus text format.
+func writeTelemetryMetrics(w io.Writer, tel Telemetry) {
+	gauge := func(name, help string, v float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, v)
+	}
+	if tel.TemperatureCelsius != nil {
+		gauge("byschiitv_soc_temperature_celsius", "SoC temperature.", *tel.TemperatureCelsius)
+	}
+	if th := tel.Throttling; th != nil {
+		for _, metric := range []struct {
+			name, help string
+			set        []string
+		}{
+			{"byschiitv_throttled", "Throttling conditions active now, from vcgencmd get_throttled.", th.Now},
+			{"byschiitv_throttled_since_boot", "Throttling conditions seen since boot.", th.SinceBoot},
+		} {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", metric.name, metric.help, metric.name)
+			for _, flag := range throttleFlags {
+				v := 0
+				if slices.Contains(metric.set, flag) {
+					v = 1
+				}
+				fmt.Fprintf(w, "%s{flag=%q} %d\n", metric.name, flag, v)
+			}
+		}
+	}
+	if len(tel.Load) == 3 {
+		fmt.Fprintf(w, "# HELP byschiitv_load_average System load average.\n# TYPE byschiitv_load_average gauge\n")
+		for i, period := range []string{"1m", "5m", "15m"} {
+			fmt.Fprintf(w, "byschiitv_load_average{period=%q} %g\n", period, tel.Load[i])
+		}
+	}
+	if tel.MemTotalBytes > 0 {
+		gauge("byschiitv_memory_total_bytes", "Total memory.", float64(tel.MemTotalBytes))
+		gauge("byschiitv_memory_available_bytes", "Memory available to new processes.", float64(tel.MemAvailableBytes))
+	}
+}
@@ -0,0 +1,157 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"byschiitv/pkg/ffmpegopts"
+)
+
+// Thermal thresholds, overridden from THERMAL_HOT_CELSIUS and
+// THERMAL_COOL_CELSIUS in main. The gap between them keeps the quality
+// from flapping around a single threshold.
+var (
+	ThermalHot  = 80.0
+	ThermalCool = 70.0
+)
+
+const (
+	// thermalCheckInterval is how often the temperature is looked at
+	thermalCheckInterval = 30 * time.Second
+	// thermalHotFor is how long it must stay hot for each step down
+	thermalHotFor = 2 * time.Minute
+	// thermalCoolFor is how long it must stay cool for each step up, longer
+	// so a cooler stretch of the evening is not mistaken for the end of it
+	thermalCoolFor = 10 * time.Minute
+	// maxThermalSteps bounds how many presets the heat can take off
+	maxThermalSteps = 3
+	// maxThermalEvents bounds the step history, oldest dropped first
+	maxThermalEvents = 50
+)
+
+// thermalFlags are the vcgencmd throttling conditions that count as hot:
+// the SoC already slows down, and the encoder with it.
+var thermalFlags = []string{"throttled", "soft_temp_limit", "frequency_capped"}
+
+// ThermalEvent is one quality step taken because of the heat.
+type ThermalEvent struct {
+	Time      time.Time `json:"time"`
+	FromSteps int       `json:"from_steps"`
+	ToSteps   int       `json:"to_steps"`
+	Reason    string    `json:"reason"`
+}
+
+// ThermalStatus is how many presets below the requested one items air at.
+type ThermalStatus struct {
+	Steps  int            `json:"steps"`
+	Hot    bool           `json:"hot"`
+	Events []ThermalEvent `json:"events,omitempty"`
+}
+
+// thermalGovernor steps the quality down while the Pi stays hot and back up
+// once it stays cool. Steps apply from the next item, as ffmpeg gets its
+// preset when it starts; within an item the encoder-behind downgrade still
+// catches an encoder that can no longer keep up.
+type thermalGovernor struct {
+	mu        sync.Mutex
+	status    ThermalStatus
+	hotSince  time.Time
+	coolSince time.Time
+}
+
+var thermal = &thermalGovernor{}
+
+func (g *thermalGovernor) Status() ThermalStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := g.status
+	st.Events = slices.Clone(st.Events)
+	return st
+}
+
+// apply lowers quality by the current steps, down to the cheapest preset.
+func (g *thermalGovernor) apply(quality int, ciccione bool) int {
+	g.mu.Lock()
+	steps := g.status.Steps
+	g.mu.Unlock()
+	return min(quality+steps, ffmpegopts.LowestQuality(ciccione))
+}
+
+// run watches the hardware telemetry until ctx is done.
+func (g *thermalGovernor) run(ctx context.Context) {
+	for sleepCtx(ctx, thermalCheckInterval) {
+		g.observe(hardware.read(ctx), time.Now())
+	}
+}
+
+// observe takes one reading into account. Readings without a temperature
+// nor throttling, off a Pi, leave the quality alone.
+func (g *thermalGovernor) observe(tel Telemetry, now time.Time) {
+	if tel.TemperatureCelsius == nil && tel.Throttling == nil {
+		return
+	}
+	var throttled []string
+	if tel.Throttling != nil {
+		for _, flag := range tel.Throttling.Now {
+			if slices.Contains(thermalFlags, flag) {
+				throttled = append(throttled, flag)
+			}
+		}
+	}
+	temp := tel.TemperatureCelsius
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	hot := len(throttled) > 0 || (temp != nil && *temp >= ThermalHot)
+	cool := len(throttled) == 0 && (temp == nil || *temp <= ThermalCool)
+	g.status.Hot = hot
+	switch {
+	case hot:
+		g.coolSince = time.Time{}
+		if g.hotSince.IsZero() {
+			g.hotSince = now
+		}
+		if now.Sub(g.hotSince) >= thermalHotFor && g.status.Steps < maxThermalSteps {
+			g.step(+1, now, thermalReason("hot", temp, throttled))
+			g.hotSince = now
+		}
+	case cool:
+		g.hotSince = time.Time{}
+		if g.coolSince.IsZero() {
+			g.coolSince = now
+		}
+		if now.Sub(g.coolSince) >= thermalCoolFor && g.status.Steps > 0 {
+			g.step(-1, now, thermalReason("cool", temp, nil))
+			g.coolSince = now
+		}
+	default:
+		// between the thresholds: hold, and start counting again
+		g.hotSince, g.coolSince = time.Time{}, time.Time{}
+	}
+}
+
+// step moves the quality by delta presets. Callers hold g.mu.
+func (g *thermalGovernor) step(delta int, now time.Time, reason string) {
+	ev := ThermalEvent{Time: now, FromSteps: g.status.Steps, ToSteps: g.status.Steps + delta, Reason: reason}
+	log.Printf("thermal: %s, quality steps %d -> %d", reason, ev.FromSteps, ev.ToSteps)
+	g.status.Steps = ev.ToSteps
+	g.status.Events = append(g.status.Events, ev)
+	if len(g.status.Events) > maxThermalEvents {
+		g.status.Events = g.status.Events[len(g.status.Events)-maxThermalEvents:]
+	}
+}
+
+func thermalReason(what string, temp *float64, throttled []string) string {
+	reason := what
+	if temp != nil {
+		reason += fmt.Sprintf(" at %.1f°C", *temp)
+	}
+	if len(throttled) > 0 {
+		reason += fmt.Sprintf(", %v", throttled)
+	}
+	return reason
+}
@@ -0,0 +1,33 @@
+package player
+
+import "fmt"
+
+// changed bumps the playlist version and persists. Every change to the
+// playlist itself goes through it. Callers hold s.mu.
+func (s *Server) changed() {
+	s.version++
+	s.persist()
+}
+
+// Version is the playlist version, increasing with every change.
+func (s *Server) Version() uint64 {
+	return s.loadView().version
+}
+
+// LockEdits serializes versioned edits from the API, so checking the
+// version and making the edit can't interleave with another client's.
+// UnlockEdits ends the edit.
+func (s *Server) LockEdits() {
+	s.editMu.Lock()
+}
+
+func (s *Server) UnlockEdits() {
+	s.editMu.Unlock()
+}
+
+// ETag identifies the playlist and player state, changing whenever any read
+// endpoint could answer differently.
+func (s *Server) ETag() string {
+	v := s.loadView()
+	return fmt.Sprintf(`"v%d-%d"`, v.version, v.seq)
+}
@@ -0,0 +1,119 @@
+package player
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"byschiitv/pkg/playlist"
+)
+
+// YtdlpCacheDir holds downloaded YouTube items. Overridden from YTDLP_CACHE_DIR.
+var YtdlpCacheDir = filepath.Join(os.TempDir(), "byschiitv-ytdlp")
+
+// ytdlpStreamFormat picks a single progressive stream, since ffmpeg gets one
+// URL. Downloads can afford separate video+audio merged by yt-dlp.
+const (
+	ytdlpStreamFormat   = "best[height<=1080]/best"
+	ytdlpDownloadFormat = "bv*[height<=1080]+ba/b[height<=1080]/b"
+)
+
+// resolveYouTube asks yt-dlp for something ffmpeg can read and returns it as
+// a plain video element.
+func resolveYouTube(ctx context.Context, y playlist.YouTubeElement) (playlist.VideoElement, error) {
+	var path string
+	var err error
+	if y.Download {
+		path, err = downloadYouTube(ctx, y.URL)
+	} else {
+		path, err = resolveYouTubeURL(ctx, y.URL)
+	}
+	if err != nil {
+		return playlist.VideoElement{}, fmt.Errorf("yt-dlp could not resolve %s: %w", y.URL, err)
+	}
+	return playlist.VideoElement{
+		Metadata:      y.Metadata,
+		Path:          path,
+		QualityIndex:  y.QualityIndex,
+		AspectRatio43: y.AspectRatio43,
+	}, nil
+}
+
+// resolveYouTubeURL returns the direct media URL of a page.
+func resolveYouTubeURL(ctx context.Context, url string) (string, error) {
+	out, err := runYtdlp(ctx, "-f", ytdlpStreamFormat, "--get-url", url)
+	if err != nil {
+		return "", err
+	}
+	return firstLine(out)
+}
+
+// downloadYouTube downloads a page's media into ytdlpCacheDir, reusing a
+// previous download of the same video, and returns the local path.
+func downloadYouTube(ctx context.Context, url string) (string, error) {
+	if err := os.MkdirAll(YtdlpCacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("cache dir: %w", err)
+	}
+	if err := checkSpace(YtdlpCacheDir, 0); err != nil {
+		return "", err
+	}
+	log.Printf("yt-dlp: downloading %s", url)
+	out, err := runYtdlp(ctx,
+		"-f", ytdlpDownloadFormat,
+		"--merge-output-format", "mkv",
+		"--no-overwrites",
+		"-o", filepath.Join(YtdlpCacheDir, "%(extractor)s-%(id)s.%(ext)s"),
+		"--print", "after_move:filepath",
+		url,
+	)
+	if err != nil {
+		return "", err
+	}
+	return firstLine(out)
+}
+
+// GetYouTubeDuration asks yt-dlp for the duration without downloading.
+func GetYouTubeDuration(ctx context.Context, url string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+	out, err := runYtdlp(ctx, "--print", "duration", url)
+	if err != nil {
+		return 0, err
+	}
+	line, err := firstLine(out)
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(line, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", line, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func runYtdlp(ctx context.Context, args ...string) ([]byte, error) {
+	args = append([]string{"--no-playlist", "--no-warnings"}, args...)
+	var stderr bytes.Buffer
+	out, err := runOutput(ctx, &stderr, "yt-dlp", args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func firstLine(out []byte) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("no output from yt-dlp")
+}
@@ -0,0 +1,48 @@
+package playlist
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"time"
+)
+
+// CueBreak is an ad break in an element: the programme can be cut at At
+// seconds into what airs (after TrimStart) for Duration seconds.
+type CueBreak struct {
+	At       float64 `json:"at"`
+	Duration float64 `json:"duration,omitempty"`
+}
+
+func parseBreaks(item map[string]interface{}) []CueBreak {
+	raw, _ := item["breaks"].([]interface{})
+	var breaks []CueBreak
+	for _, r := range raw {
+		b, _ := r.(map[string]interface{})
+		at, ok := b["at"].(float64)
+		if !ok || at < 0 {
+			log.Printf("ignoring break %v: needs a non-negative at", r)
+			continue
+		}
+		duration, _ := b["duration"].(float64)
+		breaks = append(breaks, CueBreak{At: at, Duration: max(duration, 0)})
+	}
+	slices.SortFunc(breaks, func(a, b CueBreak) int {
+		return cmp.Compare(a.At, b.At)
+	})
+	return breaks
+}
+
+// CuePoints are the break boundaries still ahead when airing starts at
+// offset, relative to it.
+func CuePoints(breaks []CueBreak, offset time.Duration) []time.Duration {
+	var points []time.Duration
+	for _, b := range breaks {
+		for _, at := range []float64{b.At, b.At + b.Duration} {
+			if p := Seconds(at) - offset; p > 0 && !slices.Contains(points, p) {
+				points = append(points, p)
+			}
+		}
+	}
+	return points
+}
@@ -0,0 +1,80 @@
+package playlist
+
+// IdentElement airs the ident clip of the branding package active when it
+// starts, so idents follow a package switch.
+type IdentElement struct {
+	Metadata
+	Scheduling
+	Entry
+	QualityIndex int `json:"quality_index,omitempty"`
+}
+
+func (i IdentElement) Type() string {
+	return "ident"
+}
+
+func (i IdentElement) Desc() string {
+	return "Ident"
+}
+
+// RandomElement airs a random file from Directory, skipping files aired in
+// the last AvoidLast plays. Meant for filler blocks and "random cartoon" slots.
+type RandomElement struct {
+	Metadata
+	Scheduling
+	Entry
+	Directory     string `json:"directory"`
+	AvoidLast     int    `json:"avoid_last,omitempty"`
+	QualityIndex  int    `json:"quality_index,omitempty"`
+	AspectRatio43 bool   `json:"aspect_ratio_4_3,omitempty"`
+	TextBanner    bool   `json:"text_banner,omitempty"`
+}
+
+func (r RandomElement) Type() string {
+	return "random"
+}
+
+func (r RandomElement) Desc() string {
+	return "Random from: " + r.Directory
+}
+
+// SeriesElement airs the next episode of the series in Directory each time
+// it comes up, instead of a fixed file.
+type SeriesElement struct {
+	Metadata
+	Scheduling
+	Entry
+	Directory     string `json:"directory"`
+	QualityIndex  int    `json:"quality_index,omitempty"`
+	AspectRatio43 bool   `json:"aspect_ratio_4_3,omitempty"`
+	TextBanner    bool   `json:"text_banner,omitempty"`
+}
+
+func (se SeriesElement) Type() string {
+	return "series"
+}
+
+func (se SeriesElement) Desc() string {
+	return "Series: " + se.Directory
+}
+
+// YouTubeElement airs anything yt-dlp can resolve (YouTube, Vimeo, ...).
+type YouTubeElement struct {
+	Metadata
+	Scheduling
+	Entry
+	URL           string `json:"url"`
+	QualityIndex  int    `json:"quality_index,omitempty"`
+	AspectRatio43 bool   `json:"aspect_ratio_4_3,omitempty"`
+	// Download fetches the whole file into the cache before airing instead
+	// of streaming from the resolved media URL, which can expire or throttle.
+	Download bool `json:"download,omitempty"`
+}
+
+func (y YouTubeElement) Type() string {
+	return "youtube"
+}
+
+func (y YouTubeElement) Desc() string {
+	return y.URL
+}
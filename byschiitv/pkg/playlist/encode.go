@@ -0,0 +1,34 @@
+package playlist
+
+import "encoding/json"
+
+// EncodeElements turns elements back into /load style objects, type
+// included, so ParseElement can read them again.
+func EncodeElements(elements []PlaylistElement) ([]map[string]interface{}, error) {
+	out := make([]map[string]interface{}, 0, len(elements))
+	for _, element := range elements {
+		data, err := json.Marshal(element)
+		if err != nil {
+			return nil, err
+		}
+		var item map[string]interface{}
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		item["type"] = element.Type()
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// DecodeElements parses persisted objects. Remote sources are not checked:
+// a restart while the network is down must not lose the queue.
+func DecodeElements(items []map[string]interface{}) []PlaylistElement {
+	var out []PlaylistElement
+	for _, item := range items {
+		if element, ok := ParseElement(item); ok {
+			out = append(out, element)
+		}
+	}
+	return out
+}
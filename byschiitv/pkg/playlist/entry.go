@@ -0,0 +1,35 @@
+package playlist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// Entry identifies an element in the queue, so a client can follow what it
+// submitted. Every element gets one when parsed.
+type Entry struct {
+	ID string `json:"id,omitempty"`
+}
+
+// EntryID makes every element embedding Entry satisfy PlaylistElement.
+func (e Entry) EntryID() string {
+	return e.ID
+}
+
+func parseEntry(item map[string]interface{}) Entry {
+	id, _ := item["id"].(string)
+	if id == "" {
+		id = NewEntryID()
+	}
+	return Entry{ID: id}
+}
+
+// NewEntryID returns a random queue ID.
+func NewEntryID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,52 @@
+package playlist
+
+import "fmt"
+
+// Metadata describes what an element is, for viewers: the banner, the
+// intermission screen, /nowplaying and /epg all read from it.
+type Metadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Artwork     string `json:"artwork,omitempty"`
+	Category    string `json:"category,omitempty"`
+	// Extra holds client defined fields, passed through untouched
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Meta makes every element embedding Metadata satisfy PlaylistElement.
+func (m Metadata) Meta() Metadata {
+	return m
+}
+
+func parseMetadata(item map[string]interface{}) Metadata {
+	title, _ := item["title"].(string)
+	description, _ := item["description"].(string)
+	artwork, _ := item["artwork"].(string)
+	category, _ := item["category"].(string)
+	var extra map[string]string
+	if fields, ok := item["extra"].(map[string]interface{}); ok {
+		extra = make(map[string]string, len(fields))
+		for k, v := range fields {
+			if s, ok := v.(string); ok {
+				extra[k] = s
+			} else {
+				extra[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	return Metadata{
+		Title:       title,
+		Description: description,
+		Artwork:     artwork,
+		Category:    category,
+		Extra:       extra,
+	}
+}
+
+// DisplayTitle is the title shown to viewers, falling back to Desc().
+func DisplayTitle(element PlaylistElement) string {
+	if title := element.Meta().Title; title != "" {
+		return title
+	}
+	return element.Desc()
+}
@@ -0,0 +1,391 @@
+// Package playlist is the element model: what can be queued, its
+// metadata and scheduling, and the JSON form it is loaded and saved in.
+package playlist
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type PlaylistElement interface {
+	Type() string
+	Desc() string
+	Meta() Metadata
+	Sched() Scheduling
+	EntryID() string
+}
+
+type VideoElement struct {
+	Metadata
+	Scheduling
+	Entry
+	Path          string `json:"path"`
+	QualityIndex  int    `json:"quality_index,omitempty"`
+	AspectRatio43 bool   `json:"aspect_ratio_4_3,omitempty"`
+	TextBanner    bool   `json:"text_banner,omitempty"`
+	// TrimStart and TrimEnd are the positions, in seconds, where the file
+	// starts and stops airing, e.g. to skip an intro or the credits. 0 means
+	// the start and the end of the file.
+	TrimStart float64 `json:"trim_start,omitempty"`
+	TrimEnd   float64 `json:"trim_end,omitempty"`
+	// Offset is where playback starts, after TrimStart, set by the player
+	// when an item is restarted mid-way (e.g. after a quality downgrade).
+	Offset time.Duration `json:"-"`
+	// CuePoints get a keyframe, so the output can be spliced there. Set by
+	// the player from Scheduling.Breaks, relative to Offset.
+	CuePoints []time.Duration `json:"-"`
+}
+
+// Trimmed is how long the video airs, for a file of duration d.
+func (v VideoElement) Trimmed(d time.Duration) time.Duration {
+	if v.TrimEnd > 0 && Seconds(v.TrimEnd) < d {
+		d = Seconds(v.TrimEnd)
+	}
+	return max(d-Seconds(v.TrimStart), 0)
+}
+
+func Seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func (v VideoElement) Type() string {
+	return "video"
+}
+
+func (v VideoElement) Desc() string {
+	return v.Path
+}
+
+// BannerText is what scrolls in the text banner: the title, or the path
+// without the "/media/n. " folder prefix for untitled items.
+func (v VideoElement) BannerText() string {
+	if v.Title != "" {
+		return v.Title
+	}
+	if len(v.Path) > 10 {
+		return v.Path[10:]
+	}
+	return v.Path
+}
+
+type IdleElement struct {
+	Scheduling
+	Entry
+	IdleSeconds int    `json:"idle_seconds"`
+	Description string `json:"description,omitempty"`
+	// AutoNext replaces Description, at play time, with the description of
+	// the item that actually follows the break.
+	AutoNext bool `json:"auto_next,omitempty"`
+	// NextTitle is filled in by the player with what airs after the break.
+	NextTitle string `json:"-"`
+	// StartsAt is when the following item is due, for the countdown.
+	StartsAt time.Time `json:"-"`
+}
+
+func (i IdleElement) Type() string {
+	return "idle"
+}
+
+func (i IdleElement) Desc() string {
+	if i.Description != "" {
+		return i.Description
+	}
+	return fmt.Sprintf("Idle for %d seconds", i.IdleSeconds)
+}
+
+func (i IdleElement) Meta() Metadata {
+	return Metadata{Title: "Intermission", Description: i.Description}
+}
+
+// PlaylistRefElement plays the saved playlist Name as a single block, so an
+// evening can be composed from reusable blocks.
+type PlaylistRefElement struct {
+	Metadata
+	Scheduling
+	Entry
+	Name string `json:"name"`
+}
+
+func (p PlaylistRefElement) Type() string {
+	return "playlist"
+}
+
+func (p PlaylistRefElement) Desc() string {
+	return "Playlist: " + p.Name
+}
+
+const DefaultTestPatternSeconds = 30
+
+// TestPatternElement airs color bars (or another lavfi test source) with a
+// sine tone, for calibration.
+type TestPatternElement struct {
+	Metadata
+	Scheduling
+	Entry
+	Pattern         string `json:"pattern,omitempty"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+	ToneHz          int    `json:"tone_hz,omitempty"`
+}
+
+func (t TestPatternElement) Type() string {
+	return "testpattern"
+}
+
+func (t TestPatternElement) Desc() string {
+	if t.Pattern == "" {
+		return "Test pattern: smptebars"
+	}
+	return "Test pattern: " + t.Pattern
+}
+
+// AudioElement airs an audio file, rendering Visualizer ("waves", "spectrum"
+// or "artwork" with the Artwork image) as the video track.
+type AudioElement struct {
+	Metadata
+	Scheduling
+	Entry
+	Path       string `json:"path"`
+	Visualizer string `json:"visualizer,omitempty"`
+	// Offset is where playback starts, set by the player when joining the
+	// item in progress.
+	Offset time.Duration `json:"-"`
+}
+
+func (a AudioElement) Type() string {
+	return "audio"
+}
+
+func (a AudioElement) Desc() string {
+	if a.Title != "" {
+		return a.Title
+	}
+	return a.Path
+}
+
+const DefaultTextCardSeconds = 10
+
+// TextCardElement is a generated full-screen card for segment intros, e.g.
+// "SATURDAY NIGHT DOUBLE FEATURE". Colors are ffmpeg color names or #rrggbb.
+type TextCardElement struct {
+	Scheduling
+	Entry
+	Title           string `json:"title"`
+	Subtitle        string `json:"subtitle,omitempty"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+	BackgroundColor string `json:"background_color,omitempty"`
+	TitleColor      string `json:"title_color,omitempty"`
+	SubtitleColor   string `json:"subtitle_color,omitempty"`
+}
+
+func (t TextCardElement) Type() string {
+	return "textcard"
+}
+
+func (t TextCardElement) Desc() string {
+	return "Card: " + t.Title
+}
+
+func (t TextCardElement) Meta() Metadata {
+	return Metadata{Title: t.Title, Description: t.Subtitle}
+}
+
+// LiveElement relays an external live source (RTMP/HLS/RTSP) for
+// DurationSeconds, or until skipped with /next when it is 0.
+type LiveElement struct {
+	Metadata
+	Scheduling
+	Entry
+	URL             string `json:"url"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+	QualityIndex    int    `json:"quality_index,omitempty"`
+	AspectRatio43   bool   `json:"aspect_ratio_4_3,omitempty"`
+}
+
+func (l LiveElement) Type() string {
+	return "live"
+}
+
+func (l LiveElement) Desc() string {
+	if l.Title != "" {
+		return l.Title
+	}
+	return "Live: " + l.URL
+}
+
+// ParseElement converts one /load JSON object into a playlist element.
+// Entries without a known type are skipped (ok is false).
+func ParseElement(item map[string]interface{}) (PlaylistElement, bool) {
+	itemType, ok := item["type"].(string)
+	if !ok {
+		return nil, false
+	}
+
+	switch itemType {
+	case "video":
+		path, _ := item["path"].(string)
+		qualityIndex := 0
+		if qi, ok := item["quality_index"].(float64); ok {
+			qualityIndex = int(qi)
+		}
+		aspectRatio43, _ := item["aspect_ratio_4_3"].(bool)
+		textBanner, _ := item["text_banner"].(bool)
+		trimStart, _ := item["trim_start"].(float64)
+		trimEnd, _ := item["trim_end"].(float64)
+		return VideoElement{
+			Metadata:      parseMetadata(item),
+			Scheduling:    parseScheduling(item),
+			Entry:         parseEntry(item),
+			Path:          path,
+			QualityIndex:  qualityIndex,
+			AspectRatio43: aspectRatio43,
+			TextBanner:    textBanner,
+			TrimStart:     trimStart,
+			TrimEnd:       trimEnd,
+		}, true
+	case "idle":
+		idleSeconds, _ := item["idle_seconds"].(float64)
+		description, _ := item["description"].(string)
+		autoNext, _ := item["auto_next"].(bool)
+		return IdleElement{
+			Scheduling:  parseScheduling(item),
+			Entry:       parseEntry(item),
+			IdleSeconds: int(idleSeconds),
+			Description: description,
+			AutoNext:    autoNext,
+		}, true
+	case "playlist":
+		name, _ := item["name"].(string)
+		return PlaylistRefElement{
+			Metadata:   parseMetadata(item),
+			Scheduling: parseScheduling(item),
+			Entry:      parseEntry(item),
+			Name:       name,
+		}, true
+	case "ident":
+		qualityIndex, _ := item["quality_index"].(float64)
+		return IdentElement{
+			Metadata:     parseMetadata(item),
+			Scheduling:   parseScheduling(item),
+			Entry:        parseEntry(item),
+			QualityIndex: int(qualityIndex),
+		}, true
+	case "testpattern":
+		pattern, _ := item["pattern"].(string)
+		durationSeconds, _ := item["duration_seconds"].(float64)
+		toneHz, _ := item["tone_hz"].(float64)
+		return TestPatternElement{
+			Metadata:        parseMetadata(item),
+			Scheduling:      parseScheduling(item),
+			Entry:           parseEntry(item),
+			Pattern:         pattern,
+			DurationSeconds: int(durationSeconds),
+			ToneHz:          int(toneHz),
+		}, true
+	case "audio":
+		path, _ := item["path"].(string)
+		visualizer, _ := item["visualizer"].(string)
+		return AudioElement{
+			Metadata:   parseMetadata(item),
+			Scheduling: parseScheduling(item),
+			Entry:      parseEntry(item),
+			Path:       path,
+			Visualizer: visualizer,
+		}, true
+	case "textcard":
+		title, _ := item["title"].(string)
+		subtitle, _ := item["subtitle"].(string)
+		durationSeconds, _ := item["duration_seconds"].(float64)
+		backgroundColor, _ := item["background_color"].(string)
+		titleColor, _ := item["title_color"].(string)
+		subtitleColor, _ := item["subtitle_color"].(string)
+		return TextCardElement{
+			Scheduling:      parseScheduling(item),
+			Entry:           parseEntry(item),
+			Title:           title,
+			Subtitle:        subtitle,
+			DurationSeconds: int(durationSeconds),
+			BackgroundColor: backgroundColor,
+			TitleColor:      titleColor,
+			SubtitleColor:   subtitleColor,
+		}, true
+	case "live":
+		url, _ := item["url"].(string)
+		durationSeconds, _ := item["duration_seconds"].(float64)
+		qualityIndex := 0
+		if qi, ok := item["quality_index"].(float64); ok {
+			qualityIndex = int(qi)
+		}
+		aspectRatio43, _ := item["aspect_ratio_4_3"].(bool)
+		return LiveElement{
+			Metadata:        parseMetadata(item),
+			Scheduling:      parseScheduling(item),
+			Entry:           parseEntry(item),
+			URL:             url,
+			DurationSeconds: int(durationSeconds),
+			QualityIndex:    qualityIndex,
+			AspectRatio43:   aspectRatio43,
+		}, true
+	case "series":
+		directory, _ := item["directory"].(string)
+		qualityIndex := 0
+		if qi, ok := item["quality_index"].(float64); ok {
+			qualityIndex = int(qi)
+		}
+		aspectRatio43, _ := item["aspect_ratio_4_3"].(bool)
+		textBanner, _ := item["text_banner"].(bool)
+		return SeriesElement{
+			Metadata:      parseMetadata(item),
+			Scheduling:    parseScheduling(item),
+			Entry:         parseEntry(item),
+			Directory:     directory,
+			QualityIndex:  qualityIndex,
+			AspectRatio43: aspectRatio43,
+			TextBanner:    textBanner,
+		}, true
+	case "random":
+		directory, _ := item["directory"].(string)
+		avoidLast, _ := item["avoid_last"].(float64)
+		qualityIndex := 0
+		if qi, ok := item["quality_index"].(float64); ok {
+			qualityIndex = int(qi)
+		}
+		aspectRatio43, _ := item["aspect_ratio_4_3"].(bool)
+		textBanner, _ := item["text_banner"].(bool)
+		return RandomElement{
+			Metadata:      parseMetadata(item),
+			Scheduling:    parseScheduling(item),
+			Entry:         parseEntry(item),
+			Directory:     directory,
+			AvoidLast:     int(avoidLast),
+			QualityIndex:  qualityIndex,
+			AspectRatio43: aspectRatio43,
+			TextBanner:    textBanner,
+		}, true
+	case "youtube":
+		url, _ := item["url"].(string)
+		qualityIndex := 0
+		if qi, ok := item["quality_index"].(float64); ok {
+			qualityIndex = int(qi)
+		}
+		aspectRatio43, _ := item["aspect_ratio_4_3"].(bool)
+		download, _ := item["download"].(bool)
+		return YouTubeElement{
+			Metadata:      parseMetadata(item),
+			Scheduling:    parseScheduling(item),
+			Entry:         parseEntry(item),
+			URL:           url,
+			QualityIndex:  qualityIndex,
+			AspectRatio43: aspectRatio43,
+			Download:      download,
+		}, true
+	}
+	return nil, false
+}
+
+// IsRemoteURL reports whether a video path points to an http(s) server
+// instead of a file under /media.
+func IsRemoteURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
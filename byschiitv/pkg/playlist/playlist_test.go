@@ -0,0 +1,43 @@
+package playlist
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	in := []PlaylistElement{
+		VideoElement{Path: "/media/a.mkv", QualityIndex: 2, TrimStart: 5, TrimEnd: 60},
+		IdleElement{IdleSeconds: 30},
+		TextCardElement{Title: "Intermission", DurationSeconds: 10},
+	}
+	items, err := EncodeElements(in)
+	if err != nil {
+		t.Fatalf("EncodeElements: %v", err)
+	}
+	out := DecodeElements(items)
+	if len(out) != len(in) {
+		t.Fatalf("decoded %d elements, want %d", len(out), len(in))
+	}
+	for i := range in {
+		if out[i].Type() != in[i].Type() || out[i].Desc() != in[i].Desc() {
+			t.Errorf("element %d = %s %q, want %s %q", i, out[i].Type(), out[i].Desc(), in[i].Type(), in[i].Desc())
+		}
+	}
+	video := out[0].(VideoElement)
+	if video.TrimStart != 5 || video.TrimEnd != 60 || video.QualityIndex != 2 {
+		t.Errorf("video = %+v", video)
+	}
+}
+
+func TestTrimmed(t *testing.T) {
+	v := VideoElement{TrimStart: 10, TrimEnd: 50}
+	if got := v.Trimmed(time.Minute); got != 40*time.Second {
+		t.Errorf("Trimmed = %s, want 40s", got)
+	}
+	// TrimEnd past the end of the file is ignored
+	v.TrimEnd = 90
+	if got := v.Trimmed(time.Minute); got != 50*time.Second {
+		t.Errorf("Trimmed past end = %s, want 50s", got)
+	}
+}
@@ -0,0 +1,144 @@
+package playlist
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+// Late policies for elements with a StartAt.
+const (
+	// latePolicyDelay airs a late element in full, pushing the rest back.
+	latePolicyDelay = "delay"
+	// latePolicyJoin starts a late element mid-way, so it ends on time.
+	latePolicyJoin = "join"
+	// latePolicySkip drops a late element and goes on with the next.
+	latePolicySkip = "skip"
+)
+
+// DefaultLatePolicy applies to elements without a late_policy, typically
+// the ones missed while the server was down. Overridden from LATE_POLICY in
+// main.
+var DefaultLatePolicy = latePolicyDelay
+
+// lateGrace is how late an element may start and still count as on time.
+const lateGrace = time.Second
+
+// Scheduling holds how the player treats an element, as opposed to what it
+// shows (Metadata).
+type Scheduling struct {
+	// LoopCount airs the element this many times in a row (0 and 1 = once).
+	LoopCount int `json:"loop_count,omitempty"`
+	// Weight is the relative chance of being picked in shuffle mode
+	// (0 = default weight 1).
+	Weight float64 `json:"weight,omitempty"`
+	// StartAt, when set, is the scheduled start: the player waits for it if
+	// early, and applies LatePolicy if late.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// LatePolicy is "delay", "join" or "skip", DefaultLatePolicy if empty.
+	LatePolicy string `json:"late_policy,omitempty"`
+	// TimeoutSeconds cancels the element if it is still airing after this
+	// long, counting as a failure (0 = no limit).
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// Breaks are where the element may be cut for ads, see CueEvent.
+	Breaks []CueBreak `json:"breaks,omitempty"`
+}
+
+// Sched makes every element embedding Scheduling satisfy PlaylistElement.
+func (sc Scheduling) Sched() Scheduling {
+	return sc
+}
+
+func parseScheduling(item map[string]interface{}) Scheduling {
+	loopCount, _ := item["loop_count"].(float64)
+	weight, _ := item["weight"].(float64)
+	latePolicy, _ := item["late_policy"].(string)
+	if !ValidLatePolicy(latePolicy) && latePolicy != "" {
+		log.Printf("ignoring late_policy %q", latePolicy)
+		latePolicy = ""
+	}
+	timeout, _ := item["timeout_seconds"].(float64)
+	sc := Scheduling{
+		LoopCount:      int(loopCount),
+		Weight:         weight,
+		LatePolicy:     latePolicy,
+		TimeoutSeconds: int(timeout),
+		Breaks:         parseBreaks(item),
+	}
+	if startAt, ok := item["start_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, startAt); err == nil {
+			sc.StartAt = &t
+		} else {
+			log.Printf("ignoring start_at %q: %v", startAt, err)
+		}
+	}
+	return sc
+}
+
+func ValidLatePolicy(policy string) bool {
+	return policy == latePolicyDelay || policy == latePolicyJoin || policy == latePolicySkip
+}
+
+// latePolicy is LatePolicy, or DefaultLatePolicy when unset.
+func (sc Scheduling) latePolicy() string {
+	if sc.LatePolicy == "" {
+		return DefaultLatePolicy
+	}
+	return sc.LatePolicy
+}
+
+// JoinOffset is how much of a late element to skip under its LatePolicy.
+func (sc Scheduling) JoinOffset(late time.Duration) time.Duration {
+	if sc.latePolicy() != latePolicyJoin || late < lateGrace {
+		return 0
+	}
+	return late
+}
+
+// SkipLate reports whether an element this late is dropped under its
+// LatePolicy.
+func (sc Scheduling) SkipLate(late time.Duration) bool {
+	return sc.latePolicy() == latePolicySkip && late >= lateGrace
+}
+
+// WithTimeout bounds ctx by TimeoutSeconds, when set.
+func (sc Scheduling) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if sc.TimeoutSeconds <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(sc.TimeoutSeconds)*time.Second)
+}
+
+func (sc Scheduling) weight() float64 {
+	if sc.Weight <= 0 {
+		return 1
+	}
+	return sc.Weight
+}
+
+// PickWeighted returns a random playlist index, proportionally to the element
+// weights. The current index is avoided when there is anything else to play.
+func PickWeighted(playlist []PlaylistElement, current int) int {
+	total := 0.0
+	for i, element := range playlist {
+		if i != current || len(playlist) == 1 {
+			total += element.Sched().weight()
+		}
+	}
+	r := rand.Float64() * total
+	for i, element := range playlist {
+		if i == current && len(playlist) > 1 {
+			continue
+		}
+		r -= element.Sched().weight()
+		if r < 0 {
+			return i
+		}
+	}
+	// rounding: fall back to the last candidate
+	if current == len(playlist)-1 && len(playlist) > 1 {
+		return len(playlist) - 2
+	}
+	return len(playlist) - 1
+}
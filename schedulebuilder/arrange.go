@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// shuffled returns items in random order.
+func shuffled(items []string) []string {
+	out := append([]string{}, items...)
+	rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// sortedByDuration returns items shortest first, or longest first when desc
+// is set. Unprobed items go last either way.
+func sortedByDuration(items []string, durations map[string]time.Duration, desc bool) []string {
+	out := append([]string{}, items...)
+	sort.SliceStable(out, func(i, j int) bool {
+		di, iok := durations[out[i]]
+		dj, jok := durations[out[j]]
+		if iok != jok {
+			return iok
+		}
+		if desc {
+			return di > dj
+		}
+		return di < dj
+	})
+	return out
+}
+
+// interleaved alternates the items tagged a with those tagged b, starting
+// with a, in the slots the two groups held. Once a group runs out the other
+// follows in order; untagged items do not move.
+func interleaved(items []string, tags map[string][]string, a, b string) []string {
+	var slots []int
+	var groupA, groupB []string
+	for i, item := range items {
+		switch {
+		case hasTag(tags, item, a):
+			groupA = append(groupA, item)
+		case hasTag(tags, item, b):
+			groupB = append(groupB, item)
+		default:
+			continue
+		}
+		slots = append(slots, i)
+	}
+	var merged []string
+	for len(groupA) > 0 || len(groupB) > 0 {
+		if len(groupA) > 0 {
+			merged = append(merged, groupA[0])
+			groupA = groupA[1:]
+		}
+		if len(groupB) > 0 {
+			merged = append(merged, groupB[0])
+			groupB = groupB[1:]
+		}
+	}
+	out := append([]string{}, items...)
+	for i, slot := range slots {
+		out[slot] = merged[i]
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blockTries is how many random draws pickBlock compares.
+const blockTries = 500
+
+// blockGroup asks for count items tagged tag.
+type blockGroup struct {
+	count int
+	tag   string
+}
+
+// blockSpec is a block to fill, e.g. "2 short + 1 movie <= 150m".
+type blockSpec struct {
+	groups []blockGroup
+	// limit is the longest the block may run, zero for no limit
+	limit time.Duration
+}
+
+func (b blockSpec) String() string {
+	parts := make([]string, len(b.groups))
+	for i, g := range b.groups {
+		parts[i] = fmt.Sprintf("%d %s", g.count, g.tag)
+	}
+	s := strings.Join(parts, " + ")
+	if b.limit > 0 {
+		s += " <= " + formatDuration(b.limit)
+	}
+	return s
+}
+
+// parseBlockSpec reads "N tag + N tag ... [<= duration]", the duration as in
+// time.ParseDuration ("150m", "2h30m").
+func parseBlockSpec(s string) (blockSpec, error) {
+	var spec blockSpec
+	groups, limit, hasLimit := strings.Cut(s, "<=")
+	if hasLimit {
+		d, err := time.ParseDuration(strings.TrimSpace(limit))
+		if err != nil || d <= 0 {
+			return spec, fmt.Errorf("block: bad limit %q", strings.TrimSpace(limit))
+		}
+		spec.limit = d
+	}
+	for _, term := range strings.Split(groups, "+") {
+		fields := strings.Fields(term)
+		if len(fields) != 2 {
+			return spec, fmt.Errorf("block: want \"N tag\", got %q", strings.TrimSpace(term))
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n <= 0 {
+			return spec, fmt.Errorf("block: bad count %q", fields[0])
+		}
+		spec.groups = append(spec.groups, blockGroup{count: n, tag: strings.ToLower(fields[1])})
+	}
+	return spec, nil
+}
+
+// pickBlock draws items for spec from candidates, each used once, keeping
+// the draw that runs longest within the limit. Only probed items are drawn,
+// so the limit can be checked.
+func pickBlock(spec blockSpec, candidates []string, tags map[string][]string, durations map[string]time.Duration) ([]string, time.Duration, error) {
+	pools := make([][]string, len(spec.groups))
+	for i, g := range spec.groups {
+		for _, item := range candidates {
+			if _, ok := durations[item]; ok && hasTag(tags, item, g.tag) {
+				pools[i] = append(pools[i], item)
+			}
+		}
+		if len(pools[i]) < g.count {
+			return nil, 0, fmt.Errorf("block: only %d probed items tagged %s", len(pools[i]), g.tag)
+		}
+	}
+	var best []string
+	var bestTotal time.Duration
+	for try := 0; try < blockTries; try++ {
+		used := map[string]struct{}{}
+		var picked []string
+		var total time.Duration
+		for i, g := range spec.groups {
+			n := 0
+			for _, j := range rand.Perm(len(pools[i])) {
+				if n == g.count {
+					break
+				}
+				item := pools[i][j]
+				if _, ok := used[item]; ok {
+					continue
+				}
+				used[item] = struct{}{}
+				picked = append(picked, item)
+				total += durations[item]
+				n++
+			}
+			if n < g.count {
+				// an item tagged twice was taken by an earlier group
+				picked = nil
+				break
+			}
+		}
+		if picked == nil || (spec.limit > 0 && total > spec.limit) {
+			continue
+		}
+		if best == nil || total > bestTotal {
+			best, bestTotal = picked, total
+		}
+		if spec.limit == 0 {
+			break
+		}
+	}
+	if best == nil {
+		return nil, 0, fmt.Errorf("block: no combination fits in %s", formatDuration(spec.limit))
+	}
+	return best, bestTotal, nil
+}
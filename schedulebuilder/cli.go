@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"textmatch"
+)
+
+// defaultMinScore is the similarity below which a title is left unmatched.
+const defaultMinScore = 0.4
+
+// runCLI is the headless mode: it matches titles against a scanned directory
+// and writes the schedule without starting the TUI, for cron jobs.
+func runCLI(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("schedulebuilder", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	scan := fs.String("scan", "", "media `dir` to match titles against")
+	match := fs.String("match", "", "comma separated `titles`, in airing order")
+	wishlist := fs.String("wishlist", "", "`file` of titles, one per line, matched after --match")
+	minScore := fs.Float64("min-score", defaultMinScore, "similarity in [0,1] a match needs")
+	out := fs.String("out", exportPath(), "schedule `file` to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *scan == "" {
+		return errors.New("--scan is required")
+	}
+	var titles []string
+	for _, t := range strings.Split(*match, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			titles = append(titles, t)
+		}
+	}
+	if *wishlist != "" {
+		lines, err := readWishlist(*wishlist)
+		if err != nil {
+			return err
+		}
+		titles = append(titles, lines...)
+	}
+	if len(titles) == 0 {
+		return errors.New("--match or --wishlist needs at least one title")
+	}
+
+	library := scanMedia(*scan)
+	var planned, unmatched []string
+	for _, title := range titles {
+		file, score := bestMatch(library, title)
+		if file == "" || score < *minScore {
+			unmatched = append(unmatched, title)
+			continue
+		}
+		fmt.Fprintf(stderr, "%s -> %s (%.2f)\n", title, file, score)
+		planned = append(planned, file)
+	}
+	fmt.Fprintf(stderr, "matched %d of %d titles\n", len(planned), len(titles))
+	for _, title := range unmatched {
+		fmt.Fprintf(stderr, "unmatched: %s\n", title)
+	}
+	if len(planned) == 0 {
+		return errors.New("nothing matched, schedule not written")
+	}
+	if err := exportSchedule(*out, *scan, planned, nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "wrote %d items to %s\n", len(planned), *out)
+	return nil
+}
+
+// readWishlist returns the titles of a wishlist file, skipping blank lines
+// and # comments.
+func readWishlist(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var titles []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		titles = append(titles, line)
+	}
+	return titles, sc.Err()
+}
+
+// bestMatch is the library file most similar to title, scored on the whole
+// path and on the bare file name so folders neither help nor hurt too much.
+func bestMatch(library []string, title string) (string, float64) {
+	best, bestScore := "", -1.0
+	for _, file := range library {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		score := max(textmatch.Combined(file, title), textmatch.Combined(name, title))
+		if score > bestScore || (score == bestScore && file < best) {
+			best, bestScore = file, score
+		}
+	}
+	return best, bestScore
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// httpClient talks to the byschiitv API; the server answers /load only after
+// probing remote sources, so allow it some time.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// serverURL is the byschiitv API base, from BYSCHIITV_URL.
+func serverURL() string {
+	if u := os.Getenv("BYSCHIITV_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// pushResultMsg reports the outcome of a POST /load.
+type pushResultMsg struct {
+	count   int
+	version uint64
+	err     error
+}
+
+// pushSchedule POSTs items to the server's /load in the background. A
+// non-zero version is sent as If-Match, so the push fails if someone else
+// changed the server playlist since it was pulled.
+func pushSchedule(items []loadItem, version uint64) tea.Cmd {
+	return func() tea.Msg {
+		body, err := json.Marshal(items)
+		if err != nil {
+			return pushResultMsg{err: err}
+		}
+		req, err := http.NewRequest(http.MethodPost, serverURL()+"/load", bytes.NewReader(body))
+		if err != nil {
+			return pushResultMsg{err: err}
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if version > 0 {
+			req.Header.Set("If-Match", strconv.FormatUint(version, 10))
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return pushResultMsg{err: err}
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusPreconditionFailed {
+			return pushResultMsg{err: fmt.Errorf("the server playlist changed since it was pulled, g to pull it again")}
+		}
+		if resp.StatusCode == http.StatusPreconditionRequired {
+			return pushResultMsg{err: fmt.Errorf("the server wants the playlist version, g to pull it first")}
+		}
+		if resp.StatusCode != http.StatusOK {
+			var e struct {
+				Error string `json:"error"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&e)
+			if e.Error == "" {
+				e.Error = resp.Status
+			}
+			return pushResultMsg{err: fmt.Errorf("server: %s", e.Error)}
+		}
+		var ok struct {
+			Version uint64 `json:"version"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&ok)
+		return pushResultMsg{count: len(items), version: ok.Version}
+	}
+}
+
+// pullResultMsg carries the server playlist, already mapped to local paths.
+type pullResultMsg struct {
+	items    []string
+	specials map[string]loadItem
+	// options are those of items[i]
+	options []itemOptions
+	skipped int
+	version uint64
+	err     error
+}
+
+// fetchServerList reads the server's /list, mapped to local paths. Entries
+// that are not local video files or idle breaks (audio, remote sources...)
+// are counted as skipped.
+func fetchServerList(baseDir string) pullResultMsg {
+	resp, err := httpClient.Get(serverURL() + "/list")
+	if err != nil {
+		return pullResultMsg{err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return pullResultMsg{err: fmt.Errorf("server: %s", resp.Status)}
+	}
+	var list struct {
+		Queue []loadItem `json:"queue"`
+		// Entries[i] describes Queue[i]
+		Entries []struct {
+			Type string `json:"type"`
+		} `json:"entries"`
+		Version uint64 `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return pullResultMsg{err: err}
+	}
+	res := pullResultMsg{items: []string{}, specials: map[string]loadItem{}, version: list.Version}
+	for i, item := range list.Queue {
+		typ := ""
+		if i < len(list.Entries) {
+			typ = list.Entries[i].Type
+		}
+		if typ == "idle" {
+			label := idleLabel(item.IdleSeconds, item.Description)
+			res.specials[label] = loadItem{Type: "idle", IdleSeconds: item.IdleSeconds, Description: item.Description}
+			res.items = append(res.items, label)
+			res.options = append(res.options, itemOptions{})
+			continue
+		}
+		if typ != "video" {
+			res.skipped++
+			continue
+		}
+		local, err := localPath(baseDir, item.Path)
+		if item.Path == "" || err != nil {
+			res.skipped++
+			continue
+		}
+		res.items = append(res.items, local)
+		res.options = append(res.options, item.itemOptions)
+	}
+	return res
+}
+
+// pullSchedule fetches the server playlist in the background, to replace the
+// planned column.
+func pullSchedule(baseDir string) tea.Cmd {
+	return func() tea.Msg {
+		return fetchServerList(baseDir)
+	}
+}
+
+// serverListMsg is the server playlist fetched for duplicate checks only.
+type serverListMsg pullResultMsg
+
+// watchServerList fetches the server playlist in the background, to flag
+// files that are already scheduled there.
+func watchServerList(baseDir string) tea.Cmd {
+	return func() tea.Msg {
+		return serverListMsg(fetchServerList(baseDir))
+	}
+}
+
+// serverProgramme is one entry of the server's /epg.
+type serverProgramme struct {
+	Title           string    `json:"title"`
+	Start           time.Time `json:"start"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	DurationKnown   bool      `json:"duration_known"`
+}
+
+func (p serverProgramme) end() time.Time {
+	return p.Start.Add(time.Duration(p.DurationSeconds * float64(time.Second)))
+}
+
+// epgMsg carries the server programme guide, from the item on air on.
+type epgMsg struct {
+	programmes []serverProgramme
+	err        error
+}
+
+// fetchEPG reads the server's /epg in the background.
+func fetchEPG() tea.Cmd {
+	return func() tea.Msg {
+		resp, err := httpClient.Get(serverURL() + "/epg")
+		if err != nil {
+			return epgMsg{err: err}
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return epgMsg{err: fmt.Errorf("server: %s", resp.Status)}
+		}
+		var epg struct {
+			Programmes []serverProgramme `json:"programmes"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&epg); err != nil {
+			return epgMsg{err: err}
+		}
+		return epgMsg{programmes: epg.Programmes}
+	}
+}
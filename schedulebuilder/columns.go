@@ -1,10 +1,23 @@
 package main
 
+import (
+	"slices"
+	"sort"
+	"strconv"
+)
+
 // Column represents a selectable list of items with cursor navigation
 type Column struct {
 	items    []string
 	cursor   int
 	selected map[int]struct{}
+	// offset is the first item shown when the list is taller than its pane
+	offset int
+	// mark is the start of a range selection, -1 when unset
+	mark int
+	// ids name the rows of the planned column, parallel to items, so what
+	// belongs to a row follows it when rows move. nil in the other columns.
+	ids []string
 }
 
 func newColumn() Column {
@@ -12,11 +25,56 @@ func newColumn() Column {
 		items:    []string{},
 		cursor:   0,
 		selected: make(map[int]struct{}),
+		mark:     -1,
+	}
+}
+
+// newPlannedColumn is a column whose rows get ids.
+func newPlannedColumn() Column {
+	c := newColumn()
+	c.ids = []string{}
+	return c
+}
+
+// rowSeq numbers the planned rows of this run.
+var rowSeq int
+
+func newRowID() string {
+	rowSeq++
+	return strconv.Itoa(rowSeq)
+}
+
+// newRowIDs returns n fresh row ids, none for columns without ids.
+func (c *Column) newRowIDs(n int) []string {
+	if c.ids == nil {
+		return nil
+	}
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = newRowID()
 	}
+	return ids
 }
 
+// rowID is the id of row i, "" when it has none.
+func (c *Column) rowID(i int) string {
+	if i < 0 || i >= len(c.ids) {
+		return ""
+	}
+	return c.ids[i]
+}
+
+// currentID is the id of the row under the cursor, "" when there is none.
+func (c *Column) currentID() string {
+	return c.rowID(c.cursor)
+}
+
+// setItems replaces the items, giving the rows new ids.
 func (c *Column) setItems(items []string) {
 	c.items = items
+	if c.ids != nil {
+		c.ids = c.newRowIDs(len(items))
+	}
 	if c.cursor >= len(items) && len(items) > 0 {
 		c.cursor = len(items) - 1
 	}
@@ -58,12 +116,182 @@ func (c *Column) isSelected(idx int) bool {
 	return ok
 }
 
+// getSelected returns the selected items in list order.
 func (c *Column) getSelected() []string {
-	result := []string{}
+	idxs := make([]int, 0, len(c.selected))
 	for idx := range c.selected {
 		if idx < len(c.items) {
-			result = append(result, c.items[idx])
+			idxs = append(idxs, idx)
 		}
 	}
+	sort.Ints(idxs)
+	result := []string{}
+	for _, idx := range idxs {
+		result = append(result, c.items[idx])
+	}
 	return result
 }
+
+func (c *Column) clearSelection() {
+	c.selected = make(map[int]struct{})
+	c.mark = -1
+}
+
+// setMark starts a range selection at the cursor.
+func (c *Column) setMark() {
+	c.mark = c.cursor
+}
+
+// selectToMark selects every item between the mark and the cursor, included.
+// Without a mark only the cursor item is selected.
+func (c *Column) selectToMark() {
+	from, to := c.mark, c.cursor
+	if from < 0 {
+		from = to
+	}
+	if from > to {
+		from, to = to, from
+	}
+	for i := from; i <= to && i < len(c.items); i++ {
+		c.selected[i] = struct{}{}
+	}
+}
+
+// extendSelection selects the cursor item, moves by delta and selects the
+// item landed on, like shift+arrow in a file manager.
+func (c *Column) extendSelection(delta int) {
+	if c.cursor < 0 || c.cursor >= len(c.items) {
+		return
+	}
+	c.selected[c.cursor] = struct{}{}
+	c.moveCursor(delta)
+	c.selected[c.cursor] = struct{}{}
+}
+
+func (c *Column) invertSelection() {
+	inverted := make(map[int]struct{})
+	for i := range c.items {
+		if _, ok := c.selected[i]; !ok {
+			inverted[i] = struct{}{}
+		}
+	}
+	c.selected = inverted
+}
+
+func (c *Column) appendItems(items ...string) {
+	c.items = append(c.items, items...)
+	if c.ids != nil {
+		c.ids = append(c.ids, c.newRowIDs(len(items))...)
+	}
+}
+
+// moveItem swaps the item under the cursor with its neighbour delta rows away,
+// keeping the cursor on it.
+func (c *Column) moveItem(delta int) {
+	to := c.cursor + delta
+	if c.cursor < 0 || c.cursor >= len(c.items) || to < 0 || to >= len(c.items) {
+		return
+	}
+	c.items[c.cursor], c.items[to] = c.items[to], c.items[c.cursor]
+	if c.ids != nil {
+		c.ids[c.cursor], c.ids[to] = c.ids[to], c.ids[c.cursor]
+	}
+	c.cursor = to
+	c.clearSelection()
+}
+
+// removeCurrent drops the item under the cursor.
+func (c *Column) removeCurrent() {
+	if c.cursor < 0 || c.cursor >= len(c.items) {
+		return
+	}
+	c.items = append(c.items[:c.cursor], c.items[c.cursor+1:]...)
+	if c.ids != nil {
+		c.ids = append(c.ids[:c.cursor], c.ids[c.cursor+1:]...)
+	}
+	if c.cursor >= len(c.items) && c.cursor > 0 {
+		c.cursor--
+	}
+	c.clearSelection()
+}
+
+// duplicateCurrent inserts a copy of the item under the cursor right after it
+// and returns the id of the copy.
+func (c *Column) duplicateCurrent() string {
+	if c.cursor < 0 || c.cursor >= len(c.items) {
+		return ""
+	}
+	item := c.items[c.cursor]
+	c.items = append(c.items[:c.cursor+1], append([]string{item}, c.items[c.cursor+1:]...)...)
+	id := ""
+	if c.ids != nil {
+		id = newRowID()
+		c.ids = slices.Insert(c.ids, c.cursor+1, id)
+	}
+	c.clearSelection()
+	return id
+}
+
+// insertAtCursor inserts item before the one under the cursor, leaving the
+// cursor on the new item.
+func (c *Column) insertAtCursor(item string) {
+	at := c.cursor
+	if at < 0 || at > len(c.items) {
+		at = len(c.items)
+	}
+	c.items = append(c.items[:at], append([]string{item}, c.items[at:]...)...)
+	if c.ids != nil {
+		c.ids = slices.Insert(c.ids, at, newRowID())
+	}
+	c.cursor = at
+	c.clearSelection()
+}
+
+// scrollIntoView moves the viewport of rows items so the cursor is visible.
+func (c *Column) scrollIntoView(rows int) {
+	if rows <= 0 {
+		return
+	}
+	if c.cursor < c.offset {
+		c.offset = c.cursor
+	}
+	if c.cursor >= c.offset+rows {
+		c.offset = c.cursor - rows + 1
+	}
+	if c.offset > len(c.items)-rows {
+		c.offset = len(c.items) - rows
+	}
+	if c.offset < 0 {
+		c.offset = 0
+	}
+}
+
+// replaceItems sets items keeping the cursor and the selection on the same
+// entries, wherever they moved. Entries that are gone lose their selection.
+func (c *Column) replaceItems(items []string) {
+	current := ""
+	if c.cursor >= 0 && c.cursor < len(c.items) {
+		current = c.items[c.cursor]
+	}
+	selected := make(map[string]struct{}, len(c.selected))
+	for i := range c.selected {
+		if i < len(c.items) {
+			selected[c.items[i]] = struct{}{}
+		}
+	}
+	c.selected = make(map[int]struct{})
+	c.mark = -1
+	cursor := -1
+	for i, item := range items {
+		if _, ok := selected[item]; ok {
+			c.selected[i] = struct{}{}
+		}
+		if item == current && cursor < 0 {
+			cursor = i
+		}
+	}
+	if cursor >= 0 {
+		c.cursor = cursor
+	}
+	c.setItems(items)
+}
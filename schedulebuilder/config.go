@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// config is the optional schedulebuilder config file. Every setting but keys
+// has an environment variable, which wins over the file.
+type config struct {
+	// ServerURL is the byschiitv API base (BYSCHIITV_URL)
+	ServerURL string `json:"server_url,omitempty"`
+	// BaseDir is the directory proposed at startup (BASE_DIR)
+	BaseDir string `json:"base_dir,omitempty"`
+	// HostMediaPath and ServerMediaPath are the two sides of the media
+	// mount, on this machine and in the server container (HOST_MEDIA_PATH,
+	// SERVER_MEDIA_PATH)
+	HostMediaPath   string `json:"host_media_path,omitempty"`
+	ServerMediaPath string `json:"server_media_path,omitempty"`
+	// SearchAlgo is the initial search algorithm (SEARCH_ALGO)
+	SearchAlgo string `json:"search_algo,omitempty"`
+	// Keys remaps keys of the main screen, pressed key to default key,
+	// e.g. {"x": "d"} to remove planned items with x
+	Keys map[string]string `json:"keys,omitempty"`
+}
+
+// keyOverrides is the keys section of the config file.
+var keyOverrides map[string]string
+
+// configPath is the config file, from SCHEDULEBUILDER_CONFIG or config.json
+// in the state directory.
+func configPath() string {
+	if p := os.Getenv("SCHEDULEBUILDER_CONFIG"); p != "" {
+		return p
+	}
+	return filepath.Join(stateDir(), "config.json")
+}
+
+// loadConfig reads the config file and applies it. A missing file is not an
+// error.
+func loadConfig() error {
+	file := configPath()
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	for key, to := range c.Keys {
+		if _, ok := keyFromString(to); !ok {
+			return fmt.Errorf("%s: unknown key %q for %q", file, to, key)
+		}
+	}
+	for name, value := range map[string]string{
+		"BYSCHIITV_URL":     c.ServerURL,
+		"BASE_DIR":          c.BaseDir,
+		"HOST_MEDIA_PATH":   c.HostMediaPath,
+		"SERVER_MEDIA_PATH": c.ServerMediaPath,
+		"SEARCH_ALGO":       c.SearchAlgo,
+	} {
+		if _, set := os.LookupEnv(name); set || value == "" {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	keyOverrides = c.Keys
+	return nil
+}
+
+// remapKey applies the configured key overrides to msg.
+func remapKey(msg tea.KeyMsg) tea.KeyMsg {
+	to, ok := keyOverrides[msg.String()]
+	if !ok {
+		return msg
+	}
+	if key, ok := keyFromString(to); ok {
+		return key
+	}
+	return msg
+}
+
+// keyFromString builds the key whose String() is s, e.g. "d", "ctrl+z" or
+// "shift+up".
+func keyFromString(s string) (tea.KeyMsg, bool) {
+	alt := false
+	if rest, ok := strings.CutPrefix(s, "alt+"); ok && rest != "" {
+		alt, s = true, rest
+	}
+	// bubbletea has no parser, so look the name up among the key types
+	for t := tea.KeyType(-128); t < 128; t++ {
+		if t == tea.KeyRunes {
+			continue
+		}
+		if k := (tea.Key{Type: t, Alt: alt}); k.Type.String() == s {
+			return tea.KeyMsg(k), true
+		}
+	}
+	if r := []rune(s); len(r) == 1 {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: r, Alt: alt}, true
+	}
+	return tea.KeyMsg{}, false
+}
@@ -10,10 +10,15 @@ import (
 
 // DirInputScreen handles the initial directory input prompt
 type DirInputScreen struct {
-	input    textinput.Model
-	errMsg   string
-	baseDir  string
-	onAccept func(string) // callback when directory is validated
+	input     textinput.Model
+	errMsg    string
+	baseDir   string
+	onAccept  func(string) // callback when directory is validated
+	recent    []string     // previously used base dirs, most recent first
+	recentIdx int          // -1 while showing what was typed
+	// saved is the session left at the last quit, offered for restore
+	saved *session
+	help  bool
 
 	// terminal dimensions (populated from WindowSizeMsg)
 	width  int
@@ -22,9 +27,9 @@ type DirInputScreen struct {
 
 func newDirInputScreen() DirInputScreen {
 	ti := textinput.New()
-	defaultPath := os.Getenv("HOST_MEDIA_PATH")
+	defaultPath := os.Getenv("BASE_DIR")
 	if defaultPath == "" {
-		defaultPath = "./byschiitv/media"
+		defaultPath = hostMediaPath()
 	}
 	ti.Placeholder = defaultPath
 	ti.SetValue(defaultPath)
@@ -33,13 +38,43 @@ func newDirInputScreen() DirInputScreen {
 	ti.Width = 60
 
 	return DirInputScreen{
-		input:   ti,
-		errMsg:  "",
-		baseDir: defaultPath,
+		input:     ti,
+		errMsg:    "",
+		baseDir:   defaultPath,
+		recent:    loadRecentDirs(),
+		recentIdx: -1,
+		saved:     loadSession(),
 	}
 }
 
 func (d *DirInputScreen) update(msg tea.Msg) tea.Cmd {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		if d.help {
+			d.help = false
+			return nil
+		}
+		if key.String() == "f1" {
+			d.help = true
+			return nil
+		}
+	}
+	if key, ok := msg.(tea.KeyMsg); ok && len(d.recent) > 0 {
+		switch key.String() {
+		case "up":
+			d.recentIdx = (d.recentIdx + 1) % len(d.recent)
+			d.input.SetValue(d.recent[d.recentIdx])
+			d.input.CursorEnd()
+			return nil
+		case "down":
+			d.recentIdx--
+			if d.recentIdx < 0 {
+				d.recentIdx = len(d.recent) - 1
+			}
+			d.input.SetValue(d.recent[d.recentIdx])
+			d.input.CursorEnd()
+			return nil
+		}
+	}
 	var cmd tea.Cmd
 	d.input, cmd = d.input.Update(msg)
 	return cmd
@@ -61,12 +96,22 @@ func (d *DirInputScreen) validate() (bool, string) {
 }
 
 func (d *DirInputScreen) view() string {
+	if d.help {
+		return helpView(helpDirInput)
+	}
 	s := "Enter base directory for videos (press Enter to continue)\n\n"
 	s += d.input.View() + "\n\n"
-	s += fmt.Sprintf("Detected default (from HOST_MEDIA_PATH or fallback): %s\n", d.input.Placeholder)
+	s += fmt.Sprintf("Detected default (from BASE_DIR, HOST_MEDIA_PATH or fallback): %s\n", d.input.Placeholder)
+	if len(d.recent) > 0 {
+		s += fmt.Sprintf("%d recent directories, ↑/↓ to cycle\n", len(d.recent))
+	}
+	if d.saved != nil && len(d.saved.Planned) > 0 {
+		s += fmt.Sprintf("\nctrl+r restores the last session: %d planned items in %s (%s)\n",
+			len(d.saved.Planned), d.saved.BaseDir, d.saved.SavedAt.Format("Mon 15:04"))
+	}
 	if d.errMsg != "" {
 		s += "\nError: " + d.errMsg + "\n"
 	}
-	s += "\nPress ctrl+c to quit.\n"
+	s += "\nPress F1 for help, ctrl+c to quit.\n"
 	return s
 }
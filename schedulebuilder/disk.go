@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileSizes stats files (relative to baseDir). Files that cannot be read are
+// left out.
+func fileSizes(baseDir string, files []string) map[string]int64 {
+	sizes := make(map[string]int64, len(files))
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(baseDir, rel))
+		if err != nil {
+			continue
+		}
+		sizes[rel] = info.Size()
+	}
+	return sizes
+}
+
+// formatSize renders n bytes with a binary unit, e.g. "1.4G".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
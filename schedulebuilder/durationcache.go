@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// probedFile is a probe result and the file state it was probed at.
+type probedFile struct {
+	Size     int64         `json:"size"`
+	ModTime  time.Time     `json:"mtime"`
+	Duration time.Duration `json:"duration"`
+	Height   int           `json:"height,omitempty"`
+}
+
+// durationCache remembers ffprobe results across runs and rescans, valid as
+// long as a file keeps its size and modification time.
+type durationCache struct {
+	mu     sync.Mutex
+	loaded bool
+	files  map[string]probedFile // by absolute path
+	// dirty is set by store until the next save
+	dirty bool
+}
+
+// probeCache is used by probeDurations for every file.
+var probeCache = &durationCache{}
+
+func durationCacheFile() string {
+	return filepath.Join(stateDir(), "durations.json")
+}
+
+// load reads the saved cache the first time it is needed, dropping the
+// entries of files that are gone. A missing or broken file just means
+// probing everything again. Callers hold c.mu.
+func (c *durationCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.files = map[string]probedFile{}
+	data, err := os.ReadFile(durationCacheFile())
+	if err != nil || json.Unmarshal(data, &c.files) != nil {
+		c.files = map[string]probedFile{}
+		return
+	}
+	for path := range c.files {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(c.files, path)
+			c.dirty = true
+		}
+	}
+}
+
+// lookup returns the cached result for path, if the file did not change.
+func (c *durationCache) lookup(path string) (probedFile, os.FileInfo, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return probedFile{}, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	f, ok := c.files[path]
+	if !ok || f.Size != info.Size() || !f.ModTime.Equal(info.ModTime()) {
+		return probedFile{}, info, false
+	}
+	return f, info, true
+}
+
+func (c *durationCache) store(path string, info os.FileInfo, d time.Duration, height int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	c.files[path] = probedFile{Size: info.Size(), ModTime: info.ModTime(), Duration: d, Height: height}
+	c.dirty = true
+}
+
+// save writes the cache if it changed since the last save. It is best
+// effort, like the recent dirs.
+func (c *durationCache) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return
+	}
+	c.dirty = false
+	data, err := json.Marshal(c.files)
+	if err != nil || os.MkdirAll(stateDir(), 0o755) != nil {
+		return
+	}
+	tmp := durationCacheFile() + ".tmp"
+	if os.WriteFile(tmp, data, 0o644) == nil {
+		_ = os.Rename(tmp, durationCacheFile())
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// probeWorkers is how many ffprobe run at once while scanning.
+const probeWorkers = 4
+
+// durationsMsg carries probed durations and video heights, keyed by path
+// relative to baseDir, for the screen of generation gen. Files ffprobe could
+// not read are left out. A library comes back in several batches.
+type durationsMsg struct {
+	gen       int
+	durations map[string]time.Duration
+	heights   map[string]int
+	// next waits for the following batch, nil after the last one
+	next tea.Cmd
+}
+
+// probeDuration asks ffprobe for the container duration of file and the
+// height of its first video stream (0 if none).
+func probeDuration(file string) (time.Duration, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "format=duration:stream=height",
+		"-of", "default=noprint_wrappers=1",
+		file,
+	).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+	var d time.Duration
+	height := 0
+	found := false
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "duration":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+			d = time.Duration(seconds * float64(time.Second))
+			found = true
+		case "height":
+			height, _ = strconv.Atoi(value)
+		}
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("%s: no duration", file)
+	}
+	return d, height, nil
+}
+
+// probeDurations probes files (relative to baseDir) in the background for
+// the screen of generation gen, taking what it can from probeCache. Results
+// come back in batches, each durationsMsg carrying the command that waits
+// for the next one.
+func probeDurations(gen int, baseDir string, files []string) tea.Cmd {
+	if len(files) == 0 {
+		return nil
+	}
+	ps := &probeStream{gen: gen, ready: make(chan struct{}, 1)}
+	ps.reset()
+	go ps.run(baseDir, files)
+	return ps.next
+}
+
+// probeBatchWait is how long a batch of probe results is gathered before it
+// is shown, so a large library fills in steadily without a redraw per file.
+const probeBatchWait = 500 * time.Millisecond
+
+// probeStream collects probe results until the screen takes them. Workers
+// never wait on the screen, so a stream nobody reads any more just ends.
+type probeStream struct {
+	gen     int
+	mu      sync.Mutex
+	pending durationsMsg
+	done    bool
+	// ready is signalled when pending gets results or the stream ends
+	ready chan struct{}
+}
+
+// reset empties pending. Callers hold ps.mu, or own ps.
+func (ps *probeStream) reset() {
+	ps.pending = durationsMsg{gen: ps.gen, durations: map[string]time.Duration{}, heights: map[string]int{}}
+}
+
+func (ps *probeStream) signal() {
+	select {
+	case ps.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (ps *probeStream) run(baseDir string, files []string) {
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for i := 0; i < probeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				path, err := filepath.Abs(filepath.Join(baseDir, rel))
+				if err != nil {
+					continue
+				}
+				f, info, ok := probeCache.lookup(path)
+				if !ok {
+					d, height, err := probeDuration(path)
+					if err != nil {
+						continue
+					}
+					f = probedFile{Duration: d, Height: height}
+					if info != nil {
+						probeCache.store(path, info, d, height)
+					}
+				}
+				ps.mu.Lock()
+				ps.pending.durations[rel] = f.Duration
+				ps.pending.heights[rel] = f.Height
+				ps.mu.Unlock()
+				ps.signal()
+			}
+		}()
+	}
+	for _, rel := range files {
+		jobs <- rel
+	}
+	close(jobs)
+	wg.Wait()
+	ps.mu.Lock()
+	ps.done = true
+	ps.mu.Unlock()
+	ps.signal()
+}
+
+// next waits for the next batch of results and saves the cache.
+func (ps *probeStream) next() tea.Msg {
+	<-ps.ready
+	time.Sleep(probeBatchWait)
+	probeCache.save()
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	msg := ps.pending
+	ps.reset()
+	if !ps.done {
+		msg.next = ps.next
+	}
+	return msg
+}
+
+// formatDuration renders d as h:mm:ss, or m:ss under an hour.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d/time.Minute) % 60
+	s := int(d/time.Second) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
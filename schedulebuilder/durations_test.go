@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDurationCacheSurvivesRuns(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	file := filepath.Join(t.TempDir(), "a.mp4")
+	if err := os.WriteFile(file, []byte("video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := &durationCache{}
+	first.store(file, info, 90*time.Second, 720)
+	first.save()
+
+	second := &durationCache{}
+	f, _, ok := second.lookup(file)
+	if !ok || f.Duration != 90*time.Second || f.Height != 720 {
+		t.Fatalf("lookup after reload = %+v, %v", f, ok)
+	}
+	if err := os.WriteFile(file, []byte("another video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := second.lookup(file); ok {
+		t.Error("a changed file was served from the cache")
+	}
+}
+
+func TestProbeDurationsStreamsBatches(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	old := probeCache
+	probeCache = &durationCache{}
+	t.Cleanup(func() { probeCache = old })
+
+	dir := t.TempDir()
+	files := []string{"a.mp4", "b.mp4", "c.mp4"}
+	for i, rel := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.WriteFile(path, []byte(rel), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := os.Stat(path)
+		probeCache.store(path, info, time.Duration(i+1)*time.Minute, 0)
+	}
+
+	got := map[string]time.Duration{}
+	cmd := probeDurations(7, dir, files)
+	for cmd != nil {
+		msg := cmd().(durationsMsg)
+		if msg.gen != 7 {
+			t.Fatalf("gen = %d, want 7", msg.gen)
+		}
+		for rel, d := range msg.durations {
+			got[rel] = d
+		}
+		cmd = msg.next
+	}
+	for i, rel := range files {
+		if want := time.Duration(i+1) * time.Minute; got[rel] != want {
+			t.Errorf("%s = %v, want %v", rel, got[rel], want)
+		}
+	}
+}
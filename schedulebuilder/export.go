@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// serverMediaPath is where docker-compose mounts HOST_MEDIA_PATH in the
+// byschiitv container, from SERVER_MEDIA_PATH.
+func serverMediaPath() string {
+	if p := os.Getenv("SERVER_MEDIA_PATH"); p != "" {
+		return p
+	}
+	return "/media"
+}
+
+// loadItem is one entry of the byschiitv /load JSON list.
+type loadItem struct {
+	Type        string `json:"type"`
+	Path        string `json:"path,omitempty"`
+	IdleSeconds int    `json:"idle_seconds,omitempty"`
+	Description string `json:"description,omitempty"`
+	itemOptions
+}
+
+// idleLabel is how an idle break is listed in the planned column.
+func idleLabel(seconds int, description string) string {
+	label := fmt.Sprintf("[idle %s]", formatDuration(time.Duration(seconds)*time.Second))
+	if description != "" {
+		label += " " + description
+	}
+	return label
+}
+
+// exportPath returns the default file the schedule is written to, from
+// SCHEDULE_FILE or "schedule.json".
+func exportPath() string {
+	if p := os.Getenv("SCHEDULE_FILE"); p != "" {
+		return p
+	}
+	return "schedule.json"
+}
+
+// hostMediaPath is the host directory docker-compose mounts on the server,
+// from HOST_MEDIA_PATH.
+func hostMediaPath() string {
+	if p := os.Getenv("HOST_MEDIA_PATH"); p != "" {
+		return p
+	}
+	return "./byschiitv/media"
+}
+
+// serverPath maps a file relative to baseDir to the path the server sees,
+// through the HOST_MEDIA_PATH -> /media mount.
+func serverPath(baseDir, rel string) (string, error) {
+	hostMedia := hostMediaPath()
+	hostAbs, err := filepath.Abs(hostMedia)
+	if err != nil {
+		return "", err
+	}
+	fileAbs, err := filepath.Abs(filepath.Join(baseDir, rel))
+	if err != nil {
+		return "", err
+	}
+	inMount, err := filepath.Rel(hostAbs, fileAbs)
+	if err != nil || inMount == ".." || strings.HasPrefix(inMount, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside HOST_MEDIA_PATH (%s)", rel, hostMedia)
+	}
+	return path.Join(serverMediaPath(), filepath.ToSlash(inMount)), nil
+}
+
+// localPath is the inverse of serverPath: a server /media path back to a path
+// relative to baseDir.
+func localPath(baseDir, serverFile string) (string, error) {
+	serverMedia := serverMediaPath()
+	inMount, err := filepath.Rel(filepath.FromSlash(serverMedia), filepath.FromSlash(serverFile))
+	if err != nil || strings.HasPrefix(inMount, "..") {
+		return "", fmt.Errorf("%s is outside %s", serverFile, serverMedia)
+	}
+	hostMedia := hostMediaPath()
+	hostAbs, err := filepath.Abs(hostMedia)
+	if err != nil {
+		return "", err
+	}
+	baseAbs, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(baseAbs, filepath.Join(hostAbs, inMount))
+}
+
+// buildLoadItems turns the planned column into a /load request body. Labels
+// found in specials (idle breaks...) are exported as is, everything else is a
+// video file relative to baseDir, with options[i], if any, for planned[i].
+func buildLoadItems(baseDir string, planned []string, specials map[string]loadItem, options []itemOptions) ([]loadItem, error) {
+	items := make([]loadItem, 0, len(planned))
+	for i, rel := range planned {
+		if special, ok := specials[rel]; ok {
+			items = append(items, special)
+			continue
+		}
+		p, err := serverPath(baseDir, rel)
+		if err != nil {
+			return nil, err
+		}
+		item := loadItem{Type: "video", Path: p}
+		if i < len(options) {
+			item.itemOptions = options[i]
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// exportSchedule writes the planned column as /load compatible JSON.
+func exportSchedule(file, baseDir string, planned []string, specials map[string]loadItem, options []itemOptions) error {
+	items, err := buildLoadItems(baseDir, planned, specials, options)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fillSlack is how far past the target a suggestion may run.
+const fillSlack = 15 * time.Minute
+
+// maxFillSuggestions is how many alternatives the fill assistant offers.
+const maxFillSuggestions = 3
+
+// fillSuggestion is a set of library items that together fill a gap.
+type fillSuggestion struct {
+	items []string
+	total time.Duration
+	gap   time.Duration
+}
+
+func (f fillSuggestion) String() string {
+	diff := f.total - f.gap
+	sign := "+"
+	if diff < 0 {
+		sign = "-"
+		diff = -diff
+	}
+	return fmt.Sprintf("%d items, %s (%s%s): %s", len(f.items), formatDuration(f.total), sign, formatDuration(diff), strings.Join(f.items, ", "))
+}
+
+// suggestFill picks subsets of candidates whose summed durations come closest
+// to gap, at one second resolution. It is a 0/1 knapsack over the reachable
+// sums, so each item is used at most once.
+func suggestFill(candidates []string, durations map[string]time.Duration, gap time.Duration) []fillSuggestion {
+	if gap <= 0 {
+		return nil
+	}
+	limit := int((gap + fillSlack) / time.Second)
+	// from[s] is the candidate that first reached sum s, -1 if unreachable
+	from := make([]int, limit+1)
+	for i := range from {
+		from[i] = -1
+	}
+	secs := make([]int, len(candidates))
+	reached := make([]bool, limit+1)
+	reached[0] = true
+	for i, item := range candidates {
+		secs[i] = int(durations[item].Round(time.Second) / time.Second)
+		if secs[i] <= 0 {
+			continue
+		}
+		for s := limit; s >= secs[i]; s-- {
+			if !reached[s] && reached[s-secs[i]] {
+				reached[s] = true
+				from[s] = i
+			}
+		}
+	}
+
+	target := int(gap / time.Second)
+	var sums []int
+	for s := 1; s <= limit; s++ {
+		if reached[s] {
+			sums = append(sums, s)
+		}
+	}
+	sort.Slice(sums, func(a, b int) bool {
+		return abs(sums[a]-target) < abs(sums[b]-target)
+	})
+
+	var out []fillSuggestion
+	for _, s := range sums {
+		if len(out) == maxFillSuggestions {
+			break
+		}
+		f := fillSuggestion{gap: gap, total: time.Duration(s) * time.Second}
+		for rest := s; rest > 0; rest -= secs[from[rest]] {
+			f.items = append(f.items, candidates[from[rest]])
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
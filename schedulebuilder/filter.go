@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// libraryFilter narrows the scanned column. Zero fields do not filter.
+// Duration and resolution filters drop files that were not probed yet.
+type libraryFilter struct {
+	exts        map[string]struct{}
+	minDuration time.Duration
+	maxDuration time.Duration
+	minHeight   int
+	tag         string
+}
+
+func (f libraryFilter) active() bool {
+	return len(f.exts) > 0 || f.minDuration > 0 || f.maxDuration > 0 || f.minHeight > 0 || f.tag != ""
+}
+
+// parseLibraryFilter reads space separated terms: ext=mkv,mp4 min=20m
+// max=2h res=720 tag=short. An empty string clears the filter.
+func parseLibraryFilter(s string) (libraryFilter, error) {
+	var f libraryFilter
+	for _, term := range strings.Fields(s) {
+		key, value, ok := strings.Cut(term, "=")
+		if !ok {
+			return f, fmt.Errorf("filter term %q: want key=value", term)
+		}
+		var err error
+		switch key {
+		case "ext":
+			f.exts = map[string]struct{}{}
+			for _, ext := range strings.Split(value, ",") {
+				ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+				if ext != "" {
+					f.exts["."+ext] = struct{}{}
+				}
+			}
+		case "min":
+			f.minDuration, err = time.ParseDuration(value)
+		case "max":
+			f.maxDuration, err = time.ParseDuration(value)
+		case "res":
+			f.minHeight, err = strconv.Atoi(strings.TrimSuffix(value, "p"))
+		case "tag":
+			f.tag = strings.ToLower(value)
+		default:
+			return f, fmt.Errorf("unknown filter %q (ext, min, max, res, tag)", key)
+		}
+		if err != nil {
+			return f, fmt.Errorf("filter %s: %w", key, err)
+		}
+	}
+	return f, nil
+}
+
+func (f libraryFilter) String() string {
+	var terms []string
+	if len(f.exts) > 0 {
+		var exts []string
+		for ext := range f.exts {
+			exts = append(exts, strings.TrimPrefix(ext, "."))
+		}
+		terms = append(terms, "ext="+strings.Join(exts, ","))
+	}
+	if f.minDuration > 0 {
+		terms = append(terms, "min="+f.minDuration.String())
+	}
+	if f.maxDuration > 0 {
+		terms = append(terms, "max="+f.maxDuration.String())
+	}
+	if f.minHeight > 0 {
+		terms = append(terms, fmt.Sprintf("res=%d", f.minHeight))
+	}
+	if f.tag != "" {
+		terms = append(terms, "tag="+f.tag)
+	}
+	return strings.Join(terms, " ")
+}
+
+// apply keeps the items passing the filter, in order.
+func (f libraryFilter) apply(items []string, durations map[string]time.Duration, heights map[string]int, tags map[string][]string) []string {
+	if !f.active() {
+		return items
+	}
+	out := []string{}
+	for _, item := range items {
+		if len(f.exts) > 0 {
+			if _, ok := f.exts[strings.ToLower(filepath.Ext(item))]; !ok {
+				continue
+			}
+		}
+		if f.minDuration > 0 || f.maxDuration > 0 {
+			d, ok := durations[item]
+			if !ok || (f.minDuration > 0 && d < f.minDuration) || (f.maxDuration > 0 && d > f.maxDuration) {
+				continue
+			}
+		}
+		if f.minHeight > 0 && heights[item] < f.minHeight {
+			continue
+		}
+		if f.tag != "" && !hasTag(tags, item, f.tag) {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
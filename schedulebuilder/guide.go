@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// guideEntry is one programme of the human readable guide.
+type guideEntry struct {
+	Start time.Time
+	// Length is the formatted duration, "?" when unknown
+	Length      string
+	Title       string
+	Description string
+}
+
+// guidePath returns the file the programme guide is written to, from
+// GUIDE_FILE or "guide.md". A .html name writes HTML, anything else Markdown.
+func guidePath() string {
+	if p := os.Getenv("GUIDE_FILE"); p != "" {
+		return p
+	}
+	return "guide.md"
+}
+
+// buildGuide pairs the /load items with their timeline, so the guide says
+// what the JSON export does.
+func buildGuide(items []loadItem, timeline []timelineEntry) []guideEntry {
+	entries := make([]guideEntry, 0, len(items))
+	for i, item := range items {
+		e := guideEntry{Title: item.Title, Description: item.Description, Length: "?"}
+		if i < len(timeline) {
+			t := timeline[i]
+			e.Start = t.start
+			if t.known {
+				e.Length = formatDuration(t.end.Sub(t.start))
+			}
+		}
+		if e.Title == "" {
+			switch item.Type {
+			case "idle":
+				e.Title = "Break"
+			default:
+				base := path.Base(item.Path)
+				e.Title = strings.TrimSuffix(base, path.Ext(base))
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func guideMarkdown(entries []guideEntry) []byte {
+	var b bytes.Buffer
+	if len(entries) > 0 {
+		fmt.Fprintf(&b, "# Programme guide, %s\n\n", entries[0].Start.Format("Monday 2 January"))
+	}
+	b.WriteString("| Time | Programme | Duration | Description |\n|---|---|---|---|\n")
+	cell := strings.NewReplacer("|", `\|`, "\n", " ")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", e.Start.Format("15:04"), cell.Replace(e.Title), e.Length, cell.Replace(e.Description))
+	}
+	return b.Bytes()
+}
+
+var guideHTML = template.Must(template.New("guide").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Programme guide</title></head>
+<body>
+{{with index . 0}}<h1>Programme guide, {{.Start.Format "Monday 2 January"}}</h1>{{end}}
+<table>
+<tr><th>Time</th><th>Programme</th><th>Duration</th><th>Description</th></tr>
+{{range .}}<tr><td>{{.Start.Format "15:04"}}</td><td>{{.Title}}</td><td>{{.Length}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// exportGuide writes entries to file, as HTML or Markdown by its extension.
+func exportGuide(file string, entries []guideEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("nothing planned")
+	}
+	data := guideMarkdown(entries)
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".html" || ext == ".htm" {
+		var b bytes.Buffer
+		if err := guideHTML.Execute(&b, entries); err != nil {
+			return err
+		}
+		data = b.Bytes()
+	}
+	return os.WriteFile(file, data, 0o644)
+}
@@ -0,0 +1,129 @@
+package main
+
+import "strings"
+
+// helpContext selects which key reference the help overlay shows.
+type helpContext int
+
+const (
+	helpDirInput helpContext = iota
+	helpSearch
+	helpLibrary
+	helpPlanned
+	helpTimeline
+	helpTags
+)
+
+type keyHelp struct {
+	keys string
+	desc string
+}
+
+var helpGlobal = []keyHelp{
+	{"?", "toggle this help (F1 while typing)"},
+	{"q / ctrl+c", "quit (the session is saved)"},
+}
+
+var helpByContext = map[helpContext][]keyHelp{
+	helpDirInput: {
+		{"enter", "scan the directory"},
+		{"↑ / ↓", "cycle recent directories"},
+		{"ctrl+r", "restore the last session"},
+	},
+	helpSearch: {
+		{"type", "narrow the results live"},
+		{"enter", "apply the search"},
+		{"esc", "cancel"},
+		{"↑ / ↓", "search history"},
+		{"ctrl+t", "switch ranking algorithm"},
+	},
+	helpLibrary: {
+		{"↑ / ↓ (k / j)", "move"},
+		{"PgUp / PgDn", "page up / down"},
+		{"Home / End", "first / last item"},
+		{"space / enter", "toggle selection, open/close folder in the tree"},
+		{"shift+↑ / shift+↓", "extend the selection"},
+		{"m / M", "mark range start / select up to the cursor"},
+		{"I / u", "invert / clear the selection"},
+		{"a", "add selection (or item) to the plan"},
+		{"A", "add the top N or all listed results, in order"},
+		{"R", "allow repeats without asking (already planned items are orange)"},
+		{"← / → / tab", "switch column"},
+		{"s", "search"},
+		{"S", "switch search algorithm"},
+		{"f", "filter by extension, duration, resolution"},
+		{"D", "folder tree"},
+		{"o", "preview in an external player"},
+		{"e", "edit base directory"},
+	},
+	helpPlanned: {
+		{"↑ / ↓ (k / j)", "move"},
+		{"PgUp / PgDn", "page up / down"},
+		{"K / J", "move item up / down"},
+		{"d", "remove item"},
+		{"c", "duplicate item"},
+		{"i", "insert idle break"},
+		{"O", "edit playout options: quality, 4:3, banner, trim, start slot"},
+		{"N", "set the display title viewers see"},
+		{"x", "shuffle the planned items"},
+		{"=", "sort by duration, again to reverse"},
+		{"Y", "interleave two tags, e.g. shorts and episodes"},
+		{"ctrl+z / ctrl+y", "undo / redo"},
+		{"← / → / tab", "switch column"},
+	},
+	helpTags: {
+		{"↑ / ↓ (k / j)", "move"},
+		{"enter", "filter the library by the tag"},
+		{"a", "add every library file with the tag"},
+		{"t / esc", "back to the columns"},
+	},
+	helpTimeline: {
+		{"v", "back to the columns"},
+		{"T", "set start time"},
+		{"B", "set end boundary"},
+		{"C", "check conflicts with the server schedule"},
+	},
+}
+
+// helpSchedule are the schedule wide keys of the main screen.
+var helpSchedule = []keyHelp{
+	{"w", "write schedule JSON"},
+	{"E", "write the programme guide (Markdown or HTML)"},
+	{"p", "push to the server /load"},
+	{"g", "pull the server playlist"},
+	{"T", "set start time"},
+	{"F", "fill to a total duration"},
+	{"b", "fill a block by tags, e.g. 2 short + 1 movie <= 150m"},
+	{"t", "browse tags"},
+	{"v", "timeline view"},
+	{"B", "set end boundary"},
+	{"C", "check conflicts with the server schedule"},
+}
+
+var helpTitles = map[helpContext]string{
+	helpDirInput: "Directory",
+	helpSearch:   "Search",
+	helpLibrary:  "Library column",
+	helpPlanned:  "Planned column",
+	helpTimeline: "Timeline",
+	helpTags:     "Tags",
+}
+
+// helpView renders the key reference for ctx.
+func helpView(ctx helpContext) string {
+	var b strings.Builder
+	section := func(title string, keys []keyHelp) {
+		b.WriteString(title + "\n")
+		for _, k := range keys {
+			b.WriteString("  " + k.keys + strings.Repeat(" ", max(1, 18-len([]rune(k.keys)))) + k.desc + "\n")
+		}
+		b.WriteString("\n")
+	}
+	section(helpTitles[ctx], helpByContext[ctx])
+	if ctx == helpLibrary || ctx == helpPlanned {
+		section("Schedule", helpSchedule)
+	}
+	section("Everywhere", helpGlobal)
+	b.WriteString("press any key to close")
+	return b.String()
+}
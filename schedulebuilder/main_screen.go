@@ -2,11 +2,16 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 	"unicode/utf8"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 // MainScreen handles the dual-column selection interface
@@ -14,16 +19,87 @@ type MainScreen struct {
 	baseDir       string
 	scannedColumn Column
 	plannedColumn Column
-	activeColumn  int // 0=scanned, 1=planned (currently unused - always 0)
+	activeColumn  int // 0=scanned, 1=planned
 	search        SearchBox
 	allScanned    []string // full list before search filter
+	filter        libraryFilter
+	// treeMode shows the library as a folder tree in treeColumn
+	treeMode     bool
+	treeColumn   Column
+	treeExpanded map[string]bool
+	heights      map[string]int   // video height of scanned files, from the probe
+	sizes        map[string]int64 // size in bytes of scanned files
+	// tags of scanned files, from their sidecar .tags files
+	tags map[string][]string
+	// sortDesc is the direction of the next sort by duration
+	sortDesc bool
+	// lastBlock is the last block spec, offered again by the block prompt
+	lastBlock string
+	// tagBrowser lists the library tags in tagColumn instead of the columns
+	tagBrowser bool
+	tagColumn  Column
+	status     string // result of the last action, shown under the columns
+	// durations holds the probed runtime of scanned files, filled in
+	// asynchronously after the scan
+	durations map[string]time.Duration
+	// startTime is when the planned schedule starts airing, zero for "now"
+	startTime time.Time
+	prompt    Prompt
+	// fill holds the fill assistant suggestions, fillIdx the one shown
+	fill    []fillSuggestion
+	fillIdx int
+	// specials are planned entries that are not library files, by label
+	specials map[string]loadItem
+	// options are the playout settings of planned files, by row id
+	options map[string]itemOptions
+	// help shows the key reference instead of the screen
+	help bool
+	// undoStack and redoStack hold planned column snapshots
+	undoStack []plannedState
+	redoStack []plannedState
+	// idleSeconds is kept between the two idle break prompts
+	idleSeconds int
+	// timeline shows the planned column on the clock instead of the columns
+	timeline bool
+	// endBoundary is the clock time the schedule should end by, zero for none
+	endBoundary time.Time
+	// info caches ffprobe details for the preview pane; a nil entry means
+	// the probe is still running
+	info    map[string]*mediaInfo
+	infoErr map[string]error
+	// confirm is the pending yes/no question; onConfirm runs on 'y' with the
+	// current screen, as m is copied on every update
+	confirm   string
+	onConfirm func(m *MainScreen) tea.Cmd
+	// onServer holds the files in the server playlist, when it could be read
+	onServer map[string]struct{}
+	// allowRepeats skips the warning when adding already planned items
+	allowRepeats bool
+	// serverStatus is the last poll of what the server is airing
+	serverStatus serverStatusMsg
+	// serverEPG is the server programme guide, read on demand for the
+	// conflict check
+	serverEPG []serverProgramme
+	// serverVersion is the server playlist version last pulled or pushed,
+	// sent back on push to catch concurrent edits; 0 when unknown
+	serverVersion uint64
+	// watcher reports files added to or removed from baseDir, nil when
+	// watching is not possible
+	watcher *mediaWatcher
+	// gen tells this screen's status polls and watch messages from those
+	// of the screens before it, which are dropped
+	gen int
 
 	// terminal dimensions (populated from WindowSizeMsg)
 	width  int
 	height int
 }
 
+// screenGen counts the main screens built, one per base dir picked.
+var screenGen int
+
 func newMainScreen(baseDir string) MainScreen {
+	screenGen++
 	scanned := scanMedia(baseDir)
 	col := newColumn()
 	col.setItems(scanned)
@@ -31,41 +107,432 @@ func newMainScreen(baseDir string) MainScreen {
 	return MainScreen{
 		baseDir:       baseDir,
 		scannedColumn: col,
-		plannedColumn: newColumn(),
+		plannedColumn: newPlannedColumn(),
 		activeColumn:  0,
 		search:        newSearchBox(),
 		allScanned:    scanned,
+		heights:       map[string]int{},
+		sizes:         fileSizes(baseDir, scanned),
+		tags:          loadTags(baseDir, scanned),
+		treeColumn:    newColumn(),
+		tagColumn:     newColumn(),
+		treeExpanded:  map[string]bool{},
+		durations:     map[string]time.Duration{},
+		startTime:     defaultStartTime(),
+		prompt:        newPrompt(),
+		specials:      map[string]loadItem{},
+		options:       map[string]itemOptions{},
+		endBoundary:   defaultEndBoundary(),
+		info:          map[string]*mediaInfo{},
+		infoErr:       map[string]error{},
+		watcher:       newMediaWatcher(baseDir),
+		gen:           screenGen,
+	}
+}
+
+// typing reports whether keys go to a text input rather than to commands.
+func (m *MainScreen) typing() bool {
+	return m.search.active || m.prompt.active()
+}
+
+// init starts probing the scanned files for their durations, reading the
+// server playlist, polling the server status and waiting for changes on disk.
+func (m *MainScreen) init() tea.Cmd {
+	return tea.Batch(probeDurations(m.gen, m.baseDir, m.allScanned), watchServerList(m.baseDir), pollServerStatus(m.gen, 0), m.watcher.next(m.gen))
+}
+
+// rescan reads baseDir again after a change on disk, keeping the cursor,
+// the selection and the search on the entries still there, and probes the
+// new files.
+func (m *MainScreen) rescan() tea.Cmd {
+	scanned := scanMedia(m.baseDir)
+	var unprobed []string
+	for _, file := range scanned {
+		if _, ok := m.durations[file]; !ok {
+			unprobed = append(unprobed, file)
+		}
+	}
+	m.allScanned = scanned
+	m.sizes = fileSizes(m.baseDir, scanned)
+	m.tags = loadTags(m.baseDir, scanned)
+	items := m.library()
+	if query := m.search.value(); query != "" {
+		items = m.search.rank(items, query)
+	}
+	m.scannedColumn.replaceItems(items)
+	if m.treeMode {
+		m.refreshTree()
+	}
+	return probeDurations(m.gen, m.baseDir, unprobed)
+}
+
+func (m *MainScreen) setOnServer(items []string) {
+	m.onServer = make(map[string]struct{}, len(items))
+	for _, item := range items {
+		m.onServer[item] = struct{}{}
 	}
 }
 
 func (m *MainScreen) update(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(durationsMsg); ok {
+		if msg.gen != m.gen {
+			// probing the previous base dir; that stream just ends
+			return nil
+		}
+		for rel, d := range msg.durations {
+			m.durations[rel] = d
+		}
+		for rel, h := range msg.heights {
+			m.heights[rel] = h
+		}
+		if m.filter.active() {
+			m.refreshScanned()
+		}
+		return msg.next
+	}
+
+	if msg, ok := msg.(mediaChangedMsg); ok {
+		if msg.gen != m.gen {
+			return nil
+		}
+		return tea.Batch(m.rescan(), m.watcher.next(m.gen))
+	}
+
+	if msg, ok := msg.(epgMsg); ok {
+		if msg.err != nil {
+			m.status = "conflict check failed: " + msg.err.Error()
+			return nil
+		}
+		m.serverEPG = msg.programmes
+		n := len(findConflicts(m.buildTimeline(m.scheduleStart()), m.serverEPG))
+		if n == 0 {
+			m.status = fmt.Sprintf("no conflicts with the %d programmes on the server", len(m.serverEPG))
+		} else {
+			m.status = fmt.Sprintf("%d conflicting items, marked x in the timeline (v)", n)
+		}
+		return nil
+	}
+
+	if msg, ok := msg.(serverStatusMsg); ok {
+		if msg.gen != m.gen {
+			// a poll started before the base dir changed; its screen's
+			// chain ends here
+			return nil
+		}
+		m.serverStatus = msg
+		return pollServerStatus(m.gen, statusInterval)
+	}
+
+	if msg, ok := msg.(mediaInfoMsg); ok {
+		if msg.err != nil {
+			m.infoErr[msg.item] = msg.err
+			return nil
+		}
+		info := msg.info
+		m.info[msg.item] = &info
+		return nil
+	}
+
+	if msg, ok := msg.(pushResultMsg); ok {
+		if msg.err != nil {
+			m.status = "push failed: " + msg.err.Error()
+		} else {
+			m.status = fmt.Sprintf("loaded %d items on %s", msg.count, serverURL())
+			m.setOnServer(m.plannedColumn.items)
+			m.serverVersion = msg.version
+		}
+		return nil
+	}
+
+	if msg, ok := msg.(serverListMsg); ok {
+		if msg.err == nil {
+			m.setOnServer(msg.items)
+		}
+		return nil
+	}
+
+	if msg, ok := msg.(pullResultMsg); ok {
+		if msg.err != nil {
+			m.status = "pull failed: " + msg.err.Error()
+			return nil
+		}
+		m.checkpoint()
+		m.plannedColumn.setItems(msg.items)
+		m.plannedColumn.clearSelection()
+		m.setOnServer(msg.items)
+		m.serverVersion = msg.version
+		for label, item := range msg.specials {
+			m.addSpecial(label, item)
+		}
+		for i, o := range msg.options {
+			m.setOptions(m.plannedColumn.ids[i], o)
+		}
+		m.status = fmt.Sprintf("pulled %d items from %s", len(msg.items), serverURL())
+		if msg.skipped > 0 {
+			m.status += fmt.Sprintf(" (%d non-file items skipped)", msg.skipped)
+		}
+		return nil
+	}
+
+	// a pending question takes the next key
+	if key, ok := msg.(tea.KeyMsg); ok && m.confirm != "" {
+		run := m.onConfirm
+		m.confirm, m.onConfirm = "", nil
+		if key.String() == "y" {
+			m.status = "working..."
+			return run(m)
+		}
+		m.status = "cancelled"
+		return nil
+	}
+
+	if key, ok := msg.(tea.KeyMsg); ok {
+		if m.help {
+			m.help = false
+			return nil
+		}
+		if key.String() == "f1" || (key.String() == "?" && !m.typing()) {
+			m.help = true
+			return nil
+		}
+	}
+
+	if m.prompt.active() {
+		return m.handlePrompt(msg)
+	}
+
+	// fill suggestions on screen: y accepts, n shows the next one
+	if key, ok := msg.(tea.KeyMsg); ok && len(m.fill) > 0 {
+		switch key.String() {
+		case "y":
+			items := m.fill[m.fillIdx].items
+			m.fill = nil
+			m.addItems(items)
+			return nil
+		case "n":
+			m.fillIdx = (m.fillIdx + 1) % len(m.fill)
+			return nil
+		case "esc":
+			m.fill = nil
+			return nil
+		}
+	}
+
 	// route to search box if active
 	if m.search.active {
 		return m.handleSearchMode(msg)
 	}
 
+	if key, ok := msg.(tea.KeyMsg); ok && m.tagBrowser {
+		return m.updateTagBrowser(key.String())
+	}
+
 	// normal navigation mode
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "s":
+			// search results are a flat list
+			m.treeMode = false
 			m.search.activate()
 			return nil
+		case "f":
+			m.prompt.open(promptLibraryFilter, "filter (ext=mkv,mp4 min=20m max=2h res=720, empty clears):", m.filter.String())
+			return textinput.Blink
+		case "S":
+			m.search.nextAlgo()
+			m.status = "search algorithm: " + m.search.algo.String()
+			return nil
 		case "up", "k":
 			m.activeCol().moveCursor(-1)
 		case "down", "j":
 			m.activeCol().moveCursor(1)
+		case "pgup":
+			m.activeCol().moveCursor(-m.listRows())
+		case "pgdown":
+			m.activeCol().moveCursor(m.listRows())
+		case "home":
+			m.activeCol().moveCursor(-len(m.activeCol().items))
+		case "end":
+			m.activeCol().moveCursor(len(m.activeCol().items))
 		case "enter", " ":
+			if m.activeColumn == 0 && m.treeMode && m.toggleFolder() {
+				break
+			}
 			m.activeCol().toggleSelection()
+		case "D":
+			m.treeMode = !m.treeMode
+			m.refreshTree()
+		case "a":
+			m.addSelected()
+		case "A":
+			m.prompt.open(promptAddTop, "add how many of the listed results (number or all):", "all")
+			return textinput.Blink
+		case "R":
+			m.allowRepeats = !m.allowRepeats
+			m.status = fmt.Sprintf("allow repeats: %v", m.allowRepeats)
+		case "m":
+			m.activeCol().setMark()
+			m.status = "mark set, M selects up to the cursor"
+		case "M":
+			m.activeCol().selectToMark()
+		case "I":
+			m.activeCol().invertSelection()
+		case "u":
+			m.activeCol().clearSelection()
+		case "ctrl+z":
+			m.undo()
+		case "ctrl+y":
+			m.redo()
+		case "w":
+			m.writeSchedule()
+		case "E":
+			m.writeGuide()
+		case "p":
+			m.askPush()
+		case "g":
+			return m.askPull()
+		case "o":
+			m.preview()
+		case "v":
+			m.timeline = !m.timeline
+			return nil
+		case "C":
+			m.status = "checking the schedule against " + serverURL() + "..."
+			return fetchEPG()
+		case "B":
+			end := ""
+			if !m.endBoundary.IsZero() {
+				end = m.endBoundary.Format("15:04")
+			}
+			m.prompt.open(promptEndBoundary, "end by (HH:MM, empty for none):", end)
+			return textinput.Blink
+		case "i":
+			m.prompt.open(promptIdleSeconds, "idle break seconds:", "60")
+			return textinput.Blink
+		case "F":
+			m.prompt.open(promptFillTarget, "fill to total duration (e.g. 4h00m):", "")
+			return textinput.Blink
+		case "t":
+			m.openTagBrowser()
+			return nil
+		case "b":
+			m.prompt.open(promptBlock, "block (e.g. 2 short + 1 movie <= 150m):", m.lastBlock)
+			return textinput.Blink
+		case "T":
+			start := "now"
+			if !m.startTime.IsZero() {
+				start = m.startTime.Format("15:04")
+			}
+			m.prompt.open(promptStartTime, "start time (HH:MM or now):", start)
+			return textinput.Blink
 		case "left", "h":
 			if m.activeColumn > 0 {
 				m.activeColumn--
 			}
 		case "right", "l":
-			// disabled: stay on left column
+			if m.activeColumn < 1 {
+				m.activeColumn++
+			}
+		case "tab":
+			m.activeColumn = 1 - m.activeColumn
+		}
+		if m.activeColumn == 1 {
+			if cmd := m.editPlanned(msg.String()); cmd != nil {
+				return cmd
+			}
+		} else {
+			switch msg.String() {
+			case "shift+up":
+				m.leftCol().extendSelection(-1)
+			case "shift+down":
+				m.leftCol().extendSelection(1)
+			}
 		}
 	}
-	return nil
+	return m.requestInfo()
+}
+
+// currentItem is the library file under the cursor, "" when there is none.
+func (m *MainScreen) currentItem() string {
+	c := m.activeCol()
+	if c.cursor < 0 || c.cursor >= len(c.items) {
+		return ""
+	}
+	if _, ok := m.specials[c.items[c.cursor]]; ok || isDirKey(c.items[c.cursor]) {
+		return ""
+	}
+	return c.items[c.cursor]
+}
+
+// requestInfo starts probing the item under the cursor for the preview pane,
+// unless it is already known or on its way.
+func (m *MainScreen) requestInfo() tea.Cmd {
+	item := m.currentItem()
+	if item == "" {
+		return nil
+	}
+	if _, ok := m.info[item]; ok {
+		return nil
+	}
+	if _, ok := m.infoErr[item]; ok {
+		return nil
+	}
+	m.info[item] = nil
+	return probeMediaInfo(m.baseDir, item)
+}
+
+// infoPane is the one line preview of the item under the cursor.
+func (m *MainScreen) infoPane() string {
+	item := m.currentItem()
+	if item == "" {
+		return ""
+	}
+	if err, ok := m.infoErr[item]; ok {
+		return fmt.Sprintf("%s: probe failed: %v\n", item, err)
+	}
+	s := ""
+	if o, ok := m.options[m.plannedColumn.currentID()]; ok && m.activeColumn == 1 {
+		if o.Title != "" {
+			s = fmt.Sprintf("title: %q, ", o.Title)
+		}
+		s += "options: " + o.String() + "\n"
+	}
+	info := m.info[item]
+	if info == nil {
+		return s + item + ": probing...\n"
+	}
+	return s + fmt.Sprintf("%s: %s\n", item, info)
+}
+
+// setOptions stores o for the planned row id, dropping empty options.
+func (m *MainScreen) setOptions(id string, o itemOptions) {
+	if o.isZero() {
+		delete(m.options, id)
+		return
+	}
+	m.options[id] = o
+}
+
+// rowOptions are the options of the planned rows, in order.
+func (m *MainScreen) rowOptions() []itemOptions {
+	out := make([]itemOptions, len(m.plannedColumn.ids))
+	for i, id := range m.plannedColumn.ids {
+		out[i] = m.options[id]
+	}
+	return out
+}
+
+// airDurations are the durations of the planned rows, by row id, once
+// trimmed by their options.
+func (m *MainScreen) airDurations() map[string]time.Duration {
+	out := make(map[string]time.Duration, len(m.plannedColumn.ids))
+	for i, id := range m.plannedColumn.ids {
+		if d, ok := m.durations[m.plannedColumn.items[i]]; ok {
+			out[id] = m.options[id].trimmed(d)
+		}
+	}
+	return out
 }
 
 func (m *MainScreen) handleSearchMode(msg tea.Msg) tea.Cmd {
@@ -77,13 +544,17 @@ func (m *MainScreen) handleSearchMode(msg tea.Msg) tea.Cmd {
 		case "enter":
 			m.search.commit()
 			query := m.search.value()
-			results := sortByJaccard(m.allScanned, query) // sortByLevenshtein(m.allScanned, query)
+			results := m.search.rank(m.library(), query)
 			m.scannedColumn.setItems(results)
 			m.search.deactivate()
 			return cmd
 		case "esc":
 			m.search.deactivate()
 			return cmd
+		case "ctrl+t":
+			m.search.nextAlgo()
+			m.refreshScanned()
+			return cmd
 		case "up", "k":
 			m.search.prevHistory()
 			return cmd
@@ -94,9 +565,9 @@ func (m *MainScreen) handleSearchMode(msg tea.Msg) tea.Cmd {
 			// live filter as user types
 			query := m.search.value()
 			if query == "" {
-				m.scannedColumn.setItems(m.allScanned)
+				m.scannedColumn.setItems(m.library())
 			} else {
-				results := sortByLevenshtein(m.allScanned, query)
+				results := m.search.rank(m.library(), query)
 				m.scannedColumn.setItems(results)
 			}
 		}
@@ -104,104 +575,799 @@ func (m *MainScreen) handleSearchMode(msg tea.Msg) tea.Cmd {
 	return cmd
 }
 
-func (m *MainScreen) activeCol() *Column {
-	if m.activeColumn == 0 {
-		return &m.scannedColumn
+// addSelected appends the selected scanned items to the planned column, or the
+// item under the cursor when nothing is selected.
+// In the tree, a folder adds every file below it.
+func (m *MainScreen) addSelected() {
+	c := m.leftCol()
+	items := c.getSelected()
+	if len(items) == 0 {
+		if c.cursor < 0 || c.cursor >= len(c.items) {
+			return
+		}
+		items = []string{c.items[c.cursor]}
 	}
-	return &m.plannedColumn
+	if m.treeMode {
+		var files []string
+		for _, key := range items {
+			files = append(files, filesUnder(m.library(), key)...)
+		}
+		items = files
+	}
+	m.addItems(items)
+	c.clearSelection()
 }
 
-func (m *MainScreen) view() string {
-	s := fmt.Sprintf("Schedule Builder — base dir: %s\n", m.baseDir)
+// addItems appends items to the planned column, first asking when some are
+// already planned or on the server, unless repeats are allowed.
+func (m *MainScreen) addItems(items []string) {
+	dups := 0
+	planned := m.plannedSet()
+	for _, item := range items {
+		if _, ok := planned[item]; ok {
+			dups++
+		} else if _, ok := m.onServer[item]; ok {
+			dups++
+		}
+	}
+	if dups == 0 || m.allowRepeats {
+		m.appendPlanned(items)
+		return
+	}
+	m.confirm = fmt.Sprintf("%d of %d items are already planned or on the server, add anyway? (y/n, R allows repeats)", dups, len(items))
+	m.onConfirm = func(m *MainScreen) tea.Cmd {
+		m.appendPlanned(items)
+		return nil
+	}
+}
+
+func (m *MainScreen) appendPlanned(items []string) {
+	m.checkpoint()
+	m.plannedColumn.appendItems(items...)
+	m.status = fmt.Sprintf("added %d items", len(items))
+}
+
+// plannedSet is the set of items in the planned column.
+func (m *MainScreen) plannedSet() map[string]struct{} {
+	set := make(map[string]struct{}, len(m.plannedColumn.items))
+	for _, item := range m.plannedColumn.items {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
+// leftCol is the library column currently shown: flat list or tree.
+func (m *MainScreen) leftCol() *Column {
+	if m.treeMode {
+		return &m.treeColumn
+	}
+	return &m.scannedColumn
+}
+
+// refreshTree rebuilds the tree rows, keeping the cursor and the selection
+// on the same rows.
+func (m *MainScreen) refreshTree() {
+	m.treeColumn.replaceItems(treeRows(m.library(), m.treeExpanded))
+}
+
+// toggleFolder opens or closes the folder under the tree cursor. It reports
+// false when the cursor is on a file.
+func (m *MainScreen) toggleFolder() bool {
+	c := &m.treeColumn
+	if c.cursor < 0 || c.cursor >= len(c.items) || !isDirKey(c.items[c.cursor]) {
+		return false
+	}
+	key := c.items[c.cursor]
+	m.treeExpanded[key] = !m.treeExpanded[key]
+	m.refreshTree()
+	return true
+}
+
+// writeSchedule exports the planned column for the server's /load.
+func (m *MainScreen) writeSchedule() {
+	file := exportPath()
+	if err := exportSchedule(file, m.baseDir, m.plannedColumn.items, m.specials, m.rowOptions()); err != nil {
+		m.status = "export failed: " + err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("wrote %d items to %s", len(m.plannedColumn.items), file)
+}
+
+// writeGuide exports the planned column as a programme guide.
+func (m *MainScreen) writeGuide() {
+	items, err := buildLoadItems(m.baseDir, m.plannedColumn.items, m.specials, m.rowOptions())
+	if err != nil {
+		m.status = "guide failed: " + err.Error()
+		return
+	}
+	file := guidePath()
+	if err := exportGuide(file, buildGuide(items, m.buildTimeline(m.scheduleStart()))); err != nil {
+		m.status = "guide failed: " + err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("wrote the programme guide to %s", file)
+}
+
+// askPush asks for confirmation before replacing the server's playlist.
+func (m *MainScreen) askPush() {
+	items, err := buildLoadItems(m.baseDir, m.plannedColumn.items, m.specials, m.rowOptions())
+	if err != nil {
+		m.status = "push failed: " + err.Error()
+		return
+	}
+	if len(items) == 0 {
+		m.status = "nothing planned to push"
+		return
+	}
+	m.confirm = fmt.Sprintf("replace the playlist on %s with %d items? (y/n)", serverURL(), len(items))
+	m.onConfirm = func(m *MainScreen) tea.Cmd {
+		return pushSchedule(items, m.serverVersion)
+	}
+}
+
+// askPull replaces the planned column with the server playlist, asking first
+// when that would throw away planned items.
+func (m *MainScreen) askPull() tea.Cmd {
+	baseDir := m.baseDir
+	if len(m.plannedColumn.items) == 0 {
+		m.status = "working..."
+		return pullSchedule(baseDir)
+	}
+	m.confirm = fmt.Sprintf("replace the %d planned items with the playlist on %s? (y/n)", len(m.plannedColumn.items), serverURL())
+	m.onConfirm = func(*MainScreen) tea.Cmd {
+		return pullSchedule(baseDir)
+	}
+	return nil
+}
 
-	leftTitle := "Search"
-	rightTitle := "Built so far"
+// editPlanned handles the keys that only make sense on the planned column.
+func (m *MainScreen) editPlanned(key string) tea.Cmd {
+	switch key {
+	case "O":
+		item := m.currentItem()
+		if item == "" {
+			return nil
+		}
+		m.prompt.open(promptItemOptions, "options (quality=N 43=on|off banner=on|off start=s end=s at=HH:MM):", m.options[m.plannedColumn.currentID()].String())
+		return textinput.Blink
+	case "N":
+		item := m.currentItem()
+		if item == "" {
+			return nil
+		}
+		m.prompt.open(promptItemTitle, "display title (empty for the file name):", m.options[m.plannedColumn.currentID()].Title)
+		return textinput.Blink
+	case "Y":
+		m.prompt.open(promptInterleave, "interleave tags (e.g. short episode):", "")
+		return textinput.Blink
+	}
+	switch key {
+	case "shift+up", "K", "shift+down", "J", "d", "delete", "backspace", "c", "x", "=":
+		if len(m.plannedColumn.items) > 0 {
+			m.checkpoint()
+		}
+	}
+	switch key {
+	case "shift+up", "K":
+		m.plannedColumn.moveItem(-1)
+	case "shift+down", "J":
+		m.plannedColumn.moveItem(1)
+	case "d", "delete", "backspace":
+		m.plannedColumn.removeCurrent()
+	case "c":
+		from := m.plannedColumn.currentID()
+		if id := m.plannedColumn.duplicateCurrent(); id != "" {
+			m.setOptions(id, m.options[from])
+		}
+	case "x":
+		m.rearrange(shuffled(m.plannedColumn.ids))
+		m.status = "shuffled the planned items"
+	case "=":
+		m.rearrange(sortedByDuration(m.plannedColumn.ids, m.airDurations(), m.sortDesc))
+		m.status = "sorted shortest first"
+		if m.sortDesc {
+			m.status = "sorted longest first"
+		}
+		m.sortDesc = !m.sortDesc
+	}
+	return nil
+}
 
-	totalWidth := 120
-	if c := os.Getenv("COLUMNS"); c != "" {
-		if v, err := strconv.Atoi(c); err == nil && v > 40 {
-			totalWidth = v
+// rearrange puts the planned rows in the order of ids, a reordering of
+// theirs. Selections are by position, so they are dropped.
+func (m *MainScreen) rearrange(ids []string) {
+	items := make(map[string]string, len(ids))
+	for i, id := range m.plannedColumn.ids {
+		items[id] = m.plannedColumn.items[i]
+	}
+	m.plannedColumn.items = make([]string, len(ids))
+	for i, id := range ids {
+		m.plannedColumn.items[i] = items[id]
+	}
+	m.plannedColumn.ids = ids
+	m.plannedColumn.clearSelection()
+}
+
+func (m *MainScreen) handlePrompt(msg tea.Msg) tea.Cmd {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "enter":
+			kind, value := m.prompt.kind, m.prompt.value()
+			m.prompt.close()
+			m.submitPrompt(kind, value)
+			return nil
+		case "esc":
+			m.prompt.close()
+			return nil
 		}
 	}
-	builtW := 30
-	if totalWidth-builtW < 20 {
-		builtW = totalWidth - 20
-		if builtW < 10 {
-			builtW = 10
+	return m.prompt.update(msg)
+}
+
+// submitPrompt applies the value typed into a prompt of the given kind.
+func (m *MainScreen) submitPrompt(kind promptKind, value string) {
+	switch kind {
+	case promptStartTime:
+		t, err := parseStartTime(value, time.Now())
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.startTime = t
+	case promptFillTarget:
+		target, err := time.ParseDuration(value)
+		if err != nil {
+			m.status = "fill: " + err.Error()
+			return
+		}
+		m.suggestFill(target)
+	case promptItemOptions:
+		item := m.currentItem()
+		if item == "" {
+			return
+		}
+		id := m.plannedColumn.currentID()
+		o, err := parseItemOptions(value, m.options[id], m.scheduleStart())
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.setOptions(id, o)
+		m.status = "options for " + item + ": " + o.String()
+	case promptItemTitle:
+		item := m.currentItem()
+		if item == "" {
+			return
+		}
+		id := m.plannedColumn.currentID()
+		o := m.options[id]
+		o.Title = value
+		m.setOptions(id, o)
+	case promptInterleave:
+		fields := strings.Fields(strings.ToLower(value))
+		if len(fields) != 2 || fields[0] == fields[1] {
+			m.status = "interleave: want two different tags"
+			return
+		}
+		a, b := fields[0], fields[1]
+		if len(m.plannedColumn.items) == 0 {
+			return
+		}
+		m.checkpoint()
+		tags := make(map[string][]string, len(m.plannedColumn.ids))
+		for i, id := range m.plannedColumn.ids {
+			tags[id] = m.tags[m.plannedColumn.items[i]]
+		}
+		m.rearrange(interleaved(m.plannedColumn.ids, tags, a, b))
+		m.status = fmt.Sprintf("interleaved %s and %s", a, b)
+	case promptAddTop:
+		results := m.scannedColumn.items
+		if m.treeMode {
+			results = m.library()
+		}
+		n := len(results)
+		if value != "all" {
+			v, err := strconv.Atoi(value)
+			if err != nil || v <= 0 {
+				m.status = "add: want a positive number or all"
+				return
+			}
+			n = min(v, n)
+		}
+		if n == 0 {
+			return
+		}
+		m.addItems(results[:n])
+	case promptLibraryFilter:
+		f, err := parseLibraryFilter(value)
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.filter = f
+		m.scannedColumn.clearSelection()
+		m.refreshScanned()
+	case promptEndBoundary:
+		t, err := parseStartTime(value, time.Now())
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.endBoundary = t
+	case promptIdleSeconds:
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			m.status = "idle: seconds must be a positive number"
+			return
 		}
+		m.idleSeconds = seconds
+		m.prompt.open(promptIdleDescription, "idle break description (optional):", "")
+	case promptIdleDescription:
+		label := idleLabel(m.idleSeconds, value)
+		m.addSpecial(label, loadItem{Type: "idle", IdleSeconds: m.idleSeconds, Description: value})
+		m.checkpoint()
+		m.plannedColumn.insertAtCursor(label)
+	case promptBlock:
+		m.insertBlock(value)
 	}
-	lw := totalWidth - builtW
-	rw := builtW
+}
 
-	header := fmt.Sprintf("%-*s %-*s\n", lw, leftTitle, rw, rightTitle)
-	s += header
+// insertBlock fills a block spec from the library and inserts it at the
+// planned cursor. Items already planned are not drawn unless repeats are
+// allowed.
+func (m *MainScreen) insertBlock(value string) {
+	spec, err := parseBlockSpec(value)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.lastBlock = value
+	candidates := m.library()
+	if !m.allowRepeats {
+		planned := m.plannedSet()
+		candidates = slices.DeleteFunc(slices.Clone(candidates), func(item string) bool {
+			_, ok := planned[item]
+			return ok
+		})
+	}
+	items, total, err := pickBlock(spec, candidates, m.tags, m.durations)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.checkpoint()
+	for i := len(items) - 1; i >= 0; i-- {
+		m.plannedColumn.insertAtCursor(items[i])
+	}
+	m.status = fmt.Sprintf("inserted block %s: %d items, %s", spec, len(items), formatDuration(total))
+}
 
-	if m.search.active {
-		s += m.search.input.View() + "\n"
-		s += "(type to narrow results, Enter to apply, Esc to cancel)\n\n"
+// preview plays the file under the cursor in an external player.
+func (m *MainScreen) preview() {
+	item := m.currentItem()
+	if item == "" {
+		m.status = "nothing to preview"
+		return
+	}
+	player, err := previewFile(filepath.Join(m.baseDir, item))
+	if err != nil {
+		m.status = "preview failed: " + err.Error()
+		return
 	}
+	m.status = fmt.Sprintf("playing %s in %s", item, player)
+}
 
-	rows := m.scannedColumn.len()
-	if m.plannedColumn.len() > rows {
-		rows = m.plannedColumn.len()
+// addSpecial registers a planned entry that is not a library file.
+func (m *MainScreen) addSpecial(label string, item loadItem) {
+	m.specials[label] = item
+	if item.Type == "idle" {
+		m.durations[label] = time.Duration(item.IdleSeconds) * time.Second
 	}
+}
 
-	for i := 0; i < rows; i++ {
-		left := ""
-		if i < len(m.scannedColumn.items) {
-			left = m.scannedColumn.items[i]
+// suggestFill offers sets of unplanned library items that bring the planned
+// total closest to target.
+func (m *MainScreen) suggestFill(target time.Duration) {
+	total, _ := plannedTotal(m.plannedColumn.ids, m.airDurations())
+	gap := target - total
+	if gap <= 0 {
+		m.status = fmt.Sprintf("already at %s, nothing to fill", formatDuration(total))
+		return
+	}
+	planned := map[string]struct{}{}
+	for _, item := range m.plannedColumn.items {
+		planned[item] = struct{}{}
+	}
+	var candidates []string
+	for _, item := range m.library() {
+		if _, ok := planned[item]; ok {
+			continue
 		}
-		right := ""
-		if i < len(m.plannedColumn.items) {
-			right = m.plannedColumn.items[i]
+		if _, ok := m.durations[item]; ok {
+			candidates = append(candidates, item)
 		}
+	}
+	m.fill = suggestFill(candidates, m.durations, gap)
+	m.fillIdx = 0
+	if len(m.fill) == 0 {
+		m.status = "fill: no combination of unplanned items fits"
+	}
+}
 
-		lcur := " "
-		if m.activeColumn == 0 && m.scannedColumn.cursor == i {
-			lcur = ">"
-		}
-		rcur := " "
-		if m.activeColumn == 1 && m.plannedColumn.cursor == i {
-			rcur = ">"
+// plannedSummary is the running total of the planned column and, from the
+// start time, when it ends.
+func (m *MainScreen) plannedSummary() string {
+	items := m.plannedColumn.items
+	total, unknown := plannedTotal(m.plannedColumn.ids, m.airDurations())
+	s := fmt.Sprintf("planned: %d items, %s", len(items), formatDuration(total))
+	if unknown > 0 {
+		s += fmt.Sprintf(" (+%d unknown)", unknown)
+	}
+	start := m.scheduleStart()
+	s += fmt.Sprintf(", %s → %s", start.Format("15:04"), start.Add(total).Format("Mon 15:04"))
+	s += ", " + formatSize(m.plannedSize())
+	return s
+}
+
+// plannedSize is the total size of the planned files, repeats counted once.
+func (m *MainScreen) plannedSize() int64 {
+	var total int64
+	seen := map[string]struct{}{}
+	for _, item := range m.plannedColumn.items {
+		if _, ok := seen[item]; ok {
+			continue
 		}
+		seen[item] = struct{}{}
+		total += m.sizes[item]
+	}
+	return total
+}
 
-		lchk := " "
-		if m.scannedColumn.isSelected(i) {
-			lchk = "x"
+// storageSummary is the free space on the server media volume. Planned files
+// outside HOST_MEDIA_PATH have to be copied there first, so it warns when
+// they would not fit.
+func (m *MainScreen) storageSummary() string {
+	free, err := freeSpace(hostMediaPath())
+	if err != nil {
+		return hintStyle.Render("media volume: " + err.Error())
+	}
+	var toCopy int64
+	seen := map[string]struct{}{}
+	for _, item := range m.plannedColumn.items {
+		if _, ok := seen[item]; ok {
+			continue
+		}
+		seen[item] = struct{}{}
+		if _, special := m.specials[item]; special {
+			continue
 		}
+		if _, err := serverPath(m.baseDir, item); err != nil {
+			toCopy += m.sizes[item]
+		}
+	}
+	s := fmt.Sprintf("media volume: %s free", formatSize(free))
+	if toCopy == 0 {
+		return s
+	}
+	s += fmt.Sprintf(", %s to copy", formatSize(toCopy))
+	if toCopy > free {
+		return errorStyle.Render(s + " - does not fit")
+	}
+	return s
+}
 
-		leftWidth := lw - 6
-		if leftWidth < 8 {
-			leftWidth = lw - 4
-			if leftWidth < 0 {
-				leftWidth = 0
-			}
+// library is the scanned files passing the library filter.
+func (m *MainScreen) library() []string {
+	return m.filter.apply(m.allScanned, m.durations, m.heights, m.tags)
+}
+
+// refreshScanned recomputes the scanned column from the library filter and
+// the current search query.
+func (m *MainScreen) refreshScanned() {
+	if m.treeMode {
+		m.refreshTree()
+	}
+	query := m.search.value()
+	if query == "" {
+		m.scannedColumn.setItems(m.library())
+		return
+	}
+	m.scannedColumn.setItems(m.search.rank(m.library(), query))
+}
+
+func (m *MainScreen) activeCol() *Column {
+	if m.activeColumn == 0 {
+		return m.leftCol()
+	}
+	return &m.plannedColumn
+}
+
+// timelineView lists the planned items with their clock times. Items that
+// start late or overlap the server schedule are marked with x, items that
+// cross midnight with *, items running past the end boundary with !, and
+// times after an unprobed item with ~.
+func (m *MainScreen) timelineView() string {
+	start := m.scheduleStart()
+	s := fmt.Sprintf("Timeline from %s", start.Format("Mon 15:04"))
+	if !m.endBoundary.IsZero() {
+		s += ", end by " + m.endBoundary.Format("15:04")
+	}
+	s += "\n\n"
+	approx := false
+	entries := m.buildTimeline(start)
+	conflicts := findConflicts(entries, m.serverEPG)
+	for i, e := range entries {
+		cur := " "
+		if m.plannedColumn.cursor == i {
+			cur = ">"
+		}
+		mark := " "
+		reason, conflict := conflicts[i]
+		if conflict {
+			mark = "x"
+		} else if e.pastBoundary {
+			mark = "!"
+		} else if e.crossesMidnight {
+			mark = "*"
+		}
+		tilde := " "
+		if approx {
+			tilde = "~"
+		}
+		if !e.known {
+			approx = true
 		}
-		rightWidth := rw - 4
-		if rightWidth < 0 {
-			rightWidth = 0
+		end := e.end.Format("15:04")
+		if !e.known {
+			end = " ?? "
 		}
+		s += fmt.Sprintf("%s %s %s%s-%s %s", cur, mark, tilde, e.start.Format("15:04"), end, e.item)
+		if conflict {
+			s += errorStyle.Render("  " + reason)
+		}
+		s += "\n"
+	}
+	return s
+}
+
+func (m *MainScreen) buildTimeline(start time.Time) []timelineEntry {
+	slots := map[string]time.Time{}
+	for id, o := range m.options {
+		if at, ok := o.startAt(); ok {
+			slots[id] = at
+		}
+	}
+	return buildTimeline(m.plannedColumn.items, m.plannedColumn.ids, m.airDurations(), slots, start, m.endBoundary)
+}
+
+// scheduleStart is the start time, now when unset.
+func (m *MainScreen) scheduleStart() time.Time {
+	if m.startTime.IsZero() {
+		return time.Now()
+	}
+	return m.startTime
+}
+
+// helpContext is the mode the help overlay describes.
+func (m *MainScreen) helpContext() helpContext {
+	switch {
+	case m.search.active:
+		return helpSearch
+	case m.tagBrowser:
+		return helpTags
+	case m.timeline:
+		return helpTimeline
+	case m.activeColumn == 1:
+		return helpPlanned
+	default:
+		return helpLibrary
+	}
+}
+
+func (m *MainScreen) view() string {
+	if m.help {
+		return helpView(m.helpContext())
+	}
+	if m.tagBrowser {
+		return m.tagBrowserView() + "\n t back to columns, ? for help.\n" + m.footer()
+	}
+	if m.timeline {
+		return m.timelineView() + "\n v back to columns, ? for help.\n" + m.footer()
+	}
+	header, bottom := m.header(), m.bottom()
 
-		leftPrinted := truncate(left, leftWidth)
-		rightPrinted := truncate(right, rightWidth)
+	leftTitle := "Library (" + m.search.algo.String() + ")"
+	if m.filter.active() {
+		leftTitle += " [" + m.filter.String() + "]"
+	}
+	rightTitle := "Planned"
+
+	paneHeight := m.paneHeight(header, bottom)
+	leftWidth := m.width*2/3 - paneStyle.GetHorizontalFrameSize()
+	rightWidth := m.width - m.width*2/3 - paneStyle.GetHorizontalFrameSize()
+	if leftWidth < 10 {
+		leftWidth = 10
+	}
+	if rightWidth < 10 {
+		rightWidth = 10
+	}
+
+	left := m.pane(leftTitle, m.leftCol(), m.activeColumn == 0, true, leftWidth, paneHeight)
+	right := m.pane(rightTitle, &m.plannedColumn, m.activeColumn == 1, false, rightWidth, paneHeight)
+	return header + lipgloss.JoinHorizontal(lipgloss.Top, left, right) + "\n" + bottom
+}
+
+// duplicates are the items to flag: in the library, those already planned or
+// on the server; in the planned column, those planned more than once.
+func (m *MainScreen) duplicates(library bool) map[string]bool {
+	dups := map[string]bool{}
+	if library {
+		for item := range m.plannedSet() {
+			dups[item] = true
+		}
+		for item := range m.onServer {
+			dups[item] = true
+		}
+		return dups
+	}
+	seen := map[string]bool{}
+	for _, item := range m.plannedColumn.items {
+		if _, special := m.specials[item]; special {
+			continue
+		}
+		if seen[item] {
+			dups[item] = true
+		}
+		seen[item] = true
+	}
+	return dups
+}
 
-		s += fmt.Sprintf("%s [%s] %-*s %s %-*s\n",
-			lcur, lchk, leftWidth, leftPrinted,
-			rcur, rightWidth, rightPrinted,
-		)
+// header is what the main view shows above the panes.
+func (m *MainScreen) header() string {
+	header := fmt.Sprintf("base dir: %s\n", m.baseDir)
+	if serverConfigured() {
+		header += m.serverStatus.view() + "\n"
 	}
+	if m.search.active {
+		header += m.search.input.View() + "\n"
+		if m.search.err != nil {
+			header += errorStyle.Render("invalid regex: "+m.search.err.Error()) + "\n"
+		}
+		header += hintStyle.Render("(type to narrow results, Enter to apply, Esc to cancel, ctrl+t to switch algorithm)") + "\n"
+	}
+	return header
+}
 
+// bottom is what the main view shows below the panes.
+func (m *MainScreen) bottom() string {
 	// show 'e' hint only when not searching
+	hint := "↑/↓ to move, PgUp/PgDn to page, space/enter to toggle selection, a to add to plan, s to search, e to edit base dir, ? for help, q to quit."
 	if m.search.active {
-		s += "\n ↑/↓ to move, space/enter to toggle selection, q to quit, s to search.\n"
-	} else {
-		s += "\n ↑/↓ to move, space/enter to toggle selection, q to quit, s to search, e to edit base dir.\n"
+		hint = "↑/↓ history, Enter to apply, Esc to cancel, F1 for help."
+	}
+	return m.infoPane() + m.footer() + hintStyle.Render(hint)
+}
+
+// paneHeight is the inner height of the panes: what header and bottom leave.
+func (m *MainScreen) paneHeight(header, bottom string) int {
+	h := m.height - strings.Count(header, "\n") - lipgloss.Height(bottom) - paneStyle.GetVerticalFrameSize()
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+// listRows is how many items fit in a pane, below its title.
+func (m *MainScreen) listRows() int {
+	return m.paneHeight(m.header(), m.bottom()) - 1
+}
+
+// keepCursorVisible scrolls both columns after the cursor or the layout
+// changed.
+func (m *MainScreen) keepCursorVisible() {
+	rows := m.listRows()
+	m.leftCol().scrollIntoView(rows)
+	m.plannedColumn.scrollIntoView(rows)
+}
+
+// pane renders a column in a bordered box of the given inner size. Library
+// panes show a selection checkbox.
+func (m *MainScreen) pane(title string, c *Column, active, checkbox bool, width, height int) string {
+	rows := height - 1
+	if len(c.items) > rows {
+		// scroll indicators and position
+		end := min(c.offset+rows, len(c.items))
+		pos := fmt.Sprintf(" %d-%d/%d", c.offset+1, end, len(c.items))
+		if c.offset > 0 {
+			pos += " ▲"
+		}
+		if end < len(c.items) {
+			pos += " ▼"
+		}
+		title = truncate(title, width-len([]rune(pos))) + pos
+	}
+	dups := m.duplicates(checkbox)
+	lines := []string{paneTitleStyle.Render(truncate(title, width))}
+	for i := c.offset; i < len(c.items) && i < c.offset+rows; i++ {
+		item := c.items[i]
+		prefix := "  "
+		if checkbox {
+			prefix = "[ ] "
+			if c.isSelected(i) {
+				prefix = "[x] "
+			}
+		}
+		labelWidth := width - lipgloss.Width(prefix)
+		label := m.label(item, labelWidth)
+		if m.treeMode && checkbox {
+			label = m.labelAs(item, treeLabel(item, m.treeExpanded), labelWidth)
+		} else if title := m.options[c.rowID(i)].Title; title != "" && !checkbox {
+			label = m.labelAs(item, "“"+title+"”", labelWidth)
+		}
+		line := fmt.Sprintf("%s%-*s", prefix, labelWidth, label)
+		switch {
+		case i == c.cursor && active:
+			line = cursorStyle.Render(line)
+		case c.isSelected(i):
+			line = selectedStyle.Render(line)
+		case dups[item]:
+			line = duplicateStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	style := paneStyle
+	if active {
+		style = activePaneStyle
 	}
+	return style.Width(width).Height(height).Render(strings.Join(lines, "\n"))
+}
 
-	s += fmt.Sprintf("%d x %d", m.height, m.width)
+// footer is the planned summary followed by whatever waits for the user: a
+// prompt, fill suggestions, a question or the last status.
+func (m *MainScreen) footer() string {
+	s := m.plannedSummary() + "\n" + m.storageSummary() + "\n"
+	if m.prompt.active() {
+		s += m.prompt.view() + "\n"
+	} else if len(m.fill) > 0 {
+		s += fmt.Sprintf("fill %d/%d: %s\n(y accept, n next, esc dismiss)\n", m.fillIdx+1, len(m.fill), m.fill[m.fillIdx])
+	} else if m.confirm != "" {
+		s += m.confirm + "\n"
+	} else if m.status != "" {
+		s += m.status + "\n"
+	}
 	return s
 }
 
+// durationWidth is the room taken by the duration shown after each item,
+// sizeWidth by the file size before it.
+const (
+	durationWidth = 9
+	sizeWidth     = 8
+)
+
+// label fits item in width, followed by its size and duration when known and
+// there is room for them.
+func (m *MainScreen) label(item string, width int) string {
+	return m.labelAs(item, item, width)
+}
+
+// labelAs is label showing name in place of the item itself.
+func (m *MainScreen) labelAs(item, name string, width int) string {
+	suffix := ""
+	if size, ok := m.sizes[item]; ok && width >= 2*(durationWidth+sizeWidth) {
+		suffix += fmt.Sprintf(" %*s", sizeWidth-1, formatSize(size))
+	}
+	if d, ok := m.durations[item]; ok && width >= 2*durationWidth {
+		suffix += fmt.Sprintf(" %*s", durationWidth-1, formatDuration(d))
+	}
+	if suffix == "" {
+		return truncate(name, width)
+	}
+	nameWidth := width - utf8.RuneCountInString(suffix)
+	return fmt.Sprintf("%-*s%s", nameWidth, truncate(name, nameWidth), suffix)
+}
+
 func truncate(s string, max int) string {
 	if max <= 0 {
 		return ""
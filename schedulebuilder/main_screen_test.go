@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestStalePollsAreDropped(t *testing.T) {
+	t.Setenv("BYSCHIITV_URL", "http://127.0.0.1:1")
+	old := newMainScreen(t.TempDir())
+	old.watcher.close()
+	m := newMainScreen(t.TempDir())
+	defer m.watcher.close()
+
+	if cmd := m.update(serverStatusMsg{Title: "stale", gen: old.gen}); cmd != nil {
+		t.Error("a stale status poll re-armed itself")
+	}
+	if m.serverStatus.Title != "" {
+		t.Errorf("stale status shown: %q", m.serverStatus.Title)
+	}
+	if cmd := m.update(serverStatusMsg{Title: "now", gen: m.gen}); cmd == nil {
+		t.Error("the current status poll was not re-armed")
+	}
+	if m.serverStatus.Title != "now" {
+		t.Errorf("status = %q, want now", m.serverStatus.Title)
+	}
+	if cmd := m.update(mediaChangedMsg{gen: old.gen}); cmd != nil {
+		t.Error("a change from a closed watcher triggered a rescan")
+	}
+}
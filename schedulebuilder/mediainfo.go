@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mediaInfo is the ffprobe summary shown in the preview pane.
+type mediaInfo struct {
+	Width      int
+	Height     int
+	VideoCodec string
+	// Audio lists the audio tracks as "codec (language)"
+	Audio    []string
+	Duration time.Duration
+	Size     int64
+}
+
+func (i mediaInfo) String() string {
+	video := "no video"
+	if i.VideoCodec != "" {
+		video = fmt.Sprintf("%dx%d %s", i.Width, i.Height, i.VideoCodec)
+	}
+	audio := "no audio"
+	if len(i.Audio) > 0 {
+		audio = strings.Join(i.Audio, ", ")
+	}
+	return fmt.Sprintf("%s | audio: %s | %s | %.1f MB", video, audio, formatDuration(i.Duration), float64(i.Size)/1e6)
+}
+
+// mediaInfoMsg carries the probe result for item (relative to baseDir).
+type mediaInfoMsg struct {
+	item string
+	info mediaInfo
+	err  error
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		Tags      struct {
+			Language string `json:"language"`
+		} `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// probeMediaInfo runs ffprobe on item in the background.
+func probeMediaInfo(baseDir, item string) tea.Cmd {
+	return func() tea.Msg {
+		file := filepath.Join(baseDir, item)
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "ffprobe",
+			"-v", "error",
+			"-show_streams",
+			"-show_format",
+			"-of", "json",
+			file,
+		).Output()
+		if err != nil {
+			return mediaInfoMsg{item: item, err: err}
+		}
+		var probe ffprobeOutput
+		if err := json.Unmarshal(out, &probe); err != nil {
+			return mediaInfoMsg{item: item, err: err}
+		}
+		var info mediaInfo
+		for _, st := range probe.Streams {
+			switch st.CodecType {
+			case "video":
+				if info.VideoCodec == "" {
+					info.VideoCodec = st.CodecName
+					info.Width, info.Height = st.Width, st.Height
+				}
+			case "audio":
+				track := st.CodecName
+				if st.Tags.Language != "" {
+					track += " (" + st.Tags.Language + ")"
+				}
+				info.Audio = append(info.Audio, track)
+			}
+		}
+		if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+			info.Duration = time.Duration(seconds * float64(time.Second))
+		}
+		if st, err := os.Stat(file); err == nil {
+			info.Size = st.Size()
+		}
+		return mediaInfoMsg{item: item, info: info}
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"strings"
+	"os"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -40,20 +40,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		// pass dimensions to current screen
-		if m.state == screenDirInput {
-			m.dirInput.width = m.width
-			m.dirInput.height = m.height
-		}
-		if m.state == screenMain {
-			m.mainScreen.width = m.width
-			m.mainScreen.height = m.height
-		}
+		m.screenSize()
+	}
+
+	// key overrides from the config file, not while typing text
+	if key, ok := msg.(tea.KeyMsg); ok && m.state == screenMain && !m.mainScreen.typing() {
+		msg = remapKey(key)
 	}
 
 	// global quit
 	if msg, ok := msg.(tea.KeyMsg); ok {
-		if msg.String() == "ctrl+c" || (m.state == screenMain && msg.String() == "q") {
+		if msg.String() == "ctrl+c" || (m.state == screenMain && msg.String() == "q" && !m.mainScreen.typing()) {
+			// an empty planned column would overwrite the session ctrl+r
+			// restores, so it is not saved
+			if m.state == screenMain && len(m.mainScreen.plannedColumn.items) > 0 {
+				// nowhere to report a failure once the screen is gone
+				_ = saveSession(m.mainScreen.session())
+			}
 			return m, tea.Quit
 		}
 	}
@@ -68,12 +71,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) updateDirInput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok && key.String() == "ctrl+r" && m.dirInput.saved != nil && !m.dirInput.help {
+		saved := m.dirInput.saved
+		if info, err := os.Stat(saved.BaseDir); err != nil || !info.IsDir() {
+			m.dirInput.errMsg = "saved session base dir is gone: " + saved.BaseDir
+			return m, nil
+		}
+		m.state = screenMain
+		m.mainScreen = newMainScreen(saved.BaseDir)
+		m.mainScreen.restore(saved)
+		m.screenSize()
+		return m, m.mainScreen.init()
+	}
+
+	// a key that closes the help does nothing else
+	helpOpen := m.dirInput.help
 	cmd := m.dirInput.update(msg)
 
-	if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "enter" {
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "enter" && !helpOpen {
 		if valid, path := m.dirInput.validate(); valid {
+			// best effort, the history is only a convenience
+			_ = rememberDir(path)
 			m.state = screenMain
 			m.mainScreen = newMainScreen(path)
+			m.screenSize()
+			return m, tea.Batch(cmd, m.mainScreen.init())
 		}
 	}
 	return m, cmd
@@ -84,8 +106,9 @@ func (m model) updateMain(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// the base directory. Prefill the input with the current base dir so
 	// confirming will create a new main screen (which re-runs the scan).
 	if key, ok := msg.(tea.KeyMsg); ok {
-		// don't allow 'e' to trigger directory edit while the search box is active
-		if key.String() == "e" && !m.mainScreen.search.active {
+		// don't allow 'e' to trigger directory edit while typing in the search box or a prompt
+		if key.String() == "e" && !m.mainScreen.typing() && !m.mainScreen.help {
+			m.mainScreen.watcher.close()
 			d := newDirInputScreen()
 			// prefill with current base dir
 			d.input.SetValue(m.mainScreen.baseDir)
@@ -93,50 +116,29 @@ func (m model) updateMain(msg tea.Msg) (tea.Model, tea.Cmd) {
 			d.baseDir = m.mainScreen.baseDir
 			m.dirInput = d
 			m.state = screenDirInput
+			m.screenSize()
 			return m, nil
 		}
 	}
 
 	cmd := m.mainScreen.update(msg)
+	m.mainScreen.keepCursorVisible()
 	return m, cmd
 }
 
 func (m model) View() string {
-
-	/*switch m.state {
+	title := titleStyle.Width(m.width).Render("Schedule Builder (ctrl+c to quit)")
+	switch m.state {
 	case screenDirInput:
-		return m.dirInput.view()
+		return title + "\n" + m.dirInput.view()
 	case screenMain:
-		return m.mainScreen.view()
-	}*/
-	return drawBorder(m.width, m.height)
-}
-
-func drawBorder(width, height int) string {
-	titleHeight := 1
-	title := " Schedule Builder (ctrl+c to quit) "
-
-	s := "\n\n"
-	// top border
-	s += "┌" + repeat("─", width-2) + "┐\n"
-	// title
-	s += "│" + title + strings.Repeat(" ", width-2-len(title)) + "│\n"
-	s += "├" + repeat("─", width-2) + "┤\n"
-
-	// empty space
-	for i := 0; i < height-titleHeight-1-3; i++ {
-		s += "│" + repeat(" ", width-2) + "│\n"
+		return title + "\n" + m.mainScreen.view()
 	}
-
-	// bottom border
-	s += "└" + repeat("─", width-2) + "┘\n"
-	return s
+	return title
 }
 
-func repeat(s string, count int) string {
-	result := ""
-	for i := 0; i < count; i++ {
-		result += s
-	}
-	return result
+// screenSize passes the terminal size, less the title bar, to the screens.
+func (m *model) screenSize() {
+	m.dirInput.width, m.dirInput.height = m.width, m.height-1
+	m.mainScreen.width, m.mainScreen.height = m.width, m.height-1
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// itemOptions are the per-item playout settings of a planned file, exported
+// with its /load entry. They are keyed by planned row id, so each time an
+// item is planned it has its own.
+type itemOptions struct {
+	// Title is the display name viewers see instead of the file name
+	Title         string  `json:"title,omitempty"`
+	QualityIndex  int     `json:"quality_index,omitempty"`
+	AspectRatio43 bool    `json:"aspect_ratio_4_3,omitempty"`
+	TextBanner    bool    `json:"text_banner,omitempty"`
+	TrimStart     float64 `json:"trim_start,omitempty"`
+	TrimEnd       float64 `json:"trim_end,omitempty"`
+	// StartAt is the RFC3339 time the item is due on air, empty for back to
+	// back with the previous item
+	StartAt string `json:"start_at,omitempty"`
+}
+
+func (o itemOptions) isZero() bool {
+	return o == itemOptions{}
+}
+
+// parseItemOptions reads space separated terms: quality=1 43=on banner=off
+// start=90 end=2400 at=21:30. Terms left out keep their value from base; at
+// is the first such clock time from day on, so a slot after midnight follows
+// a late evening start. "at=" clears it.
+func parseItemOptions(s string, base itemOptions, day time.Time) (itemOptions, error) {
+	o := base
+	for _, term := range strings.Fields(s) {
+		key, value, ok := strings.Cut(term, "=")
+		if !ok {
+			return o, fmt.Errorf("option %q: want key=value", term)
+		}
+		var err error
+		switch key {
+		case "quality":
+			o.QualityIndex, err = strconv.Atoi(value)
+			if err == nil && o.QualityIndex < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "43":
+			o.AspectRatio43, err = parseOnOff(value)
+		case "banner":
+			o.TextBanner, err = parseOnOff(value)
+		case "start":
+			o.TrimStart, err = strconv.ParseFloat(value, 64)
+		case "end":
+			o.TrimEnd, err = strconv.ParseFloat(value, 64)
+		case "at":
+			o.StartAt = ""
+			if value != "" {
+				var t time.Time
+				t, err = time.ParseInLocation("15:04", value, day.Location())
+				if err == nil {
+					slot := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+					if slot.Before(day.Truncate(time.Minute)) {
+						slot = slot.AddDate(0, 0, 1)
+					}
+					o.StartAt = slot.Format(time.RFC3339)
+				}
+			}
+		default:
+			return o, fmt.Errorf("unknown option %q (quality, 43, banner, start, end, at)", key)
+		}
+		if err != nil {
+			return o, fmt.Errorf("option %s: %w", key, err)
+		}
+	}
+	if o.TrimEnd > 0 && o.TrimEnd <= o.TrimStart {
+		return o, fmt.Errorf("end must be after start")
+	}
+	return o, nil
+}
+
+func parseOnOff(s string) (bool, error) {
+	switch s {
+	case "on", "yes", "true", "1":
+		return true, nil
+	case "off", "no", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("want on or off, got %q", s)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func (o itemOptions) String() string {
+	s := fmt.Sprintf("quality=%d 43=%s banner=%s start=%g end=%g",
+		o.QualityIndex, onOff(o.AspectRatio43), onOff(o.TextBanner), o.TrimStart, o.TrimEnd)
+	if at, ok := o.startAt(); ok {
+		s += " at=" + at.Format("15:04")
+	}
+	return s
+}
+
+// startAt is StartAt parsed, if set and valid.
+func (o itemOptions) startAt() (time.Time, bool) {
+	if o.StartAt == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, o.StartAt)
+	return t.Local(), err == nil
+}
+
+// trimmed is how long a file of duration d airs with these options.
+func (o itemOptions) trimmed(d time.Duration) time.Duration {
+	if o.TrimEnd > 0 && time.Duration(o.TrimEnd*float64(time.Second)) < d {
+		d = time.Duration(o.TrimEnd * float64(time.Second))
+	}
+	return max(d-time.Duration(o.TrimStart*float64(time.Second)), 0)
+}
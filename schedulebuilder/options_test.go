@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseItemOptionsSlot(t *testing.T) {
+	evening := time.Date(2026, 10, 15, 22, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		at   string
+		want time.Time
+	}{
+		{"later that evening", "at=23:15", time.Date(2026, 10, 15, 23, 15, 0, 0, time.Local)},
+		{"at the start", "at=22:00", evening},
+		{"after midnight", "at=00:30", time.Date(2026, 10, 16, 0, 30, 0, 0, time.Local)},
+		{"before the start", "at=21:00", time.Date(2026, 10, 16, 21, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := parseItemOptions(tt.at, itemOptions{}, evening)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := o.startAt()
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("slot = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlotAfterMidnightIsNoConflict(t *testing.T) {
+	evening := time.Date(2026, 10, 15, 22, 0, 0, 0, time.Local)
+	o, err := parseItemOptions("at=00:30", itemOptions{}, evening)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot, _ := o.startAt()
+	entries := buildTimeline(
+		[]string{"film.mkv", "late.mkv"}, []string{"1", "2"},
+		map[string]time.Duration{"1": 2 * time.Hour, "2": time.Hour},
+		map[string]time.Time{"2": slot},
+		evening, time.Time{},
+	)
+	if conflicts := findConflicts(entries, nil); len(conflicts) != 0 {
+		t.Errorf("conflicts %v", conflicts)
+	}
+	if !entries[1].start.Equal(slot) {
+		t.Errorf("late.mkv starts %s, want its %s slot", entries[1].start, slot)
+	}
+}
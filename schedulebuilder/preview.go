@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// previewPlayers are tried in order when PREVIEW_PLAYER is not set.
+var previewPlayers = []string{"mpv", "ffplay"}
+
+// previewFile opens file in an external player without waiting for it. Its
+// output is discarded so it does not draw over the TUI.
+func previewFile(file string) (string, error) {
+	var player []string
+	if p := os.Getenv("PREVIEW_PLAYER"); p != "" {
+		player = strings.Fields(p)
+	} else {
+		for _, name := range previewPlayers {
+			if _, err := exec.LookPath(name); err == nil {
+				player = []string{name}
+				break
+			}
+		}
+	}
+	if len(player) == 0 {
+		return "", errors.New("no player found, install mpv or ffplay or set PREVIEW_PLAYER")
+	}
+	if player[0] == "ffplay" && len(player) == 1 {
+		player = append(player, "-autoexit", "-loglevel", "quiet")
+	}
+	cmd := exec.Command(player[0], append(player[1:], file)...)
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	// reap it whenever it is closed
+	go cmd.Wait()
+	return filepath.Base(player[0]), nil
+}
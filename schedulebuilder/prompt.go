@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// promptKind tells the main screen what a submitted prompt value is for.
+type promptKind int
+
+const (
+	promptNone promptKind = iota
+	promptStartTime
+	promptFillTarget
+	promptIdleSeconds
+	promptIdleDescription
+	promptEndBoundary
+	promptLibraryFilter
+	promptAddTop
+	promptItemOptions
+	promptItemTitle
+	promptInterleave
+	promptBlock
+)
+
+// Prompt is a one line question shown under the columns.
+type Prompt struct {
+	input textinput.Model
+	kind  promptKind
+	label string
+}
+
+func newPrompt() Prompt {
+	ti := textinput.New()
+	ti.CharLimit = 256
+	ti.Width = 40
+	return Prompt{input: ti}
+}
+
+func (p *Prompt) active() bool {
+	return p.kind != promptNone
+}
+
+func (p *Prompt) open(kind promptKind, label, value string) {
+	p.kind = kind
+	p.label = label
+	p.input.SetValue(value)
+	p.input.CursorEnd()
+	p.input.Focus()
+}
+
+func (p *Prompt) close() {
+	p.kind = promptNone
+	p.input.Blur()
+}
+
+func (p *Prompt) update(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return cmd
+}
+
+func (p *Prompt) value() string {
+	return strings.TrimSpace(p.input.Value())
+}
+
+func (p *Prompt) view() string {
+	return p.label + " " + p.input.View() + " (Enter to confirm, Esc to cancel)"
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// maxRecentDirs is how many base directories are remembered.
+const maxRecentDirs = 10
+
+// stateDir is where schedulebuilder keeps its files between runs.
+func stateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "schedulebuilder")
+}
+
+func recentDirsFile() string {
+	return filepath.Join(stateDir(), "recent_dirs.json")
+}
+
+// loadRecentDirs returns the remembered base directories, most recent first.
+func loadRecentDirs() []string {
+	data, err := os.ReadFile(recentDirsFile())
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	if err := json.Unmarshal(data, &dirs); err != nil {
+		return nil
+	}
+	return dirs
+}
+
+// rememberDir moves dir to the front of the recent list and saves it.
+func rememberDir(dir string) error {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	dirs := []string{dir}
+	for _, d := range loadRecentDirs() {
+		if d != dir && len(dirs) < maxRecentDirs {
+			dirs = append(dirs, d)
+		}
+	}
+	data, err := json.MarshalIndent(dirs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(recentDirsFile(), data, 0o644)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// statusInterval is how often the server status bar is refreshed.
+const statusInterval = 10 * time.Second
+
+// serverStatusMsg is the result of polling the server's /nowplaying.
+type serverStatusMsg struct {
+	Playing   bool      `json:"playing"`
+	Index     int       `json:"index"`
+	Title     string    `json:"title"`
+	StartedAt time.Time `json:"started_at"`
+	checked   time.Time
+	err       error
+	gen       int // of the screen that polled
+}
+
+// serverConfigured reports whether BYSCHIITV_URL is set; the status bar is
+// only shown then, so working offline stays quiet.
+func serverConfigured() bool {
+	return os.Getenv("BYSCHIITV_URL") != ""
+}
+
+func fetchServerStatus(gen int) serverStatusMsg {
+	st := serverStatusMsg{checked: time.Now(), gen: gen}
+	resp, err := httpClient.Get(serverURL() + "/nowplaying")
+	if err != nil {
+		st.err = err
+		return st
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		st.err = fmt.Errorf("server: %s", resp.Status)
+		return st
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		st.err = err
+	}
+	return st
+}
+
+// pollServerStatus reads the server status in the background, after delay,
+// for the screen of generation gen.
+func pollServerStatus(gen int, delay time.Duration) tea.Cmd {
+	if !serverConfigured() {
+		return nil
+	}
+	if delay == 0 {
+		return func() tea.Msg { return fetchServerStatus(gen) }
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return fetchServerStatus(gen)
+	})
+}
+
+// view is the one line status bar.
+func (st *serverStatusMsg) view() string {
+	if st.checked.IsZero() {
+		return hintStyle.Render("server " + serverURL() + ": connecting...")
+	}
+	if st.err != nil {
+		return errorStyle.Render(fmt.Sprintf("server %s: unreachable (%v)", serverURL(), st.err))
+	}
+	if !st.Playing {
+		return hintStyle.Render("server " + serverURL() + ": connected, stopped")
+	}
+	s := fmt.Sprintf("server %s: on air #%d %s", serverURL(), st.Index+1, st.Title)
+	if !st.StartedAt.IsZero() {
+		s += fmt.Sprintf(" (since %s)", st.StartedAt.Local().Format("15:04"))
+	}
+	return hintStyle.Render(s)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// session is the work in progress saved on quit.
+type session struct {
+	BaseDir       string              `json:"base_dir"`
+	Planned       []string            `json:"planned"`
+	Specials      map[string]loadItem `json:"specials,omitempty"`
+	RowOptions    []itemOptions       `json:"row_options,omitempty"` // of Planned[i]
+	SearchHistory []string            `json:"search_history,omitempty"`
+	StartTime     time.Time           `json:"start_time,omitempty"`
+	SavedAt       time.Time           `json:"saved_at"`
+}
+
+func sessionFile() string {
+	return filepath.Join(stateDir(), "session.json")
+}
+
+// loadSession returns the last saved session, nil if there is none.
+func loadSession() *session {
+	data, err := os.ReadFile(sessionFile())
+	if err != nil {
+		return nil
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+func saveSession(s session) error {
+	s.SavedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir(), 0o755); err != nil {
+		return err
+	}
+	// write then rename, so a crash mid-write keeps the previous session
+	tmp := sessionFile() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sessionFile())
+}
+
+// session captures what is worth keeping of the main screen.
+func (m *MainScreen) session() session {
+	return session{
+		BaseDir:       m.baseDir,
+		Planned:       append([]string{}, m.plannedColumn.items...),
+		Specials:      m.specials,
+		RowOptions:    m.rowOptions(),
+		SearchHistory: m.search.history,
+		StartTime:     m.startTime,
+	}
+}
+
+// restore loads a saved session into a freshly scanned main screen.
+func (m *MainScreen) restore(s *session) {
+	m.plannedColumn.setItems(append([]string{}, s.Planned...))
+	for label, item := range s.Specials {
+		m.addSpecial(label, item)
+	}
+	for i, o := range s.RowOptions {
+		if i < len(m.plannedColumn.ids) {
+			m.setOptions(m.plannedColumn.ids[i], o)
+		}
+	}
+	m.search.history = append([]string{}, s.SearchHistory...)
+	m.search.historyIdx = len(m.search.history)
+	m.startTime = s.StartTime
+	m.status = "restored session from " + s.SavedAt.Format("Mon 15:04")
+}
@@ -0,0 +1,26 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+var (
+	titleStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("230")).
+			Background(lipgloss.Color("62")).
+			Padding(0, 1)
+
+	paneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("240"))
+
+	activePaneStyle = paneStyle.
+			BorderForeground(lipgloss.Color("62"))
+
+	paneTitleStyle = lipgloss.NewStyle().Bold(true)
+
+	cursorStyle    = lipgloss.NewStyle().Bold(true).Reverse(true)
+	selectedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
+	duplicateStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	hintStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+)
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tagCounts counts the scanned files carrying each tag.
+func tagCounts(files []string, tags map[string][]string) map[string]int {
+	counts := map[string]int{}
+	for _, file := range files {
+		for _, t := range tags[file] {
+			counts[t]++
+		}
+	}
+	return counts
+}
+
+// openTagBrowser lists the library tags, the cursor on the filtered one.
+func (m *MainScreen) openTagBrowser() {
+	counts := tagCounts(m.allScanned, m.tags)
+	if len(counts) == 0 {
+		m.status = "no tags: add a " + tagsSuffix + " file next to the media"
+		return
+	}
+	names := make([]string, 0, len(counts))
+	for t := range counts {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	m.tagColumn.setItems(names)
+	m.tagColumn.cursor = 0
+	for i, t := range names {
+		if t == m.filter.tag {
+			m.tagColumn.cursor = i
+		}
+	}
+	m.tagBrowser = true
+}
+
+func (m *MainScreen) updateTagBrowser(key string) tea.Cmd {
+	c := &m.tagColumn
+	switch key {
+	case "up", "k":
+		c.moveCursor(-1)
+	case "down", "j":
+		c.moveCursor(1)
+	case "enter":
+		m.filter.tag = c.items[c.cursor]
+		m.scannedColumn.clearSelection()
+		m.refreshScanned()
+		m.tagBrowser = false
+		m.status = "library filtered by tag " + m.filter.tag + " (f to change)"
+	case "a":
+		tag := c.items[c.cursor]
+		var items []string
+		for _, item := range m.allScanned {
+			if hasTag(m.tags, item, tag) {
+				items = append(items, item)
+			}
+		}
+		m.tagBrowser = false
+		m.addItems(items)
+	case "t", "esc":
+		m.tagBrowser = false
+	}
+	return nil
+}
+
+// tagBrowserView lists the tags with how many files carry them.
+func (m *MainScreen) tagBrowserView() string {
+	counts := tagCounts(m.allScanned, m.tags)
+	var b strings.Builder
+	b.WriteString("Tags\n\n")
+	for i, t := range m.tagColumn.items {
+		cur := " "
+		if i == m.tagColumn.cursor {
+			cur = ">"
+		}
+		line := fmt.Sprintf("%s %-24s %4d files", cur, t, counts[t])
+		if i == m.tagColumn.cursor {
+			line = cursorStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tagsSuffix names the sidecar file holding the tags of a media file:
+// "ep01.mkv" is tagged by "ep01.mkv.tags", comma or newline separated.
+const tagsSuffix = ".tags"
+
+// loadTags reads the sidecar tags of files (relative to baseDir). Tags are
+// lower case; files without a sidecar are left out.
+func loadTags(baseDir string, files []string) map[string][]string {
+	tags := map[string][]string{}
+	for _, rel := range files {
+		data, err := os.ReadFile(filepath.Join(baseDir, rel) + tagsSuffix)
+		if err != nil {
+			continue
+		}
+		if t := parseTags(string(data)); len(t) > 0 {
+			tags[rel] = t
+		}
+	}
+	return tags
+}
+
+// parseTags splits s on commas and newlines, dropping blanks and repeats.
+func parseTags(s string) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if _, ok := seen[t]; ok || t == "" {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hasTag reports whether item carries tag.
+func hasTag(tags map[string][]string, item, tag string) bool {
+	for _, t := range tags[item] {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
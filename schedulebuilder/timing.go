@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// parseStartTime reads a channel start time as "HH:MM" today, or "now"/empty
+// for the zero time, meaning "whenever the schedule is pushed".
+func parseStartTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "now" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("15:04", s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("start time %q: want HH:MM", s)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location()), nil
+}
+
+// defaultStartTime is SCHEDULE_START, when set and valid.
+func defaultStartTime() time.Time {
+	t, _ := parseStartTime(os.Getenv("SCHEDULE_START"), time.Now())
+	return t
+}
+
+// plannedTotal sums the known durations of items, counting the unknown ones.
+func plannedTotal(items []string, durations map[string]time.Duration) (time.Duration, int) {
+	var total time.Duration
+	unknown := 0
+	for _, item := range items {
+		d, ok := durations[item]
+		if !ok {
+			unknown++
+			continue
+		}
+		total += d
+	}
+	return total, unknown
+}
+
+// defaultEndBoundary is SCHEDULE_END ("HH:MM"), zero when unset.
+func defaultEndBoundary() time.Time {
+	t, _ := parseStartTime(os.Getenv("SCHEDULE_END"), time.Now())
+	return t
+}
+
+// timelineEntry is one planned item placed on the clock.
+type timelineEntry struct {
+	item  string
+	start time.Time
+	end   time.Time
+	// known is false when the item duration has not been probed, so end (and
+	// every later time) is a lower bound
+	known           bool
+	crossesMidnight bool
+	pastBoundary    bool
+	// slot is the fixed start time of the item, zero if none, and late how
+	// long after it the previous items let it start
+	slot time.Time
+	late time.Duration
+}
+
+// buildTimeline lays items out back to back from start. durations and slots
+// are by row id, ids[i] being the row of items[i]. Items with a slot wait for
+// it, as the server does. boundary is a clock time (its date is ignored) the
+// schedule should end by, zero for none.
+func buildTimeline(items, ids []string, durations map[string]time.Duration, slots map[string]time.Time, start, boundary time.Time) []timelineEntry {
+	var limit time.Time
+	if !boundary.IsZero() {
+		limit = time.Date(start.Year(), start.Month(), start.Day(), boundary.Hour(), boundary.Minute(), 0, 0, start.Location())
+		if !limit.After(start) {
+			limit = limit.AddDate(0, 0, 1)
+		}
+	}
+	entries := make([]timelineEntry, 0, len(items))
+	at := start
+	for i, item := range items {
+		d, ok := durations[ids[i]]
+		e := timelineEntry{item: item, start: at, known: ok, slot: slots[ids[i]]}
+		if !e.slot.IsZero() {
+			if e.slot.After(at) {
+				e.start = e.slot
+			} else {
+				e.late = at.Sub(e.slot)
+			}
+		}
+		e.end = e.start.Add(d)
+		e.crossesMidnight = e.end.YearDay() != e.start.YearDay()
+		e.pastBoundary = !limit.IsZero() && e.end.After(limit)
+		entries = append(entries, e)
+		at = e.end
+	}
+	return entries
+}
+
+// findConflicts flags the entries starting after their slot and those
+// overlapping a programme still to air on the server, by entry index.
+func findConflicts(entries []timelineEntry, server []serverProgramme) map[int]string {
+	conflicts := map[int]string{}
+	for i, e := range entries {
+		if e.late > 0 {
+			conflicts[i] = fmt.Sprintf("starts %s after its %s slot", formatDuration(e.late), e.slot.Format("15:04"))
+			continue
+		}
+		for _, p := range server {
+			if !p.DurationKnown {
+				continue
+			}
+			if e.start.Before(p.end()) && p.Start.Before(e.end) {
+				conflicts[i] = fmt.Sprintf("overlaps %q on the server (%s-%s)", p.Title, p.Start.Local().Format("15:04"), p.end().Local().Format("15:04"))
+				break
+			}
+		}
+	}
+	return conflicts
+}
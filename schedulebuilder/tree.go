@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// The tree pane lists the library by folder. Its rows are keyed by path
+// relative to baseDir: folders end with "/", files are plain paths, so file
+// rows can be planned as they are.
+
+func isDirKey(key string) bool {
+	return strings.HasSuffix(key, "/")
+}
+
+// treeRows returns the visible rows for files, folders before files at each
+// level. Only the content of expanded folders is listed.
+func treeRows(files []string, expanded map[string]bool) []string {
+	children := map[string][]string{}
+	seen := map[string]bool{}
+	for _, file := range files {
+		file = strings.ReplaceAll(file, "\\", "/")
+		parent := ""
+		parts := strings.Split(file, "/")
+		for _, dir := range parts[:len(parts)-1] {
+			key := parent + dir + "/"
+			if !seen[key] {
+				seen[key] = true
+				children[parent] = append(children[parent], key)
+			}
+			parent = key
+		}
+		children[parent] = append(children[parent], file)
+	}
+	for _, c := range children {
+		sort.Slice(c, func(i, j int) bool {
+			if isDirKey(c[i]) != isDirKey(c[j]) {
+				return isDirKey(c[i])
+			}
+			return c[i] < c[j]
+		})
+	}
+
+	var rows []string
+	var walk func(parent string)
+	walk = func(parent string) {
+		for _, key := range children[parent] {
+			rows = append(rows, key)
+			if isDirKey(key) && expanded[key] {
+				walk(key)
+			}
+		}
+	}
+	walk("")
+	return rows
+}
+
+// treeLabel renders a row indented by depth, folders with an open/closed
+// marker.
+func treeLabel(key string, expanded map[string]bool) string {
+	trimmed := strings.TrimSuffix(key, "/")
+	depth := strings.Count(trimmed, "/")
+	name := trimmed[strings.LastIndex(trimmed, "/")+1:]
+	indent := strings.Repeat("  ", depth)
+	if !isDirKey(key) {
+		return indent + "  " + name
+	}
+	if expanded[key] {
+		return indent + "▾ " + name + "/"
+	}
+	return indent + "▸ " + name + "/"
+}
+
+// filesUnder lists the files of a folder row and all its subfolders, or just
+// the file for a file row.
+func filesUnder(files []string, key string) []string {
+	if !isDirKey(key) {
+		return []string{key}
+	}
+	var out []string
+	for _, file := range files {
+		if strings.HasPrefix(strings.ReplaceAll(file, "\\", "/"), key) {
+			out = append(out, file)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,55 @@
+package main
+
+// maxUndo bounds the undo history of the planned column.
+const maxUndo = 100
+
+// plannedState is a snapshot of the planned column for undo/redo.
+type plannedState struct {
+	items  []string
+	ids    []string
+	cursor int
+}
+
+func (m *MainScreen) snapshot() plannedState {
+	return plannedState{
+		items:  append([]string{}, m.plannedColumn.items...),
+		ids:    append([]string{}, m.plannedColumn.ids...),
+		cursor: m.plannedColumn.cursor,
+	}
+}
+
+func (m *MainScreen) apply(st plannedState) {
+	m.plannedColumn.items = st.items
+	m.plannedColumn.ids = st.ids
+	m.plannedColumn.cursor = st.cursor
+	m.plannedColumn.clearSelection()
+}
+
+// checkpoint records the planned column before an edit.
+func (m *MainScreen) checkpoint() {
+	m.undoStack = append(m.undoStack, m.snapshot())
+	if len(m.undoStack) > maxUndo {
+		m.undoStack = m.undoStack[1:]
+	}
+	m.redoStack = nil
+}
+
+func (m *MainScreen) undo() {
+	if len(m.undoStack) == 0 {
+		m.status = "nothing to undo"
+		return
+	}
+	m.redoStack = append(m.redoStack, m.snapshot())
+	m.apply(m.undoStack[len(m.undoStack)-1])
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+}
+
+func (m *MainScreen) redo() {
+	if len(m.redoStack) == 0 {
+		m.status = "nothing to redo"
+		return
+	}
+	m.undoStack = append(m.undoStack, m.snapshot())
+	m.apply(m.redoStack[len(m.redoStack)-1])
+	m.redoStack = m.redoStack[:len(m.redoStack)-1]
+}
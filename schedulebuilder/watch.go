@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long the base directory has to stay quiet before it is
+// rescanned, so a copy in progress triggers one refresh and not hundreds.
+const watchSettle = time.Second
+
+// mediaChangedMsg tells the main screen of generation gen files appeared or
+// disappeared.
+type mediaChangedMsg struct{ gen int }
+
+// mediaWatcher reports changes anywhere under a base directory. fsnotify is
+// not recursive, so every folder is watched, new ones as they appear.
+type mediaWatcher struct {
+	w       *fsnotify.Watcher
+	changed chan struct{}
+}
+
+// newMediaWatcher starts watching root. It returns nil when the platform or
+// the limits on watches do not allow it; the screen just stays static then.
+func newMediaWatcher(root string) *mediaWatcher {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil
+	}
+	mw := &mediaWatcher{w: w, changed: make(chan struct{}, 1)}
+	if err := mw.addTree(root); err != nil {
+		w.Close()
+		return nil
+	}
+	go mw.run()
+	return mw
+}
+
+func (mw *mediaWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			return mw.w.Add(path)
+		}
+		return nil
+	})
+}
+
+func (mw *mediaWatcher) run() {
+	var settle <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-mw.w.Events:
+			if !ok {
+				close(mw.changed)
+				return
+			}
+			if ev.Has(fsnotify.Chmod) {
+				continue
+			}
+			if ev.Has(fsnotify.Create) {
+				// a new folder, or one moved in with its files
+				_ = mw.addTree(ev.Name)
+			}
+			settle = time.After(watchSettle)
+		case <-mw.w.Errors:
+		case <-settle:
+			settle = nil
+			select {
+			case mw.changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// next waits for the next change, for the screen of generation gen.
+func (mw *mediaWatcher) next(gen int) tea.Cmd {
+	if mw == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		if _, ok := <-mw.changed; !ok {
+			return nil
+		}
+		return mediaChangedMsg{gen: gen}
+	}
+}
+
+func (mw *mediaWatcher) close() {
+	if mw != nil {
+		mw.w.Close()
+	}
+}
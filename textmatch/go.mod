@@ -0,0 +1,3 @@
+module textmatch
+
+go 1.23
@@ -0,0 +1,302 @@
+// Package textmatch ranks strings against a search query: edit distance,
+// character n-gram cosine and token set Jaccard similarities, and their mean.
+// It is shared by the schedulebuilder search box and the server's library
+// search.
+package textmatch
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Levenshtein computes the Levenshtein distance between two strings.
+func Levenshtein(a, b string) int {
+	la := len(a)
+	lb := len(b)
+	if la == 0 {
+		return lb
+	}
+	if lb == 0 {
+		return la
+	}
+	dp := make([]int, lb+1)
+	for j := 0; j <= lb; j++ {
+		dp[j] = j
+	}
+	for i := 1; i <= la; i++ {
+		prev := dp[0]
+		dp[0] = i
+		for j := 1; j <= lb; j++ {
+			cur := dp[j]
+			cost := 0
+			if a[i-1] != b[j-1] {
+				cost = 1
+			}
+			v := prev + cost
+			if dp[j-1]+1 < v {
+				v = dp[j-1] + 1
+			}
+			if dp[j]+1 < v {
+				v = dp[j] + 1
+			}
+			prev = cur
+			dp[j] = v
+		}
+	}
+	return dp[lb]
+}
+
+// SortByLevenshtein returns a new slice of inputs sorted by Levenshtein distance to query (ascending).
+func SortByLevenshtein(inputs []string, query string) []string {
+	if query == "" {
+		out := make([]string, len(inputs))
+		copy(out, inputs)
+		return out
+	}
+	type pair struct {
+		s string
+		d int
+	}
+	ps := make([]pair, 0, len(inputs))
+	qlower := strings.ToLower(query)
+	for _, s := range inputs {
+		d := Levenshtein(Strip(s), qlower)
+		ps = append(ps, pair{s: s, d: d})
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].d == ps[j].d {
+			return ps[i].s < ps[j].s
+		}
+		return ps[i].d < ps[j].d
+	})
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.s
+	}
+	return out
+}
+
+// ngrams returns a map of character n-gram -> count using runes.
+func ngrams(s string, n int) map[string]int {
+	m := make(map[string]int)
+	r := []rune(strings.ToLower(s))
+	if n <= 0 {
+		return m
+	}
+	if len(r) < n {
+		if len(r) > 0 {
+			m[string(r)]++
+		}
+		return m
+	}
+	for i := 0; i <= len(r)-n; i++ {
+		m[string(r[i:i+n])]++
+	}
+	return m
+}
+
+// CosineNGram computes cosine similarity between two strings using character n-grams.
+// Returns value in [0,1], where 1 means identical n-gram vectors.
+func CosineNGram(a, b string, n int) float64 {
+	if a == b {
+		return 1.0
+	}
+	ma := ngrams(a, n)
+	mb := ngrams(b, n)
+	var dot float64
+	var na2 float64
+	var nb2 float64
+	for k, va := range ma {
+		vb := mb[k]
+		dot += float64(va * vb)
+		na2 += float64(va * va)
+	}
+	for _, vb := range mb {
+		nb2 += float64(vb * vb)
+	}
+	if na2 == 0 || nb2 == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na2*nb2)
+}
+
+// tokenize builds a set of tokens from the input string. Tokens are sequences of letters or numbers.
+func tokenize(s string) map[string]struct{} {
+	out := make(map[string]struct{})
+	lower := strings.ToLower(s)
+	f := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}
+	for _, t := range strings.FieldsFunc(lower, f) {
+		if t == "" {
+			continue
+		}
+		out[t] = struct{}{}
+	}
+	return out
+}
+
+// JaccardTokenSet computes Jaccard similarity between token sets of two strings.
+// Tokens are extracted by splitting on non-letter/non-digit characters. Result in [0,1].
+func JaccardTokenSet(a, b string) float64 {
+	sa := tokenize(a)
+	sb := tokenize(b)
+	if len(sa) == 0 && len(sb) == 0 {
+		return 1.0
+	}
+	if len(sa) == 0 || len(sb) == 0 {
+		return 0.0
+	}
+	inter := 0
+	for k := range sa {
+		if _, ok := sb[k]; ok {
+			inter++
+		}
+	}
+	union := len(sa) + len(sb) - inter
+	if union == 0 {
+		return 0.0
+	}
+	return float64(inter) / float64(union)
+}
+
+// SortByCosine sorts inputs by cosine n-gram similarity to query (descending).
+func SortByCosine(inputs []string, query string, n int) []string {
+	if query == "" {
+		out := make([]string, len(inputs))
+		copy(out, inputs)
+		return out
+	}
+	type pair struct {
+		s string
+		v float64
+	}
+	ps := make([]pair, 0, len(inputs))
+	for _, s := range inputs {
+		v := CosineNGram(Strip(s), query, n)
+		ps = append(ps, pair{s: s, v: v})
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].v == ps[j].v {
+			return ps[i].s < ps[j].s
+		}
+		return ps[i].v > ps[j].v
+	})
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.s
+	}
+	return out
+}
+
+// SortByJaccard sorts inputs by Jaccard token-set similarity to query (descending).
+func SortByJaccard(inputs []string, query string) []string {
+	if query == "" {
+		out := make([]string, len(inputs))
+		copy(out, inputs)
+		return out
+	}
+	type pair struct {
+		s string
+		v float64
+	}
+	ps := make([]pair, 0, len(inputs))
+	for _, s := range inputs {
+		v := JaccardTokenSet(s, query)
+		ps = append(ps, pair{s: s, v: v})
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].v == ps[j].v {
+			return ps[i].s < ps[j].s
+		}
+		return ps[i].v > ps[j].v
+	})
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.s
+	}
+	return out
+}
+
+// FilterSubstring keeps the inputs containing query, ignoring case.
+func FilterSubstring(inputs []string, query string) []string {
+	q := strings.ToLower(query)
+	out := []string{}
+	for _, s := range inputs {
+		if strings.Contains(strings.ToLower(s), q) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// FilterRegex keeps the inputs matching the regular expression query,
+// ignoring case.
+func FilterRegex(inputs []string, query string) ([]string, error) {
+	re, err := regexp.Compile("(?i)" + query)
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, s := range inputs {
+		if re.MatchString(s) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// SortByCombined sorts inputs by the mean of the three similarities
+// (descending), Levenshtein distance being normalized to [0,1] first.
+func SortByCombined(inputs []string, query string) []string {
+	if query == "" {
+		out := make([]string, len(inputs))
+		copy(out, inputs)
+		return out
+	}
+	type pair struct {
+		s string
+		v float64
+	}
+	ps := make([]pair, 0, len(inputs))
+	for _, s := range inputs {
+		ps = append(ps, pair{s: s, v: Combined(s, query)})
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].v == ps[j].v {
+			return ps[i].s < ps[j].s
+		}
+		return ps[i].v > ps[j].v
+	})
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.s
+	}
+	return out
+}
+
+// Combined is the similarity SortByCombined ranks by, in [0,1].
+func Combined(s, query string) float64 {
+	qlower := strings.ToLower(query)
+	stripped := Strip(s)
+	lev := 1.0
+	if longest := max(len(stripped), len(qlower)); longest > 0 {
+		lev = 1 - float64(Levenshtein(stripped, qlower))/float64(longest)
+	}
+	return (lev + CosineNGram(stripped, qlower, 3) + JaccardTokenSet(s, query)) / 3
+}
+
+// Strip keeps only the letters and digits of s, lower cased.
+func Strip(s string) string {
+	// remove spaces and punctuation, convert to lower case
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
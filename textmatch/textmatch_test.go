@@ -0,0 +1,217 @@
+package textmatch
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"twinpeaks", "twinpeaks", 0},
+	}
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCosineNGram(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"twin peaks", "twin peaks", 1},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+		// "ab" is shorter than n: it becomes a single gram
+		{"ab", "AB", 1},
+		// grams abc, bcd against abc
+		{"abcd", "abc", 1 / math.Sqrt2},
+	}
+	for _, tt := range tests {
+		if got := CosineNGram(tt.a, tt.b, 3); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("CosineNGram(%q, %q, 3) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestJaccardTokenSet(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"a", "", 0},
+		{"Twin Peaks S01E01", "twin-peaks s01e01", 1},
+		{"twin peaks", "twin towers", 1.0 / 3},
+		{"a a b", "b", 0.5},
+	}
+	for _, tt := range tests {
+		if got := JaccardTokenSet(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("JaccardTokenSet(%q, %q) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCombined(t *testing.T) {
+	// the title is stripped, the query only lower cased
+	if got := Combined("twinpeaks", "TwinPeaks"); math.Abs(got-1) > 1e-9 {
+		t.Errorf("Combined of the same title = %g, want 1", got)
+	}
+	close := Combined("Twin.Peaks.S01E01.mkv", "twin peaks")
+	far := Combined("Blue.Velvet.1986.mkv", "twin peaks")
+	if close <= far {
+		t.Errorf("Combined ranks %g for the match under %g for the miss", close, far)
+	}
+}
+
+func TestSortAndFilter(t *testing.T) {
+	inputs := []string{"Blue Velvet", "Twin Peaks", "Twin Peaks Fire Walk With Me", "Dune"}
+	for name, sort := range map[string]func([]string, string) []string{
+		"levenshtein": SortByLevenshtein,
+		"cosine":      func(in []string, q string) []string { return SortByCosine(in, q, 3) },
+		"jaccard":     SortByJaccard,
+		"combined":    SortByCombined,
+	} {
+		got := sort(inputs, "twin peaks")
+		if got[0] != "Twin Peaks" {
+			t.Errorf("%s: best match %q, want Twin Peaks (%q)", name, got[0], got)
+		}
+		if len(got) != len(inputs) {
+			t.Errorf("%s: %d results for %d inputs", name, len(got), len(inputs))
+		}
+		if empty := sort(inputs, ""); !slices.Equal(empty, inputs) {
+			t.Errorf("%s: empty query reordered %q", name, empty)
+		}
+	}
+	if got := FilterSubstring(inputs, "PEAKS"); !slices.Equal(got, []string{"Twin Peaks", "Twin Peaks Fire Walk With Me"}) {
+		t.Errorf("FilterSubstring = %q", got)
+	}
+	if got, err := FilterRegex(inputs, "^(dune|blue)"); err != nil || !slices.Equal(got, []string{"Blue Velvet", "Dune"}) {
+		t.Errorf("FilterRegex = %q, %v", got, err)
+	}
+	if _, err := FilterRegex(inputs, "("); err == nil {
+		t.Error("FilterRegex accepted an invalid expression")
+	}
+}
+
+func TestStrip(t *testing.T) {
+	if got := Strip("Twin.Peaks - S01E01 (Pilot)"); got != "twinpeakss01e01pilot" {
+		t.Errorf("Strip = %q", got)
+	}
+}
+
+// inUnit reports whether v is a similarity, allowing for rounding.
+func inUnit(v float64) bool {
+	return v >= 0 && v <= 1+1e-9
+}
+
+func FuzzLevenshtein(f *testing.F) {
+	f.Add("kitten", "sitting")
+	f.Add("", "abc")
+	f.Add("àèì", "aei")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		d := Levenshtein(a, b)
+		if d != Levenshtein(b, a) {
+			t.Errorf("not symmetric: %d vs %d", d, Levenshtein(b, a))
+		}
+		if d < abs(len(a)-len(b)) || d > max(len(a), len(b)) {
+			t.Errorf("distance %d out of [%d, %d]", d, abs(len(a)-len(b)), max(len(a), len(b)))
+		}
+		if (d == 0) != (a == b) {
+			t.Errorf("distance %d for %q and %q", d, a, b)
+		}
+	})
+}
+
+func FuzzSimilarities(f *testing.F) {
+	f.Add("Twin Peaks", "twin peaks")
+	f.Add("", "")
+	f.Add("a a b", "b")
+	f.Add("ßtraße", "STRASSE")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if !utf8.ValidString(a) || !utf8.ValidString(b) {
+			t.Skip()
+		}
+		for name, v := range map[string][2]float64{
+			"CosineNGram":     {CosineNGram(a, b, 3), CosineNGram(b, a, 3)},
+			"JaccardTokenSet": {JaccardTokenSet(a, b), JaccardTokenSet(b, a)},
+		} {
+			if !inUnit(v[0]) {
+				t.Errorf("%s = %g, out of [0,1]", name, v[0])
+			}
+			if math.Abs(v[0]-v[1]) > 1e-9 {
+				t.Errorf("%s not symmetric: %g vs %g", name, v[0], v[1])
+			}
+		}
+		if c := Combined(a, b); !inUnit(c) || math.IsNaN(c) {
+			t.Errorf("Combined = %g, out of [0,1]", c)
+		}
+		if q := Strip(a); q != "" {
+			if c := Combined(q, q); math.Abs(c-1) > 1e-9 {
+				t.Errorf("Combined(%q, %q) = %g, want 1", q, q, c)
+			}
+		}
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+var benchTitles = []string{
+	"Twin.Peaks.S01E01.Pilot.1990.1080p.mkv",
+	"Twin.Peaks.Fire.Walk.With.Me.1992.mkv",
+	"Blue.Velvet.1986.Remastered.mkv",
+	"Mulholland.Drive.2001.mkv",
+	"Dune.1984.Extended.Edition.mkv",
+}
+
+func BenchmarkLevenshtein(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Levenshtein(benchTitles[i%len(benchTitles)], "twinpeakspilot")
+	}
+}
+
+func BenchmarkCosineNGram(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		CosineNGram(benchTitles[i%len(benchTitles)], "twin peaks pilot", 3)
+	}
+}
+
+func BenchmarkJaccardTokenSet(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		JaccardTokenSet(benchTitles[i%len(benchTitles)], "twin peaks pilot")
+	}
+}
+
+func BenchmarkCombined(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Combined(benchTitles[i%len(benchTitles)], "twin peaks pilot")
+	}
+}
+
+func BenchmarkSortByCombined(b *testing.B) {
+	library := make([]string, 0, 1000)
+	for len(library) < cap(library) {
+		library = append(library, benchTitles...)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SortByCombined(library, "twin peaks pilot")
+	}
+}